@@ -4,21 +4,38 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/agidelle/todo_web/internal/api"
 	"github.com/agidelle/todo_web/internal/config"
+	"github.com/agidelle/todo_web/internal/events"
+	"github.com/agidelle/todo_web/internal/grpcapi"
+	"github.com/agidelle/todo_web/internal/reminder"
 	"github.com/agidelle/todo_web/internal/service"
 	"github.com/agidelle/todo_web/internal/storage"
+	"github.com/agidelle/todo_web/internal/webhook"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
 	_ "modernc.org/sqlite"
 )
 
+// Период просмотра очереди webhook, новые события отправляются сразу
+const webhookInterval = 5 * time.Second
+
+// Число последних событий задач, которые клиент SSE может получить после переподключения
+const eventBuffer = 1024
+
 type App struct {
-	cfg     *config.Config
-	handler *api.TaskHandler
+	cfg        *config.Config
+	handler    *api.TaskHandler
+	reminders  *reminder.Engine
+	dispatcher *webhook.Dispatcher
+	events     *events.Broker
+	grpc       *grpc.Server
+	cancel     context.CancelFunc
 }
 
 func Initialize() (*App, *storage.Storage) {
@@ -28,18 +45,90 @@ func Initialize() (*App, *storage.Storage) {
 		log.Fatalf("Ошибка загрузки файла конфигурации: %v", err)
 	}
 
+	return New(cfg)
+}
+
+// New собирает приложение по готовому конфигу
+func New(cfg *config.Config) (*App, *storage.Storage) {
 	db := storage.NewConn(cfg)
-	svc := service.NewService(db)
-	handler := api.NewHandler(svc)
+	svc := service.NewService(db, db)
+	users := service.NewUserService(db)
+	dispatcher := webhook.NewDispatcher(db, webhookInterval, cfg.WebhookPrivate)
+	webhooks := webhook.NewService(db, dispatcher)
+	broker := events.NewBroker(eventBuffer)
+	svc.Subscribe(webhooks.HandleEvent)
+	svc.Subscribe(broker.Publish)
+	handler := api.NewHandler(svc, users, webhooks, broker, service.NewFilterService(db, svc))
+
+	//Пароль из TODO_PASSWORD становится паролем администратора
+	if cfg.Password != "" {
+		if _, err := users.Bootstrap(cfg.Password); err != nil {
+			db.Close()
+			log.Fatalf("Ошибка создания администратора: %v", err)
+		}
+	}
 
-	return &App{
-		cfg:     cfg,
-		handler: handler,
-	}, db
+	app := &App{
+		cfg:        cfg,
+		handler:    handler,
+		dispatcher: dispatcher,
+		events:     broker,
+	}
+	if cfg.GRPCPort > 0 {
+		app.grpc = grpcapi.NewServer(svc, broker, cfg.JWTKey, cfg.Password != "")
+	}
+	if cfg.Reminders {
+		engine, err := newReminders(cfg, svc, db)
+		if err != nil {
+			db.Close()
+			log.Fatalf("Ошибка настройки напоминаний: %v", err)
+		}
+		app.reminders = engine
+	}
+	return app, db
 }
 
 func (a *App) Start() *http.Server {
 	fmt.Println("Starting server...")
+
+	//Фоновые задачи останавливаются в Stop
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	a.dispatcher.Start(ctx)
+	if a.reminders != nil {
+		a.reminders.Start(ctx)
+	}
+
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%d", a.cfg.Port),
+		Handler:      a.Router(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+	if a.grpc != nil {
+		go func() {
+			lis, err := net.Listen("tcp", fmt.Sprintf(":%d", a.cfg.GRPCPort))
+			if err != nil {
+				log.Printf("Error starting gRPC server: %s\n", err)
+				return
+			}
+			fmt.Printf("gRPC listening on port %d.\n", a.cfg.GRPCPort)
+			if err := a.grpc.Serve(lis); err != nil {
+				log.Printf("Error starting gRPC server: %s\n", err)
+			}
+		}()
+	}
+	go func() {
+		fmt.Printf("Listening on port %d.\n", a.cfg.Port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Error starting server: %s\n", err)
+		}
+	}()
+	return server
+}
+
+// Router возвращает обработчик со всеми маршрутами приложения
+func (a *App) Router() http.Handler {
 	authEnabled := a.cfg.Password != ""
 
 	r := chi.NewRouter()
@@ -49,37 +138,81 @@ func (a *App) Start() *http.Server {
 
 	r.Handle("/*", http.FileServer(http.Dir("web")))
 	r.Get("/api/nextdate", a.handler.NextDateHandler)
-	r.Post("/api/signin", a.handler.Login(a.cfg.Password, a.cfg.JWTKey))
+	r.Post("/api/signin", a.handler.Login(a.cfg.JWTKey))
+	//Адрес подписки /api/calendar.ics: расширение отбрасывает middleware.URLFormat
+	r.Get("/api/calendar", a.handler.Calendar)
+	//Токен WebSocket проверяется при подключении или методом auth
+	r.Get("/api/ws", a.handler.WebSocket(a.cfg.JWTKey, authEnabled))
+	if authEnabled && a.cfg.Registration {
+		r.Post("/api/signup", a.handler.Signup(a.cfg.JWTKey))
+	}
 
 	r.Group(func(r chi.Router) {
 		if authEnabled {
-			r.Use(a.handler.JWTMiddleware(a.cfg.Password, a.cfg.JWTKey))
+			r.Use(a.handler.JWTMiddleware(a.cfg.JWTKey))
+			r.Get("/api/me", a.handler.GetMe)
+			r.Put("/api/me", a.handler.UpdateMe)
+			r.With(a.handler.AdminOnly).Get("/api/users", a.handler.ListUsers)
+			r.With(a.handler.AdminOnly).Post("/api/users", a.handler.CreateUser)
 		}
 		r.Get("/api/tasks", a.handler.GetTasks)
 		r.Get("/api/task", a.handler.GetTask)
 		r.Post("/api/task", a.handler.AddTask)
 		r.Put("/api/task", a.handler.UpdateTask)
+		r.Patch("/api/task", a.handler.PatchTask)
 		r.Delete("/api/task", a.handler.DeleteTask)
 		r.Post("/api/task/done", a.handler.Done)
+		r.Get("/api/task/history", a.handler.TaskHistory)
+		r.Get("/api/completed", a.handler.Completed)
+		r.Get("/api/agenda", a.handler.Agenda)
+		r.Get("/api/events", a.handler.Events)
+		r.Post("/api/calendar/token", a.handler.IssueFeedToken)
+		r.Delete("/api/calendar/token", a.handler.RevokeFeedToken)
+		r.Post("/api/import/ics", a.handler.ImportICS)
+		r.Get("/api/export", a.handler.Export)
+		r.Post("/api/import", a.handler.Import)
+		r.Get("/api/webhooks", a.handler.ListWebhooks)
+		r.Post("/api/webhooks", a.handler.AddWebhook)
+		r.Get("/api/webhook", a.handler.GetWebhook)
+		r.Put("/api/webhook", a.handler.UpdateWebhook)
+		r.Delete("/api/webhook", a.handler.DeleteWebhook)
+		r.Get("/api/webhook/deliveries", a.handler.WebhookDeliveries)
+		r.Get("/api/filters", a.handler.ListFilters)
+		r.Post("/api/filters", a.handler.AddFilter)
+		r.Get("/api/filters/{id}", a.handler.GetFilter)
+		r.Put("/api/filters/{id}", a.handler.UpdateFilter)
+		r.Delete("/api/filters/{id}", a.handler.DeleteFilter)
+		r.Get("/api/filters/{id}/tasks", a.handler.FilterTasks)
 	})
 
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", a.cfg.Port),
-		Handler:      r,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 5 * time.Second,
-	}
-	go func() {
-		fmt.Printf("Listening on port %d.\n", a.cfg.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("Error starting server: %s\n", err)
+	r.Route(api.V2Prefix, func(r chi.Router) {
+		r.NotFound(api.NotFoundV2)
+		r.MethodNotAllowed(api.MethodNotAllowedV2)
+		r.Use(api.Negotiate)
+		if authEnabled {
+			r.Use(a.handler.JWTMiddlewareV2(a.cfg.JWTKey))
 		}
-	}()
-	return server
+		r.Get("/tasks", a.handler.ListTasksV2)
+		r.Post("/tasks", a.handler.CreateTaskV2)
+		r.Get("/tasks/{id}", a.handler.GetTaskV2)
+		r.Put("/tasks/{id}", a.handler.UpdateTaskV2)
+		r.Patch("/tasks/{id}", a.handler.PatchTaskV2)
+		r.Delete("/tasks/{id}", a.handler.DeleteTaskV2)
+		r.Post("/tasks/{id}/done", a.handler.DoneTaskV2)
+	})
+	return r
 }
 
 func (a *App) Stop(server *http.Server, db *storage.Storage) {
 	fmt.Println("\nShutting down server ...")
+	if a.cancel != nil {
+		a.cancel()
+	}
+	//Потоки событий иначе держали бы соединения до конца таймаута
+	a.events.Close()
+	if a.grpc != nil {
+		a.grpc.GracefulStop()
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
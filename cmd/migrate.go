@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/agidelle/todo_web/internal/config"
+	"github.com/agidelle/todo_web/internal/storage"
+)
+
+const migrateUsage = "использование: migrate up | down [N] | status"
+
+// Migrate выполняет команду управления схемой БД: up, down [N] или status
+func Migrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	cfg, err := config.LoadCfg()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки файла конфигурации: %w", err)
+	}
+	dsn := cfg.DBPath
+	if cfg.DBdriver == "postgres" {
+		dsn = cfg.DSN
+	}
+	db, err := storage.Open(cfg.DBdriver, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp()
+		for _, m := range applied {
+			fmt.Printf("Применена миграция %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Схема БД актуальна")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("неверное число миграций для отката: %s", args[1])
+			}
+		}
+		reverted, err := db.MigrateDown(steps)
+		for _, m := range reverted {
+			fmt.Printf("Откачена миграция %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		status, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, m := range status {
+			applied := "не применена"
+			if m.AppliedAt != "" {
+				applied = "применена " + m.AppliedAt
+			}
+			fmt.Printf("%04d_%s\t%s\n", m.Version, m.Name, applied)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/agidelle/todo_web/internal/config"
+	"github.com/agidelle/todo_web/internal/reminder"
+	"github.com/agidelle/todo_web/internal/service"
+	"github.com/agidelle/todo_web/internal/storage"
+)
+
+// newReminders собирает планировщик напоминаний из настроек TODO_REMINDER_* и TODO_NOTIFIERS
+func newReminders(cfg *config.Config, svc *service.TaskService, db *storage.Storage) (*reminder.Engine, error) {
+	offsets, err := reminder.ParseOffsets(cfg.ReminderOffsets)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ReminderInterval <= 0 {
+		return nil, fmt.Errorf("некорректный период напоминаний: %v", cfg.ReminderInterval)
+	}
+
+	var notifiers []reminder.Notifier
+	for _, name := range cfg.Notifiers {
+		switch strings.TrimSpace(name) {
+		case "log":
+			notifiers = append(notifiers, reminder.LogNotifier{})
+		case "smtp":
+			if cfg.SMTPAddr == "" || cfg.SMTPFrom == "" || len(cfg.SMTPTo) == 0 {
+				return nil, fmt.Errorf("для напоминаний по почте нужны TODO_SMTP_ADDR, TODO_SMTP_FROM и TODO_SMTP_TO")
+			}
+			notifiers = append(notifiers, &reminder.SMTPNotifier{
+				Addr:     cfg.SMTPAddr,
+				From:     cfg.SMTPFrom,
+				To:       cfg.SMTPTo,
+				Username: cfg.SMTPUser,
+				Password: cfg.SMTPPassword,
+			})
+		case "webhook":
+			if cfg.ReminderWebhook == "" {
+				return nil, fmt.Errorf("для напоминаний через webhook нужен TODO_REMINDER_WEBHOOK")
+			}
+			notifiers = append(notifiers, &reminder.WebhookNotifier{URL: cfg.ReminderWebhook})
+		case "":
+		default:
+			return nil, fmt.Errorf("неизвестный канал напоминаний: %s", name)
+		}
+	}
+	return reminder.NewEngine(svc, db, notifiers, offsets, cfg.ReminderInterval), nil
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/agidelle/todo_web/internal/config"
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/service"
+	"github.com/agidelle/todo_web/internal/storage"
+	"github.com/agidelle/todo_web/internal/todotxt"
+)
+
+const todoTxtUsage = "использование: todotxt export [-user логин] [файл] | import [-user логин] [-dry-run] [файл]"
+
+// TodoTxt выгружает задачи пользователя в todo.txt или загружает их из файла.
+// Без имени файла используются стандартные вывод и ввод. Если задан TODO_PASSWORD,
+// по умолчанию берутся задачи администратора
+func TodoTxt(args []string) error {
+	if len(args) == 0 {
+		return errors.New(todoTxtUsage)
+	}
+	flags := flag.NewFlagSet("todotxt "+args[0], flag.ContinueOnError)
+	login := flags.String("user", "", "логин владельца задач")
+	dry := flags.Bool("dry-run", false, "только проверить задачи, не сохраняя их")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 || (*dry && args[0] != "import") {
+		return errors.New(todoTxtUsage)
+	}
+
+	cfg, err := config.LoadCfg()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки файла конфигурации: %w", err)
+	}
+	dsn := cfg.DBPath
+	if cfg.DBdriver == "postgres" {
+		dsn = cfg.DSN
+	}
+	db, err := storage.Open(cfg.DBdriver, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *login == "" && cfg.Password != "" {
+		*login = service.AdminLogin
+	}
+	var userID int64
+	if *login != "" {
+		user, err := db.FindUser(*login)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return fmt.Errorf("пользователь %q не найден", *login)
+		}
+		userID = user.ID
+	}
+	svc := service.NewService(db, db)
+
+	switch args[0] {
+	case "export":
+		tasks, cErr := svc.Export(userID)
+		if cErr != nil {
+			return cErr
+		}
+		var w io.Writer = os.Stdout
+		if flags.NArg() == 1 {
+			f, err := os.Create(flags.Arg(0))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		return todotxt.Write(w, tasks)
+	case "import":
+		var r io.Reader = os.Stdin
+		if flags.NArg() == 1 {
+			f, err := os.Open(flags.Arg(0))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		items, err := todotxt.Parse(r)
+		if err != nil {
+			return err
+		}
+		report, cErr := svc.Import(userID, items, *dry)
+		if cErr != nil {
+			return cErr
+		}
+		printReport(report)
+	default:
+		return errors.New(todoTxtUsage)
+	}
+	return nil
+}
+
+// printReport выводит записи, не вошедшие в импорт, и итог
+func printReport(report *domain.ImportReport) {
+	for _, item := range report.Items {
+		if item.Status != domain.ImportCreated {
+			fmt.Printf("%s (%s): %s — %s\n", item.Ref, item.Title, item.Status, item.Error)
+		}
+	}
+	if report.DryRun {
+		fmt.Print("Пробный импорт: ")
+	}
+	fmt.Printf("создано %d, пропущено %d, с ошибками %d\n", report.Created, report.Skipped, report.Failed)
+}
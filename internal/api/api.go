@@ -6,53 +6,144 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/events"
+	"github.com/agidelle/todo_web/internal/query"
 	"github.com/agidelle/todo_web/internal/service"
+	"github.com/agidelle/todo_web/internal/webhook"
 )
 
 var errorMap = map[error]int{
 	domain.ErrID:             http.StatusBadRequest,
 	domain.ErrBadTitle:       http.StatusBadRequest,
 	domain.ErrDate:           http.StatusBadRequest,
+	domain.ErrRepeat:         http.StatusBadRequest,
+	domain.ErrPriority:       http.StatusBadRequest,
+	domain.ErrTag:            http.StatusBadRequest,
+	domain.ErrTime:           http.StatusBadRequest,
+	domain.ErrTimezone:       http.StatusBadRequest,
+	domain.ErrNotFound:       http.StatusBadRequest,
+	domain.ErrTaskDone:       http.StatusBadRequest,
+	domain.ErrStatus:         http.StatusBadRequest,
+	domain.ErrPatch:          http.StatusBadRequest,
+	domain.ErrConflict:       http.StatusPreconditionFailed,
+	domain.ErrSort:           http.StatusBadRequest,
+	domain.ErrCursor:         http.StatusBadRequest,
+	domain.ErrLimit:          http.StatusBadRequest,
+	domain.ErrQuery:          http.StatusBadRequest,
+	domain.ErrAgendaRange:    http.StatusBadRequest,
+	domain.ErrFilterName:     http.StatusBadRequest,
+	domain.ErrFilterExists:   http.StatusConflict,
+	domain.ErrFilterNotFound: http.StatusNotFound,
+	domain.ErrFilterBuiltIn:  http.StatusForbidden,
+	domain.ErrWebhookURL:     http.StatusBadRequest,
+	domain.ErrWebhookHost:    http.StatusBadRequest,
+	domain.ErrWebhookEvent:   http.StatusBadRequest,
+	domain.ErrLogin:          http.StatusBadRequest,
+	domain.ErrPassword:       http.StatusBadRequest,
+	domain.ErrCredentials:    http.StatusUnauthorized,
+	domain.ErrUserExists:     http.StatusConflict,
+	domain.ErrUnauthorized:   http.StatusUnauthorized,
+	domain.ErrForbidden:      http.StatusForbidden,
 	domain.ErrInternalServer: http.StatusInternalServerError,
 }
 
 type TaskHandler struct {
-	service *service.TaskService
+	service  *service.TaskService
+	users    *service.UserService
+	webhooks *webhook.Service
+	events   *events.Broker
+	filters  *service.FilterService
 }
 
-func NewHandler(service *service.TaskService) *TaskHandler {
-	return &TaskHandler{service: service}
+func NewHandler(service *service.TaskService, users *service.UserService, webhooks *webhook.Service, broker *events.Broker,
+	filters *service.FilterService) *TaskHandler {
+	return &TaskHandler{service: service, users: users, webhooks: webhooks, events: broker, filters: filters}
 }
 
 func sendJSONError(w http.ResponseWriter, customErr *domain.CustomError) {
 	w.WriteHeader(customErr.Code)
-	var errorMessage string
-	if customErr.ErrStorage != nil {
-		errorMessage = fmt.Sprintf("%v: %v", customErr.Err, customErr.ErrStorage)
-	} else {
-		errorMessage = fmt.Sprintf("%v", customErr.Err)
-	}
-
 	err := json.NewEncoder(w).Encode(struct {
-		Error string `json:"error"`
+		Error    string `json:"error"`
+		Position int    `json:"position,omitempty"`
 	}{
-		Error: errorMessage,
+		Error:    customErr.Error(),
+		Position: queryPosition(customErr),
 	})
 	if err != nil {
 		log.Println(err)
 	}
 }
 
-func sendJSONTasks(w http.ResponseWriter, tasks []*domain.Task) {
+// queryPosition возвращает позицию ошибки в запросе поиска, 0 — ошибка не в запросе
+func queryPosition(cErr *domain.CustomError) int {
+	var qErr *query.Error
+	if errors.As(cErr.ErrStorage, &qErr) {
+		return qErr.Pos
+	}
+	return 0
+}
+
+// sendMappedError подбирает HTTP-статус ошибки сервиса по errorMap
+func sendMappedError(w http.ResponseWriter, cErr *domain.CustomError) {
+	if code, ok := errorMap[cErr.Err]; ok {
+		cErr.Code = code
+	} else {
+		cErr.Code = http.StatusInternalServerError
+	}
+	sendJSONError(w, cErr)
+}
+
+// setETag передаёт версию задачи в заголовке ETag
+func setETag(w http.ResponseWriter, task *domain.Task) {
+	if task.Version > 0 {
+		w.Header().Set("ETag", `"`+strconv.FormatInt(task.Version, 10)+`"`)
+	}
+}
+
+// ifMatch возвращает версию задачи из заголовка If-Match, 0 — заголовка нет или указан «*».
+// Значение, которое не может совпасть с ETag задачи, означает конфликт версий
+func ifMatch(r *http.Request) (int64, *domain.CustomError) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+	version, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(value, `"`), `"`), 10, 64)
+	if err != nil || version <= 0 || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		return 0, domain.NewCustomError(0, domain.ErrConflict, nil)
+	}
+	return version, nil
+}
+
+// sendJSONPage отправляет страницу задач, общее число задач всегда передаётся в X-Total-Count.
+// Если full = false, в теле только список задач
+func sendJSONPage(w http.ResponseWriter, page *domain.TaskPage, full bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	var body any = page
+	if !full {
+		body = struct {
+			Tasks []*domain.Task `json:"tasks"`
+		}{
+			Tasks: page.Tasks,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Println(err)
+	}
+}
+
+func sendJSONCompletions(w http.ResponseWriter, completions []*domain.Completion) {
 	w.Header().Set("Content-Type", "application/json")
 	err := json.NewEncoder(w).Encode(struct {
-		Tasks []*domain.Task `json:"tasks"`
+		Completions []*domain.Completion `json:"completions"`
 	}{
-		Tasks: tasks,
+		Completions: completions,
 	})
 	if err != nil {
 		log.Println(err)
@@ -68,6 +159,7 @@ func (h *TaskHandler) AddTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//Добавление задачи
+	task.UserID = userID(r)
 	id, cErr := h.service.Create(&task)
 	if cErr != nil {
 		if code, ok := errorMap[cErr.Err]; ok {
@@ -89,42 +181,76 @@ func (h *TaskHandler) AddTask(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *TaskHandler) GetTasks(w http.ResponseWriter, r *http.Request) {
-	var filter domain.Filter
+	filter := domain.Filter{UserID: userID(r)}
 	queryValues := r.URL.Query()
 	_, searchParamExists := queryValues["search"]
 
 	filter.SearchTerm = r.URL.Query().Get("search")
-
-	if !searchParamExists {
-		res, cErr := h.service.GetTasks(&filter)
-		if cErr != nil {
-			if code, ok := errorMap[cErr.Err]; ok {
-				cErr.Code = code
-			} else {
-				cErr.Code = http.StatusInternalServerError
-			}
-			sendJSONError(w, cErr)
+	filter.Project = queryValues.Get("project")
+	filter.Tags = queryValues["tag"]
+	if priority := queryValues.Get("priority"); priority != "" {
+		p, err := strconv.Atoi(priority)
+		if err != nil {
+			sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, domain.ErrPriority, err))
 			return
 		}
-		sendJSONTasks(w, res)
+		filter.Priority = p
+	}
+	paged, cErr := pageFilter(queryValues, &filter)
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+
+	var res *domain.TaskPage
+	if !searchParamExists {
+		res, cErr = h.service.GetTasks(&filter)
 	} else {
-		res, cErr := h.service.Search(&filter)
-		if cErr != nil {
-			if code, ok := errorMap[cErr.Err]; ok {
-				cErr.Code = code
-			} else {
-				cErr.Code = http.StatusInternalServerError
-			}
-			sendJSONError(w, cErr)
-			return
+		res, cErr = h.service.Search(&filter)
+	}
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	//Без параметров страницы ответ остаётся прежним, общее число есть только в заголовке
+	sendJSONPage(w, res, paged)
+}
+
+// Параметры страницы списка задач
+var pageParams = []string{"from", "to", "sort", "order", "limit", "cursor"}
+
+// pageFilter читает параметры страницы списка: from и to в формате 20060102,
+// sort, order=asc|desc, limit и cursor из next_cursor предыдущей страницы.
+// Возвращает true, если указан хотя бы один из них
+func pageFilter(query url.Values, filter *domain.Filter) (bool, *domain.CustomError) {
+	paged := false
+	for _, param := range pageParams {
+		paged = paged || query.Has(param)
+	}
+	filter.From = query.Get("from")
+	filter.To = query.Get("to")
+	filter.Sort = query.Get("sort")
+	filter.Cursor = query.Get("cursor")
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return paged, domain.NewCustomError(0, domain.ErrSort, nil)
+	}
+	if limit := query.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l <= 0 {
+			return paged, domain.NewCustomError(0, domain.ErrLimit, err)
 		}
-		sendJSONTasks(w, res)
+		filter.Limit = l
 	}
+	return paged, nil
 }
 
 // Для использования FindAll нужна маленькая корректировка
 func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
-	var filter domain.Filter
+	filter := domain.Filter{UserID: userID(r)}
 	searchID := r.URL.Query().Get("id")
 	if searchID == "" {
 		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, domain.ErrID, nil))
@@ -148,6 +274,7 @@ func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
 	}
 	//Корректировка: task[0].ID = searchID, проверка на len есть в FindAll
 	task.ID = searchID
+	setETag(w, task)
 	err = json.NewEncoder(w).Encode(&task)
 	if err != nil {
 		log.Printf("Error writing response: %v", err)
@@ -161,7 +288,14 @@ func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, errors.New("ошибка десериализации JSON"), err))
 		return
 	}
-	cErr := h.service.Update(&task)
+	task.UserID = userID(r)
+	version, cErr := ifMatch(r)
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	task.Version = version
+	cErr = h.service.Update(&task)
 	if cErr != nil {
 		if code, ok := errorMap[cErr.Err]; ok {
 			cErr.Code = code
@@ -171,14 +305,58 @@ func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 		sendJSONError(w, cErr)
 		return
 	}
+	setETag(w, &task)
 	err := json.NewEncoder(w).Encode(domain.Task{})
 	if err != nil {
 		log.Printf("Error writing response: %v", err)
 	}
 }
 
+// PatchTask — частичное изменение задачи ?id= патчем JSON Merge Patch (RFC 7396).
+// Отвечает задачей после изменения
+func (h *TaskHandler) PatchTask(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	searchID := r.URL.Query().Get("id")
+	id, err := strconv.Atoi(searchID)
+	if err != nil {
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, domain.ErrID, err))
+		return
+	}
+	version, cErr := ifMatch(r)
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	patch, cErr := decodePatch(r)
+	if cErr != nil {
+		sendJSONError(w, cErr)
+		return
+	}
+	task, cErr := h.service.Patch(&domain.Filter{ID: &id, UserID: userID(r), Version: version}, patch)
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	setETag(w, task)
+	if err = json.NewEncoder(w).Encode(task); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// decodePatch читает патч задачи, им может быть только JSON-объект
+func decodePatch(r *http.Request) (map[string]any, *domain.CustomError) {
+	var patch map[string]any
+	dec := json.NewDecoder(r.Body)
+	//Числа остаются в исходной записи до разбора в поля задачи
+	dec.UseNumber()
+	if err := dec.Decode(&patch); err != nil || patch == nil {
+		return nil, domain.NewCustomError(http.StatusBadRequest, errors.New("патч должен быть JSON-объектом"), err)
+	}
+	return patch, nil
+}
+
 func (h *TaskHandler) Done(w http.ResponseWriter, r *http.Request) {
-	var filter domain.Filter
+	filter := domain.Filter{UserID: userID(r)}
 	searchID := r.URL.Query().Get("id")
 	if searchID == "" {
 		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, domain.ErrID, nil))
@@ -190,7 +368,13 @@ func (h *TaskHandler) Done(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	filter.ID = &id
-	cErr := h.service.Done(&filter)
+	version, cErr := ifMatch(r)
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	filter.Version = version
+	cErr = h.service.Done(&filter)
 	if cErr != nil {
 		if code, ok := errorMap[cErr.Err]; ok {
 			cErr.Code = code
@@ -218,7 +402,12 @@ func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
 		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, domain.ErrID, err))
 		return
 	}
-	cErr := h.service.Delete(id)
+	version, cErr := ifMatch(r)
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	cErr = h.service.Delete(&domain.Filter{ID: &id, UserID: userID(r), Version: version})
 	if cErr != nil {
 		if code, ok := errorMap[cErr.Err]; ok {
 			cErr.Code = code
@@ -236,14 +425,78 @@ func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (h *TaskHandler) TaskHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	searchID := r.URL.Query().Get("id")
+	if searchID == "" {
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, domain.ErrID, nil))
+		return
+	}
+	id, err := strconv.Atoi(searchID)
+	if err != nil {
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, domain.ErrID, err))
+		return
+	}
+	res, cErr := h.service.History(userID(r), id)
+	if cErr != nil {
+		if code, ok := errorMap[cErr.Err]; ok {
+			cErr.Code = code
+		} else {
+			cErr.Code = http.StatusInternalServerError
+		}
+		sendJSONError(w, cErr)
+		return
+	}
+	sendJSONCompletions(w, res)
+}
+
+func (h *TaskHandler) Completed(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	res, cErr := h.service.Completed(userID(r), r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if cErr != nil {
+		if code, ok := errorMap[cErr.Err]; ok {
+			cErr.Code = code
+		} else {
+			cErr.Code = http.StatusInternalServerError
+		}
+		sendJSONError(w, cErr)
+		return
+	}
+	sendJSONCompletions(w, res)
+}
+
+// Agenda возвращает задачи по дням периода from–to с повторениями повторяющихся задач
+func (h *TaskHandler) Agenda(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	agenda, cErr := h.service.Agenda(userID(r), r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(agenda); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
 func (h *TaskHandler) NextDateHandler(w http.ResponseWriter, r *http.Request) {
 	nowStr := r.URL.Query().Get("now")
 	dateStr := r.URL.Query().Get("date")
 	repeat := r.URL.Query().Get("repeat")
 
+	//Без now сегодняшняя дата берётся в поясе tz, по умолчанию в поясе сервера
+	loc := time.Local
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil || tz == "Local" {
+			http.Error(w, domain.ErrTimezone.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	var now time.Time
 	if nowStr == "" {
-		now = time.Now()
+		now = time.Now().In(loc)
 	} else {
 		var err error
 		now, err = time.Parse("20060102", nowStr)
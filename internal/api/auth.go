@@ -1,72 +1,233 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/agidelle/todo_web/internal/domain"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/service"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func (h *TaskHandler) Login(passStored, jwtkey string) http.HandlerFunc {
+type ctxKey int
+
+const userKey ctxKey = iota
+
+type credentials struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	IsAdmin  bool   `json:"is_admin"`
+}
+
+// userID возвращает id пользователя из запроса, 0 — аутентификация отключена
+func userID(r *http.Request) int64 {
+	id, _ := r.Context().Value(userKey).(int64)
+	return id
+}
+
+func (h *TaskHandler) Login(jwtkey string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var password struct {
-			Password string `json:"password"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&password); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		var creds credentials
+		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
 			sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, errors.New("ошибка десериализации JSON"), nil))
 			return
 		}
-		hash, err := hashPassword(password.Password)
-		if err != nil {
-			sendJSONError(w, domain.NewCustomError(http.StatusInternalServerError, errors.New("ошибка создания хэша пароля"), nil))
-			return
+		//Веб-интерфейс отправляет только пароль, это вход администратора
+		if creds.Login == "" {
+			creds.Login = service.AdminLogin
 		}
-		if password.Password != passStored {
-			sendJSONError(w, domain.NewCustomError(http.StatusUnauthorized, errors.New("не правильный пароль"), nil))
+		user, cErr := h.users.Authenticate(creds.Login, creds.Password)
+		if cErr != nil {
+			sendMappedError(w, cErr)
 			return
 		}
-		if err != nil {
-			sendJSONError(w, domain.NewCustomError(http.StatusUnauthorized, errors.New("неправильный пароль"), nil))
+		h.sendToken(w, jwtkey, user, http.StatusOK)
+	}
+}
+
+// Signup регистрирует нового пользователя и сразу выдаёт ему токен
+func (h *TaskHandler) Signup(jwtkey string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var creds credentials
+		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+			sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, errors.New("ошибка десериализации JSON"), nil))
 			return
 		}
-		token, err := GenerateJWT(jwtkey)
-		err = json.NewEncoder(w).Encode(map[string]string{"token": token, "hash": hash})
-		if err != nil {
-			log.Printf("Error writing response: %v", err)
+		user, cErr := h.users.Register(creds.Login, creds.Password, false)
+		if cErr != nil {
+			sendMappedError(w, cErr)
+			return
 		}
+		h.sendToken(w, jwtkey, user, http.StatusCreated)
+	}
+}
+
+func (h *TaskHandler) sendToken(w http.ResponseWriter, jwtkey string, user *domain.User, status int) {
+	token, err := GenerateJWT(jwtkey, user.ID)
+	if err != nil {
+		sendJSONError(w, domain.NewCustomError(http.StatusInternalServerError, domain.ErrInternalServer, err))
+		return
+	}
+	w.WriteHeader(status)
+	err = json.NewEncoder(w).Encode(map[string]string{"token": token})
+	if err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// CreateUser — создание учётной записи администратором
+func (h *TaskHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var creds credentials
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, errors.New("ошибка десериализации JSON"), nil))
+		return
+	}
+	user, cErr := h.users.Register(creds.Login, creds.Password, creds.IsAdmin)
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// GetMe возвращает учётную запись текущего пользователя
+func (h *TaskHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, cErr := h.users.Get(userID(r))
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// UpdateMe изменяет настройки текущего пользователя, сейчас это часовой пояс
+func (h *TaskHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var settings struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, errors.New("ошибка десериализации JSON"), nil))
+		return
+	}
+	user, cErr := h.users.SetTimezone(userID(r), settings.Timezone)
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		log.Printf("Error writing response: %v", err)
 	}
 }
 
-func (h *TaskHandler) JWTMiddleware(pass, secretKey string) func(next http.Handler) http.Handler {
+func (h *TaskHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	users, cErr := h.users.List()
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	err := json.NewEncoder(w).Encode(struct {
+		Users []*domain.User `json:"users"`
+	}{
+		Users: users,
+	})
+	if err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// JWTMiddleware проверяет токен из cookie token или заголовка Authorization: Bearer
+// и сохраняет id пользователя из claim sub в контексте запроса
+func (h *TaskHandler) JWTMiddleware(secretKey string) func(next http.Handler) http.Handler {
+	return authenticate(secretKey, func(w http.ResponseWriter, _ *http.Request) {
+		sendJSONError(w, domain.NewCustomError(http.StatusUnauthorized, domain.ErrUnauthorized, nil))
+	})
+}
+
+// authenticate пропускает запросы с действительным токеном, на остальные отвечает unauthorized
+func authenticate(secretKey string, unauthorized http.HandlerFunc) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			cookie, err := r.Cookie("token")
-			if err != nil {
-				return
-			}
-			token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("неправильный метод шифрования token: %v", token.Header["alg"])
-				}
-				return []byte(secretKey), nil
-			})
-			if err != nil || !token.Valid {
-				http.Error(w, "не авторизован.", http.StatusUnauthorized)
+			id, ok := ParseToken(secretKey, bearerToken(r))
+			if !ok {
+				unauthorized(w, r)
 				return
 			}
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey, id)))
 		})
 	}
 }
 
-func GenerateJWT(jwtkey string) (string, error) {
+// ParseToken проверяет подпись токена и возвращает id пользователя из claim sub.
+// Используется также gRPC-сервером
+func ParseToken(secretKey, raw string) (int64, bool) {
+	if raw == "" {
+		return 0, false
+	}
+	token, err := jwt.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("неправильный метод шифрования token: %v", token.Header["alg"])
+		}
+		return []byte(secretKey), nil
+	})
+	if err != nil || !token.Valid {
+		return 0, false
+	}
+	//Токены без sub выданы до появления учётных записей и не принимаются
+	sub, err := token.Claims.GetSubject()
+	id, errID := strconv.ParseInt(sub, 10, 64)
+	if err != nil || errID != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// AdminOnly пропускает только запросы администраторов
+func (h *TaskHandler) AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, cErr := h.users.Get(userID(r))
+		if cErr != nil {
+			sendMappedError(w, cErr)
+			return
+		}
+		if !user.IsAdmin {
+			sendJSONError(w, domain.NewCustomError(http.StatusForbidden, domain.ErrForbidden, nil))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	if cookie, err := r.Cookie("token"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+func GenerateJWT(jwtkey string, userID int64) (string, error) {
 	claims := jwt.MapClaims{
+		"sub": strconv.FormatInt(userID, 10),
 		"exp": time.Now().Add(24 * time.Hour).Unix(),
 		"iat": time.Now().Unix(),
 	}
@@ -83,17 +244,3 @@ func GenerateJWT(jwtkey string) (string, error) {
 
 	return tokenSign, nil
 }
-
-func hashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hashedPassword), nil
-}
-
-// Пример функции для сравнения с хэшем хранящимся в БД для аутентификации
-// неприменимо в данном случае, так как хранение хэша в токене небезопасно, взято из пета
-func checkPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-}
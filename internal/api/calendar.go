@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/ical"
+)
+
+const calendarName = "Планировщик задач"
+
+// Calendar отдаёт активные задачи владельца токена в формате iCalendar.
+// Календарные клиенты не передают cookie, поэтому доступ проверяется по параметру token.
+// Параметр type=todo выдаёт задачи как VTODO вместо событий VEVENT
+func (h *TaskHandler) Calendar(w http.ResponseWriter, r *http.Request) {
+	userID, cErr := h.users.FeedUser(r.URL.Query().Get("token"))
+	if cErr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		sendMappedError(w, cErr)
+		return
+	}
+	kind := r.URL.Query().Get("type")
+	switch kind {
+	case "":
+		kind = ical.KindEvent
+	case ical.KindEvent, ical.KindTodo:
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, errors.New("тип календаря должен быть event или todo"), nil))
+		return
+	}
+	tasks, cErr := h.service.CalendarTasks(userID)
+	if cErr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		sendMappedError(w, cErr)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+	err := ical.Write(w, tasks, ical.Options{Name: calendarName, Kind: kind, Now: time.Now()})
+	if err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// IssueFeedToken выпускает новый токен подписки на календарь и возвращает адрес подписки
+func (h *TaskHandler) IssueFeedToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	token, cErr := h.users.IssueFeedToken(userID(r))
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	w.WriteHeader(http.StatusCreated)
+	err := json.NewEncoder(w).Encode(map[string]string{
+		"token": token,
+		"url":   scheme + "://" + r.Host + "/api/calendar.ics?token=" + token,
+	})
+	if err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// RevokeFeedToken отзывает токен подписки на календарь
+func (h *TaskHandler) RevokeFeedToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if cErr := h.users.RevokeFeedToken(userID(r)); cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// ImportICS создаёт задачи из компонентов VTODO и VEVENT календаря одной транзакцией
+// и возвращает отчёт по каждой записи
+func (h *TaskHandler) ImportICS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	body, _, cErr := importBody(w, r)
+	if cErr != nil {
+		sendJSONError(w, cErr)
+		return
+	}
+	defer body.Close()
+	items, err := ical.Parse(body)
+	if err != nil {
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, err, nil))
+		return
+	}
+	report, cErr := h.service.Import(userID(r), items, dryRun(r))
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	if err = json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/events"
+)
+
+const (
+	//Комментарий-пинг не даёт прокси закрыть простаивающее соединение
+	eventsHeartbeat = 25 * time.Second
+	//Пауза перед переподключением EventSource, мс
+	eventsRetry = 3000
+)
+
+// Events передаёт изменения задач пользователя потоком Server-Sent Events.
+// Номер последнего полученного события берётся из заголовка Last-Event-ID,
+// который EventSource отправляет при переподключении, или из параметра last_event_id
+func (h *TaskHandler) Events(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	//Поток открыт дольше WriteTimeout сервера
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		sendJSONError(w, domain.NewCustomError(http.StatusInternalServerError, domain.ErrInternalServer, err))
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var after uint64
+	resume := lastID != ""
+	if resume {
+		var err error
+		//Чужой номер приводит к событию reset
+		if after, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			after = math.MaxUint64
+		}
+	}
+	sub, missed := h.events.Subscribe(userID(r), after, resume)
+	if sub == nil {
+		w.Header().Set("Content-Type", "application/json")
+		sendJSONError(w, domain.NewCustomError(http.StatusServiceUnavailable, errors.New("сервер останавливается"), nil))
+		return
+	}
+	defer h.events.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", eventsRetry); err != nil {
+		return
+	}
+	for _, e := range missed {
+		if writeEvent(w, e) != nil {
+			return
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			err = writeEvent(w, e)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		}
+		if err != nil || rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// writeEvent записывает событие в формате text/event-stream, тип события — его поле event
+func writeEvent(w http.ResponseWriter, e events.Event) error {
+	data, err := json.Marshal(e.TaskEvent)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+type filterRequest struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	Sort  string `json:"sort"`
+	Order string `json:"order"`
+}
+
+func (req *filterRequest) decode(w http.ResponseWriter, r *http.Request) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, errors.New("ошибка десериализации JSON"), err))
+		return false
+	}
+	return true
+}
+
+func (h *TaskHandler) ListFilters(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	filters, cErr := h.filters.List(userID(r))
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	err := json.NewEncoder(w).Encode(struct {
+		Filters []*domain.SavedFilter `json:"filters"`
+	}{
+		Filters: filters,
+	})
+	if err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func (h *TaskHandler) AddFilter(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req filterRequest
+	if !req.decode(w, r) {
+		return
+	}
+	filter, cErr := h.filters.Create(&domain.SavedFilter{UserID: userID(r), Name: req.Name, Query: req.Query, Sort: req.Sort, Order: req.Order})
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(filter); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func (h *TaskHandler) GetFilter(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	filter, cErr := h.filters.Get(userID(r), chi.URLParam(r, "id"))
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(filter); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func (h *TaskHandler) UpdateFilter(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req filterRequest
+	if !req.decode(w, r) {
+		return
+	}
+	filter, cErr := h.filters.Update(&domain.SavedFilter{ID: chi.URLParam(r, "id"), UserID: userID(r),
+		Name: req.Name, Query: req.Query, Sort: req.Sort, Order: req.Order})
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(filter); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func (h *TaskHandler) DeleteFilter(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if cErr := h.filters.Delete(userID(r), chi.URLParam(r, "id")); cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// FilterTasks возвращает страницу задач фильтра с параметрами страницы, как у GET /api/tasks
+func (h *TaskHandler) FilterTasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var page domain.Filter
+	if _, cErr := pageFilter(r.URL.Query(), &page); cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	res, cErr := h.filters.Tasks(userID(r), chi.URLParam(r, "id"), &page)
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	sendJSONPage(w, res, true)
+}
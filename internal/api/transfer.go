@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/taskfile"
+	"github.com/agidelle/todo_web/internal/todotxt"
+)
+
+// Максимальный размер импортируемого файла
+const maxImportSize = 10 << 20
+
+// importBody возвращает импортируемый файл и его имя: поле file формы multipart/form-data или тело запроса
+func importBody(w http.ResponseWriter, r *http.Request) (io.ReadCloser, string, *domain.CustomError) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.Body, "", nil
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, "", domain.NewCustomError(http.StatusBadRequest, errors.New("не передан файл в поле file"), err)
+	}
+	return file, header.Filename, nil
+}
+
+// dryRun сообщает, запрошен ли пробный импорт без сохранения задач
+func dryRun(r *http.Request) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	return v
+}
+
+// importFormat определяет формат импорта по параметру format, расширению файла или Content-Type
+func importFormat(r *http.Request, filename string) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	if ext := strings.ToLower(path.Ext(filename)); ext != "" {
+		if ext == ".txt" {
+			return todotxt.Format
+		}
+		return ext[1:]
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return taskfile.FormatCSV
+	case "application/json":
+		return taskfile.FormatJSON
+	case "text/plain":
+		return todotxt.Format
+	}
+	return ""
+}
+
+func unknownFormat(format string) *domain.CustomError {
+	return domain.NewCustomError(http.StatusBadRequest, taskfile.ErrFormat,
+		fmt.Errorf("формат %q не поддерживается, ожидается csv, json или todotxt", format))
+}
+
+// Export выгружает все задачи пользователя с метаданными в CSV, JSON (по умолчанию) или todo.txt
+func (h *TaskHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = taskfile.FormatJSON
+	}
+	var write func(io.Writer, []*domain.Task) error
+	filename := "tasks." + format
+	switch format {
+	case taskfile.FormatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		write = taskfile.WriteCSV
+	case taskfile.FormatJSON:
+		w.Header().Set("Content-Type", "application/json")
+		write = taskfile.WriteJSON
+	case todotxt.Format:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		write = todotxt.Write
+		filename = "todo.txt"
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		sendJSONError(w, unknownFormat(format))
+		return
+	}
+	tasks, cErr := h.service.Export(userID(r))
+	if cErr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		sendMappedError(w, cErr)
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if err := write(w, tasks); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// Import создаёт задачи из файла CSV, JSON или todo.txt в формате выгрузки и возвращает отчёт по каждой записи.
+// С dry_run=true задачи только проверяются
+func (h *TaskHandler) Import(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	body, filename, cErr := importBody(w, r)
+	if cErr != nil {
+		sendJSONError(w, cErr)
+		return
+	}
+	defer body.Close()
+	var items []*domain.ImportItem
+	var err error
+	switch format := importFormat(r, filename); format {
+	case taskfile.FormatCSV:
+		items, err = taskfile.ReadCSV(body)
+	case taskfile.FormatJSON:
+		items, err = taskfile.ReadJSON(body)
+	case todotxt.Format:
+		items, err = todotxt.Parse(body)
+	default:
+		sendJSONError(w, unknownFormat(format))
+		return
+	}
+	if err != nil {
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, err, nil))
+		return
+	}
+	report, cErr := h.service.Import(userID(r), items, dryRun(r))
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	if err = json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
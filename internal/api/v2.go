@@ -0,0 +1,335 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+// V2Prefix — адрес REST API v2. v1 остаётся для веб-интерфейса
+const V2Prefix = "/api/v2"
+
+const problemType = "application/problem+json"
+
+// v2Status сопоставляет ошибки сервиса статусам v2: запрос понятен, но задача не прошла
+// проверку — 422, задачи нет — 404, задача уже выполнена — 409
+var v2Status = map[error]int{
+	domain.ErrID:             http.StatusBadRequest,
+	domain.ErrNotFound:       http.StatusNotFound,
+	domain.ErrTaskDone:       http.StatusConflict,
+	domain.ErrBadTitle:       http.StatusUnprocessableEntity,
+	domain.ErrDate:           http.StatusUnprocessableEntity,
+	domain.ErrRepeat:         http.StatusUnprocessableEntity,
+	domain.ErrPriority:       http.StatusUnprocessableEntity,
+	domain.ErrTag:            http.StatusUnprocessableEntity,
+	domain.ErrTime:           http.StatusUnprocessableEntity,
+	domain.ErrTimezone:       http.StatusUnprocessableEntity,
+	domain.ErrStatus:         http.StatusUnprocessableEntity,
+	domain.ErrPatch:          http.StatusUnprocessableEntity,
+	domain.ErrConflict:       http.StatusPreconditionFailed,
+	domain.ErrSort:           http.StatusBadRequest,
+	domain.ErrCursor:         http.StatusBadRequest,
+	domain.ErrLimit:          http.StatusBadRequest,
+	domain.ErrQuery:          http.StatusBadRequest,
+	domain.ErrUnauthorized:   http.StatusUnauthorized,
+	domain.ErrForbidden:      http.StatusForbidden,
+	domain.ErrInternalServer: http.StatusInternalServerError,
+}
+
+var errJSON = errors.New("ошибка десериализации JSON")
+
+// problem — описание ошибки по RFC 7807
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	//Расширение: позиция ошибки в запросе поиска
+	Position int `json:"position,omitempty"`
+}
+
+// sendProblem отправляет ошибку в формате application/problem+json.
+// Ошибки сервиса с нулевым кодом сопоставляются по v2Status
+func sendProblem(w http.ResponseWriter, r *http.Request, cErr *domain.CustomError) {
+	if cErr.Code == 0 {
+		if code, ok := v2Status[cErr.Err]; ok {
+			cErr.Code = code
+		} else {
+			cErr.Code = http.StatusInternalServerError
+		}
+	}
+	detail := cErr.Error()
+	//Подробности внутренних ошибок остаются в журнале сервера
+	if cErr.Code == http.StatusInternalServerError {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, detail)
+		detail = domain.ErrInternalServer.Error()
+	}
+	if cErr.Code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	w.Header().Set("Content-Type", problemType)
+	w.WriteHeader(cErr.Code)
+	err := json.NewEncoder(w).Encode(problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(cErr.Code),
+		Status:   cErr.Code,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Position: queryPosition(cErr),
+	})
+	if err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func sendV2JSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// Типы тела запроса v2, патчи могут передаваться как application/merge-patch+json
+var bodyTypes = map[string]bool{"application/json": true, "application/merge-patch+json": true}
+
+// Negotiate проверяет заголовки запроса v2: ответ отдаётся только в JSON (406, если клиент
+// его не принимает), тело запроса принимается только в JSON (415)
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsJSON(r.Header.Values("Accept")) {
+			sendProblem(w, r, domain.NewCustomError(http.StatusNotAcceptable,
+				errors.New("ответ доступен только в application/json"), nil))
+			return
+		}
+		if r.ContentLength != 0 {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || !bodyTypes[mediaType] {
+				sendProblem(w, r, domain.NewCustomError(http.StatusUnsupportedMediaType,
+					errors.New("тело запроса должно быть в application/json"), nil))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acceptsJSON сообщает, допускает ли заголовок Accept ответ application/json.
+// Без заголовка подходит любой тип, диапазоны с q=0 исключают тип
+func acceptsJSON(values []string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+				continue
+			}
+			switch mediaType {
+			case "application/json", "application/*", "*/*":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// JWTMiddlewareV2 проверяет токен так же, как JWTMiddleware, и отвечает 401 в формате problem+json
+func (h *TaskHandler) JWTMiddlewareV2(secretKey string) func(next http.Handler) http.Handler {
+	return authenticate(secretKey, func(w http.ResponseWriter, r *http.Request) {
+		sendProblem(w, r, domain.NewCustomError(http.StatusUnauthorized, domain.ErrUnauthorized, nil))
+	})
+}
+
+// NotFoundV2 и MethodNotAllowedV2 отвечают на неизвестные адреса и методы v2 в формате problem+json
+func NotFoundV2(w http.ResponseWriter, r *http.Request) {
+	sendProblem(w, r, domain.NewCustomError(http.StatusNotFound, errors.New("ресурс не найден"), nil))
+}
+
+func MethodNotAllowedV2(w http.ResponseWriter, r *http.Request) {
+	sendProblem(w, r, domain.NewCustomError(http.StatusMethodNotAllowed, errors.New("метод не поддерживается"), nil))
+}
+
+// taskID возвращает id задачи из адреса, некорректный id означает отсутствующую задачу
+func taskID(r *http.Request) (int, *domain.CustomError) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		return 0, domain.NewCustomError(0, domain.ErrNotFound, nil)
+	}
+	return id, nil
+}
+
+// taskFilter возвращает фильтр изменяемой задачи {id} с ожидаемой версией из If-Match
+func taskFilter(r *http.Request) (*domain.Filter, *domain.CustomError) {
+	id, cErr := taskID(r)
+	if cErr != nil {
+		return nil, cErr
+	}
+	version, cErr := ifMatch(r)
+	if cErr != nil {
+		return nil, cErr
+	}
+	return &domain.Filter{ID: &id, UserID: userID(r), Version: version}, nil
+}
+
+// sendTaskV2 отправляет текущее состояние задачи, в том числе выполненной
+func (h *TaskHandler) sendTaskV2(w http.ResponseWriter, r *http.Request, id int, status int) {
+	task, cErr := h.service.GetTask(&domain.Filter{ID: &id, UserID: userID(r), Status: domain.StatusAll})
+	if cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	task.ID = strconv.Itoa(id)
+	setETag(w, task)
+	sendV2JSON(w, status, task)
+}
+
+// ListTasksV2 — GET /api/v2/tasks. Параметры: search, project, tag (несколько), priority
+// и status (active по умолчанию, done или all)
+func (h *TaskHandler) ListTasksV2(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := domain.Filter{
+		UserID:     userID(r),
+		SearchTerm: query.Get("search"),
+		Project:    query.Get("project"),
+		Tags:       query["tag"],
+		Status:     query.Get("status"),
+	}
+	if priority := query.Get("priority"); priority != "" {
+		p, err := strconv.Atoi(priority)
+		if err != nil {
+			sendProblem(w, r, domain.NewCustomError(http.StatusBadRequest, domain.ErrPriority, err))
+			return
+		}
+		filter.Priority = p
+	}
+	if _, cErr := pageFilter(query, &filter); cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	var page *domain.TaskPage
+	var cErr *domain.CustomError
+	if query.Has("search") {
+		page, cErr = h.service.Search(&filter)
+	} else {
+		page, cErr = h.service.GetTasks(&filter)
+	}
+	if cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	sendV2JSON(w, http.StatusOK, page)
+}
+
+// CreateTaskV2 — POST /api/v2/tasks. Отвечает 201 с задачей и её адресом в Location
+func (h *TaskHandler) CreateTaskV2(w http.ResponseWriter, r *http.Request) {
+	var task domain.Task
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		sendProblem(w, r, domain.NewCustomError(http.StatusBadRequest, errJSON, err))
+		return
+	}
+	task.ID = ""
+	task.UserID = userID(r)
+	id, cErr := h.service.Create(&task)
+	if cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	w.Header().Set("Location", V2Prefix+"/tasks/"+strconv.FormatInt(id, 10))
+	h.sendTaskV2(w, r, int(id), http.StatusCreated)
+}
+
+// GetTaskV2 — GET /api/v2/tasks/{id}, задача в любом состоянии
+func (h *TaskHandler) GetTaskV2(w http.ResponseWriter, r *http.Request) {
+	id, cErr := taskID(r)
+	if cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	h.sendTaskV2(w, r, id, http.StatusOK)
+}
+
+// UpdateTaskV2 — PUT /api/v2/tasks/{id}, полная замена полей задачи. id в теле не учитывается
+func (h *TaskHandler) UpdateTaskV2(w http.ResponseWriter, r *http.Request) {
+	filter, cErr := taskFilter(r)
+	if cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	var task domain.Task
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		sendProblem(w, r, domain.NewCustomError(http.StatusBadRequest, errJSON, err))
+		return
+	}
+	task.ID = strconv.Itoa(*filter.ID)
+	task.UserID = filter.UserID
+	task.Version = filter.Version
+	if cErr = h.service.Update(&task); cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	h.sendTaskV2(w, r, *filter.ID, http.StatusOK)
+}
+
+// PatchTaskV2 — PATCH /api/v2/tasks/{id}, частичное изменение (JSON Merge Patch)
+func (h *TaskHandler) PatchTaskV2(w http.ResponseWriter, r *http.Request) {
+	filter, cErr := taskFilter(r)
+	if cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	patch, cErr := decodePatch(r)
+	if cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	task, cErr := h.service.Patch(filter, patch)
+	if cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	setETag(w, task)
+	sendV2JSON(w, http.StatusOK, task)
+}
+
+// DeleteTaskV2 — DELETE /api/v2/tasks/{id}, отвечает 204 без тела
+func (h *TaskHandler) DeleteTaskV2(w http.ResponseWriter, r *http.Request) {
+	filter, cErr := taskFilter(r)
+	if cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	if cErr = h.service.Delete(filter); cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DoneTaskV2 — POST /api/v2/tasks/{id}/done. Отвечает задачей после выполнения:
+// повторяющаяся перенесена на следующую дату, остальные в состоянии done
+func (h *TaskHandler) DoneTaskV2(w http.ResponseWriter, r *http.Request) {
+	filter, cErr := taskFilter(r)
+	if cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	if cErr = h.service.Done(filter); cErr != nil {
+		sendProblem(w, r, cErr)
+		return
+	}
+	h.sendTaskV2(w, r, *filter.ID, http.StatusOK)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+// webhookID читает id подписки из параметра запроса
+func webhookID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	searchID := r.URL.Query().Get("id")
+	if searchID == "" {
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, domain.ErrID, nil))
+		return 0, false
+	}
+	id, err := strconv.ParseInt(searchID, 10, 64)
+	if err != nil {
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, domain.ErrID, err))
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *TaskHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	hooks, cErr := h.webhooks.List(userID(r))
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	err := json.NewEncoder(w).Encode(struct {
+		Webhooks []*domain.Webhook `json:"webhooks"`
+	}{
+		Webhooks: hooks,
+	})
+	if err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// AddWebhook создаёт подписку, секрет подписи возвращается только в этом ответе
+func (h *TaskHandler) AddWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, errors.New("ошибка десериализации JSON"), err))
+		return
+	}
+	hook, cErr := h.webhooks.Create(&domain.Webhook{UserID: userID(r), URL: req.URL, Secret: req.Secret, Events: req.Events})
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(hook); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func (h *TaskHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	hook, cErr := h.webhooks.Get(userID(r), id)
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(hook); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func (h *TaskHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, domain.NewCustomError(http.StatusBadRequest, errors.New("ошибка десериализации JSON"), err))
+		return
+	}
+	hook := &domain.Webhook{ID: id, UserID: userID(r), URL: req.URL, Events: req.Events, Active: true}
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+	if cErr := h.webhooks.Update(hook); cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(hook); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func (h *TaskHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	if cErr := h.webhooks.Delete(userID(r), id); cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// WebhookDeliveries возвращает журнал доставки подписки, последние записи первыми
+func (h *TaskHandler) WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	deliveries, cErr := h.webhooks.Deliveries(userID(r), id)
+	if cErr != nil {
+		sendMappedError(w, cErr)
+		return
+	}
+	err := json.NewEncoder(w).Encode(struct {
+		Deliveries []*domain.WebhookDelivery `json:"deliveries"`
+	}{
+		Deliveries: deliveries,
+	})
+	if err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,404 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/events"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+	wsMaxMessage = 1 << 20
+	//Очередь исходящих сообщений одного соединения
+	wsQueue = 64
+)
+
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+var errWSAuth = errors.New("сначала выполните auth с токеном")
+
+// wsRequest — вызов метода. id возвращается в ответе без изменений
+type wsRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type wsError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// wsResponse — ответ на вызов или уведомление (без id) с событием задачи
+type wsResponse struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params any             `json:"params,omitempty"`
+	Result any             `json:"result,omitempty"`
+	Error  *wsError        `json:"error,omitempty"`
+}
+
+// wsEvent — параметры уведомления event
+type wsEvent struct {
+	ID uint64 `json:"id"`
+	domain.TaskEvent
+}
+
+type wsIDParams struct {
+	ID json.Number `json:"id"`
+}
+
+type wsListParams struct {
+	Search   *string  `json:"search"`
+	Project  string   `json:"project"`
+	Tags     []string `json:"tags"`
+	Priority int      `json:"priority"`
+	Limit    int      `json:"limit"`
+	Cursor   string   `json:"cursor"`
+	Sort     string   `json:"sort"`
+	Desc     bool     `json:"desc"`
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+}
+
+// wsConn — состояние одного соединения. Запросы выполняются по очереди в readLoop,
+// писать в соединение может только writeLoop
+type wsConn struct {
+	h      *TaskHandler
+	conn   *websocket.Conn
+	jwtkey string
+	auth   bool
+	userID int64
+	authed bool
+	out    chan wsResponse
+	done   chan struct{}
+
+	mu     sync.Mutex
+	sub    *events.Subscription
+	lastID uint64
+}
+
+// WebSocket открывает соединение для интерактивных клиентов. Сообщения — JSON-объекты
+// {"id", "method", "params"}, ответы — {"id", "result"} или {"id", "error": {"code", "message"}},
+// коды ошибок совпадают с HTTP-статусами REST API. Методы: auth, list, get, add, update, done, delete, subscribe.
+// Токен принимается из cookie и заголовка Authorization или методом auth
+func (h *TaskHandler) WebSocket(jwtkey string, authEnabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			//Ответ с ошибкой уже отправлен Upgrade
+			return
+		}
+		c := &wsConn{
+			h:      h,
+			conn:   conn,
+			jwtkey: jwtkey,
+			auth:   authEnabled,
+			authed: !authEnabled,
+			out:    make(chan wsResponse, wsQueue),
+			done:   make(chan struct{}),
+		}
+		if authEnabled {
+			c.userID, c.authed = ParseToken(jwtkey, bearerToken(r))
+		}
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.writeLoop()
+		}()
+		c.readLoop()
+		close(c.done)
+		c.unsubscribe()
+		wg.Wait()
+		conn.Close()
+	}
+}
+
+func (c *wsConn) readLoop() {
+	c.conn.SetReadLimit(wsMaxMessage)
+	_ = c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("WebSocket: %v", err)
+			}
+			return
+		}
+		var req wsRequest
+		if err = json.Unmarshal(data, &req); err != nil || req.Method == "" {
+			c.send(wsResponse{ID: req.ID, Error: &wsError{Code: http.StatusBadRequest, Message: "неверный запрос"}})
+			continue
+		}
+		result, cErr := c.call(req)
+		if cErr != nil {
+			if cErr.Code == 0 {
+				if code, ok := errorMap[cErr.Err]; ok {
+					cErr.Code = code
+				} else {
+					cErr.Code = http.StatusInternalServerError
+				}
+			}
+			c.send(wsResponse{ID: req.ID, Error: &wsError{Code: cErr.Code, Message: cErr.Error()}})
+			continue
+		}
+		//Ответ на subscribe уже отправлен
+		if result != nil {
+			c.send(wsResponse{ID: req.ID, Result: result})
+		}
+	}
+}
+
+// writeLoop отправляет ответы, уведомления и ping, пока соединение открыто
+func (c *wsConn) writeLoop() {
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.done:
+			_ = c.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(wsWriteWait))
+			return
+		case msg := <-c.out:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				c.conn.Close()
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConn) send(msg wsResponse) {
+	select {
+	case c.out <- msg:
+	case <-c.done:
+	}
+}
+
+// call выполняет метод. Ошибки разбора параметров возвращаются с кодом 400,
+// ошибки сервиса — с нулевым кодом и сопоставляются по errorMap
+func (c *wsConn) call(req wsRequest) (any, *domain.CustomError) {
+	if req.Method == "auth" {
+		var params struct {
+			Token string `json:"token"`
+		}
+		if err := c.params(req, &params); err != nil {
+			return nil, err
+		}
+		if !c.auth {
+			return map[string]int64{"user_id": 0}, nil
+		}
+		id, ok := ParseToken(c.jwtkey, params.Token)
+		if !ok {
+			return nil, domain.NewCustomError(http.StatusUnauthorized, domain.ErrUnauthorized, nil)
+		}
+		//Пользователь соединения не меняется, иначе подписка получала бы чужие события
+		if c.authed && c.userID != id {
+			return nil, domain.NewCustomError(http.StatusForbidden, domain.ErrForbidden, nil)
+		}
+		c.userID, c.authed = id, true
+		return map[string]int64{"user_id": id}, nil
+	}
+	if !c.authed {
+		return nil, domain.NewCustomError(http.StatusUnauthorized, errWSAuth, nil)
+	}
+
+	switch req.Method {
+	case "list":
+		var params wsListParams
+		if err := c.params(req, &params); err != nil {
+			return nil, err
+		}
+		filter := &domain.Filter{
+			UserID:   c.userID,
+			Project:  params.Project,
+			Tags:     params.Tags,
+			Priority: params.Priority,
+			Limit:    params.Limit,
+			Cursor:   params.Cursor,
+			Sort:     params.Sort,
+			Desc:     params.Desc,
+			From:     params.From,
+			To:       params.To,
+		}
+		var page *domain.TaskPage
+		var cErr *domain.CustomError
+		if params.Search != nil {
+			filter.SearchTerm = *params.Search
+			page, cErr = c.h.service.Search(filter)
+		} else {
+			page, cErr = c.h.service.GetTasks(filter)
+		}
+		if cErr != nil {
+			return nil, cErr
+		}
+		return page, nil
+	case "get":
+		filter, cErr := c.idFilter(req)
+		if cErr != nil {
+			return nil, cErr
+		}
+		task, cErr := c.h.service.GetTask(filter)
+		if cErr != nil {
+			return nil, cErr
+		}
+		task.ID = strconv.Itoa(*filter.ID)
+		return task, nil
+	case "add":
+		var task domain.Task
+		if err := c.params(req, &task); err != nil {
+			return nil, err
+		}
+		task.UserID = c.userID
+		id, cErr := c.h.service.Create(&task)
+		if cErr != nil {
+			return nil, cErr
+		}
+		return map[string]int64{"id": id}, nil
+	case "update":
+		var task domain.Task
+		if err := c.params(req, &task); err != nil {
+			return nil, err
+		}
+		task.UserID = c.userID
+		if cErr := c.h.service.Update(&task); cErr != nil {
+			return nil, cErr
+		}
+		return struct{}{}, nil
+	case "done", "delete":
+		filter, cErr := c.idFilter(req)
+		if cErr != nil {
+			return nil, cErr
+		}
+		if req.Method == "done" {
+			cErr = c.h.service.Done(filter)
+		} else {
+			cErr = c.h.service.Delete(filter)
+		}
+		if cErr != nil {
+			return nil, cErr
+		}
+		return struct{}{}, nil
+	case "subscribe":
+		var params struct {
+			LastEventID *uint64 `json:"last_event_id"`
+		}
+		if err := c.params(req, &params); err != nil {
+			return nil, err
+		}
+		return nil, c.subscribe(req.ID, params.LastEventID)
+	}
+	return nil, domain.NewCustomError(http.StatusNotFound, errors.New("неизвестный метод "+req.Method), nil)
+}
+
+// params разбирает параметры вызова, отсутствующие параметры допустимы
+func (c *wsConn) params(req wsRequest, v any) *domain.CustomError {
+	if len(req.Params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(req.Params, v); err != nil {
+		return domain.NewCustomError(http.StatusBadRequest, errors.New("ошибка десериализации JSON"), err)
+	}
+	return nil
+}
+
+func (c *wsConn) idFilter(req wsRequest) (*domain.Filter, *domain.CustomError) {
+	var params wsIDParams
+	if err := c.params(req, &params); err != nil {
+		return nil, err
+	}
+	id, err := strconv.Atoi(params.ID.String())
+	if err != nil {
+		return nil, domain.NewCustomError(http.StatusBadRequest, domain.ErrID, err)
+	}
+	return &domain.Filter{ID: &id, UserID: c.userID}, nil
+}
+
+// subscribe включает уведомления event о задачах пользователя и сам отвечает на вызов,
+// чтобы ответ ушёл раньше событий. С last_event_id сначала приходят пропущенные события,
+// как у /api/events. Повторный вызов ничего не меняет
+func (c *wsConn) subscribe(reqID json.RawMessage, after *uint64) *domain.CustomError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sub != nil {
+		c.send(wsResponse{ID: reqID, Result: struct{}{}})
+		return nil
+	}
+	var sub *events.Subscription
+	var missed []events.Event
+	if after != nil {
+		sub, missed = c.h.events.Subscribe(c.userID, *after, true)
+	} else {
+		sub, missed = c.h.events.Subscribe(c.userID, 0, false)
+	}
+	if sub == nil {
+		return domain.NewCustomError(http.StatusServiceUnavailable, errors.New("сервер останавливается"), nil)
+	}
+	c.sub = sub
+	c.send(wsResponse{ID: reqID, Result: struct{}{}})
+	go c.forward(sub, missed)
+	return nil
+}
+
+// forward пересылает события подписки. Если соединение не успевало их забирать
+// и рассыльщик отключил подписку, она возобновляется с последнего отправленного события
+func (c *wsConn) forward(sub *events.Subscription, missed []events.Event) {
+	for {
+		for _, e := range missed {
+			c.notify(e)
+		}
+		for e := range sub.Events() {
+			c.notify(e)
+		}
+		c.mu.Lock()
+		if c.sub != sub {
+			c.mu.Unlock()
+			return
+		}
+		sub, missed = c.h.events.Subscribe(c.userID, c.lastID, true)
+		c.sub = sub
+		c.mu.Unlock()
+		if sub == nil {
+			//Сервер останавливается
+			c.conn.Close()
+			return
+		}
+	}
+}
+
+func (c *wsConn) notify(e events.Event) {
+	c.mu.Lock()
+	c.lastID = e.ID
+	c.mu.Unlock()
+	c.send(wsResponse{Method: "event", Params: wsEvent{ID: e.ID, TaskEvent: e.TaskEvent}})
+}
+
+func (c *wsConn) unsubscribe() {
+	c.mu.Lock()
+	sub := c.sub
+	c.sub = nil
+	c.mu.Unlock()
+	if sub != nil {
+		c.h.events.Unsubscribe(sub)
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -10,8 +11,28 @@ type Config struct {
 	Port     int    `mapstructure:"TODO_PORT"`
 	DBdriver string `mapstructure:"TODO_DRIVER"`
 	DBPath   string `mapstructure:"TODO_DBFILE"`
+	DSN      string `mapstructure:"TODO_DSN"`
 	Password string `mapstructure:"TODO_PASSWORD"`
 	JWTKey   string `mapstructure:"TODO_JWTSECRET"`
+	Migrate  bool   `mapstructure:"TODO_MIGRATE"`
+	//Разрешить самостоятельную регистрацию через /api/signup
+	Registration bool `mapstructure:"TODO_REGISTRATION"`
+	//Порт gRPC-сервиса TaskScheduler, 0 — не запускать
+	GRPCPort int `mapstructure:"TODO_GRPC_PORT"`
+	//Разрешить доставку webhook на локальный узел и адреса внутренних сетей
+	WebhookPrivate bool `mapstructure:"TODO_WEBHOOK_PRIVATE"`
+
+	//Напоминания: смещения до срока (1d,1h), период просмотра задач и каналы доставки
+	Reminders        bool          `mapstructure:"TODO_REMINDERS"`
+	ReminderOffsets  []string      `mapstructure:"TODO_REMINDER_OFFSETS"`
+	ReminderInterval time.Duration `mapstructure:"TODO_REMINDER_INTERVAL"`
+	Notifiers        []string      `mapstructure:"TODO_NOTIFIERS"`
+	SMTPAddr         string        `mapstructure:"TODO_SMTP_ADDR"`
+	SMTPFrom         string        `mapstructure:"TODO_SMTP_FROM"`
+	SMTPTo           []string      `mapstructure:"TODO_SMTP_TO"`
+	SMTPUser         string        `mapstructure:"TODO_SMTP_USER"`
+	SMTPPassword     string        `mapstructure:"TODO_SMTP_PASSWORD"`
+	ReminderWebhook  string        `mapstructure:"TODO_REMINDER_WEBHOOK"`
 }
 
 func LoadCfg() (*Config, error) {
@@ -31,8 +52,29 @@ func LoadCfg() (*Config, error) {
 	viper.BindEnv("TODO_PORT")
 	viper.BindEnv("TODO_DRIVER")
 	viper.BindEnv("TODO_DBFILE")
+	viper.BindEnv("TODO_DSN")
 	viper.BindEnv("TODO_PASSWORD")
 	viper.BindEnv("TODO_JWTSECRET")
+	viper.BindEnv("TODO_MIGRATE")
+	viper.BindEnv("TODO_REGISTRATION")
+	viper.BindEnv("TODO_GRPC_PORT")
+	viper.BindEnv("TODO_WEBHOOK_PRIVATE")
+	viper.BindEnv("TODO_REMINDERS")
+	viper.BindEnv("TODO_REMINDER_OFFSETS")
+	viper.BindEnv("TODO_REMINDER_INTERVAL")
+	viper.BindEnv("TODO_NOTIFIERS")
+	viper.BindEnv("TODO_SMTP_ADDR")
+	viper.BindEnv("TODO_SMTP_FROM")
+	viper.BindEnv("TODO_SMTP_TO")
+	viper.BindEnv("TODO_SMTP_USER")
+	viper.BindEnv("TODO_SMTP_PASSWORD")
+	viper.BindEnv("TODO_REMINDER_WEBHOOK")
+
+	//По умолчанию миграции применяются при запуске
+	viper.SetDefault("TODO_MIGRATE", true)
+	viper.SetDefault("TODO_REMINDER_OFFSETS", "1d,1h")
+	viper.SetDefault("TODO_REMINDER_INTERVAL", "1m")
+	viper.SetDefault("TODO_NOTIFIERS", "log")
 
 	var cfg Config
 	if err := viper.Unmarshal(&cfg); err != nil {
@@ -40,15 +82,27 @@ func LoadCfg() (*Config, error) {
 	}
 
 	//Проверки конфига
-	if cfg.DBdriver == "" {
+	switch cfg.DBdriver {
+	case "":
 		return nil, fmt.Errorf("не указан драйвер БД")
+	case "postgres":
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("не указана строка подключения к PostgreSQL")
+		}
+	default:
+		if cfg.DBPath == "" {
+			return nil, fmt.Errorf("не указан путь к файлу БД")
+		}
 	}
-	if cfg.DBPath == "" {
-		return nil, fmt.Errorf("не указан путь к файлу БД")
+	if cfg.Password != "" && cfg.JWTKey == "" {
+		return nil, fmt.Errorf("не указан ключ подписи токенов TODO_JWTSECRET")
 	}
 	if cfg.Port <= 0 || cfg.Port > 65535 {
 		return nil, fmt.Errorf("некорректный номер порта: %d", cfg.Port)
 	}
+	if cfg.GRPCPort < 0 || cfg.GRPCPort > 65535 || cfg.GRPCPort == cfg.Port {
+		return nil, fmt.Errorf("некорректный номер порта gRPC: %d", cfg.GRPCPort)
+	}
 
 	return &cfg, nil
 }
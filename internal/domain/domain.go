@@ -1,24 +1,258 @@
 package domain
 
+import "time"
+
 type Task struct {
-	ID      string `json:"id,omitempty"`
-	Date    string `json:"date,omitempty"`
-	Title   string `json:"title,omitempty"`
-	Comment string `json:"comment,omitempty"`
-	Repeat  string `json:"repeat,omitempty"`
+	ID       string   `json:"id,omitempty"`
+	Date     string   `json:"date,omitempty"`
+	Title    string   `json:"title,omitempty"`
+	Comment  string   `json:"comment,omitempty"`
+	Repeat   string   `json:"repeat,omitempty"`
+	Priority int      `json:"priority,omitempty"`
+	Project  string   `json:"project,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	//Необязательное время выполнения ЧЧ:ММ и часовой пояс IANA
+	Time     string `json:"time,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	//Срок выполнения в формате RFC 3339, вычисляется при чтении
+	Due string `json:"due,omitempty"`
+	//Состояние задачи, заполняется при чтении и учитывается только при импорте
+	Status string `json:"status,omitempty"`
+	UserID int64  `json:"-"`
+	//Версия растёт при каждом изменении задачи, передаётся клиентам в ETag
+	Version int64 `json:"-"`
+	//Фрагмент текста с найденными словами в <mark></mark> и релевантность (меньше — выше),
+	//заполняются только при поиске
+	Snippet string  `json:"snippet,omitempty"`
+	Rank    float64 `json:"-"`
+	//Признаки задачи в повестке: просрочена задача без повторения с прошедшей датой,
+	//Virtual — вычисленное повторение, у самой задачи в БД другая дата
+	Overdue bool `json:"overdue,omitempty"`
+	Virtual bool `json:"virtual,omitempty"`
+}
+
+// Уровни приоритета задачи
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+// Состояния задачи, StatusAll в фильтре выбирает задачи в любом состоянии
+const (
+	StatusActive = "active"
+	StatusDone   = "done"
+	StatusAll    = "all"
+)
+
+// Completion — запись журнала выполнения задачи
+type Completion struct {
+	ID          string `json:"id,omitempty"`
+	TaskID      string `json:"task_id"`
+	Title       string `json:"title"`
+	Date        string `json:"date"`
+	NextDate    string `json:"next_date,omitempty"`
+	CompletedAt string `json:"completed_at"`
+	UserID      int64  `json:"-"`
+}
+
+// Состояния записей импорта
+const (
+	ImportCreated = "created"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// ImportItem — запись отчёта об импорте: исходная запись, её задача и результат
+type ImportItem struct {
+	//Номер записи в файле начиная с 1 и её идентификатор в источнике, например UID
+	Index  int    `json:"index"`
+	Ref    string `json:"ref,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Status string `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Task   *Task  `json:"-"`
+}
+
+// ImportReport — итог импорта по всем записям.
+// При пробном импорте (DryRun) created — число задач, которые были бы созданы
+type ImportReport struct {
+	DryRun  bool          `json:"dry_run,omitempty"`
+	Created int           `json:"created"`
+	Skipped int           `json:"skipped"`
+	Failed  int           `json:"failed"`
+	Items   []*ImportItem `json:"items"`
+}
+
+// User — учётная запись, владелец задач.
+// Задачи с UserID = 0 не принадлежат никому и видны, когда аутентификация отключена
+type User struct {
+	ID           int64  `json:"id"`
+	Login        string `json:"login"`
+	PasswordHash string `json:"-"`
+	IsAdmin      bool   `json:"is_admin"`
+	Timezone     string `json:"timezone,omitempty"`
+	CreatedAt    string `json:"created_at,omitempty"`
+}
+
+// Типы событий жизненного цикла задачи
+const (
+	EventTaskCreated = "task.created"
+	EventTaskUpdated = "task.updated"
+	EventTaskDone    = "task.done"
+	EventTaskDeleted = "task.deleted"
+)
+
+// TaskEvent — событие, которое TaskService рассылает подписчикам после успешной операции
+type TaskEvent struct {
+	Type       string      `json:"event"`
+	TaskID     string      `json:"task_id"`
+	UserID     int64       `json:"-"`
+	Task       *Task       `json:"task,omitempty"`
+	Completion *Completion `json:"completion,omitempty"`
+	OccurredAt string      `json:"occurred_at"`
 }
 
 type Filter struct {
 	ID         *int
 	SearchTerm string
 	Date       string
+	Status     string
+	Priority   int
+	Project    string
+	Tags       []string
 	Limit      int
+	UserID     int64
+	//Диапазон дат From..To включительно и поиск по задачам всех пользователей
+	//для фоновых задач сервера
+	From     string
+	To       string
+	AllUsers bool
+	//Ожидаемая версия задачи для изменения по ID, 0 — без проверки
+	Version int64
+	//Заблокировать найденные задачи до конца транзакции WithTx
+	ForUpdate bool
+	//Сортировка по ключу Sort*, по умолчанию по дате. Cursor — непрозрачный курсор страницы
+	//из запроса, After — позиция, разобранная из него сервисом
+	Sort   string
+	Desc   bool
+	Cursor string
+	After  *Cursor
+	//Условия из языка запросов поиска, все должны выполняться
+	Conditions []Condition
+}
+
+// Поля условий поиска
+const (
+	//Слово в заголовке или комментарии
+	FieldText    = "text"
+	FieldTitle   = "title"
+	FieldComment = "comment"
+	//Начало правила повторения, например w
+	FieldRepeat = "repeat"
+	//Задача повторяется, Value не используется
+	FieldRecurring = "recurring"
+	FieldTag       = "tag"
+	FieldProject   = "project"
+)
+
+// Condition — условие поиска по полю задачи. Not — задача не должна ему соответствовать
+type Condition struct {
+	Field string
+	Value string
+	Not   bool
+}
+
+// Ключи сортировки списка задач
+const (
+	SortDate     = "date"
+	SortTitle    = "title"
+	SortPriority = "priority"
+	SortCreated  = "created"
+	//По релевантности, только при поиске
+	SortRank = "rank"
+)
+
+// Cursor — позиция в списке задач: порядок сортировки и ключ последней задачи страницы.
+// Используются только поля ключа сортировки Sort и ID
+type Cursor struct {
+	Sort     string  `json:"s"`
+	Desc     bool    `json:"d,omitempty"`
+	ID       int64   `json:"id"`
+	Date     string  `json:"date,omitempty"`
+	Time     string  `json:"time,omitempty"`
+	Title    string  `json:"title,omitempty"`
+	Priority int     `json:"priority,omitempty"`
+	Rank     float64 `json:"rank,omitempty"`
+}
+
+// TaskPage — страница списка задач. Total — число задач по фильтру на всех страницах,
+// NextCursor пуст на последней странице
+type TaskPage struct {
+	Tasks      []*Task `json:"tasks"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	Total      int     `json:"total"`
+}
+
+// Agenda — задачи по дням с From по To включительно. Days содержит каждый день периода,
+// в Overdue — просроченные задачи без повторения с датой раньше From
+type Agenda struct {
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Overdue []*Task      `json:"overdue"`
+	Days    []*AgendaDay `json:"days"`
+}
+
+type AgendaDay struct {
+	Date  string  `json:"date"`
+	Tasks []*Task `json:"tasks"`
+}
+
+type CompletionFilter struct {
+	TaskID *int
+	From   time.Time
+	To     time.Time
+	UserID int64
 }
 
 type TaskRepository interface {
 	FindTask(filter *Filter) ([]*Task, error)
+	//CountTasks возвращает число задач по фильтру без учёта Limit и After
+	CountTasks(filter *Filter) (int, error)
 	CreateTask(task *Task) (int64, error)
+	//CreateTasks создаёт задачи одной транзакцией: сохраняются все или ни одной
+	CreateTasks(tasks []*Task) ([]int64, error)
 	UpdateTask(task *Task) error
-	DeleteTask(id *int) error
+	DeleteTask(filter *Filter) error
+	SetStatus(filter *Filter, status string) error
+	AddCompletion(completion *Completion) (int64, error)
+	FindCompletions(filter *CompletionFilter) ([]*Completion, error)
+	//WithTx выполняет fn в одной транзакции с репозиторием, привязанным к ней:
+	//изменения сохраняются, только если fn вернула nil. Вложенный вызов продолжает ту же транзакцию
+	WithTx(fn func(repo TaskRepository) error) error
 	Close() error
 }
+
+type UserRepository interface {
+	CreateUser(user *User) (int64, error)
+	FindUser(login string) (*User, error)
+	FindUserByID(id int64) (*User, error)
+	ListUsers() ([]*User, error)
+	SetPassword(id int64, hash string) error
+	SetTimezone(id int64, timezone string) error
+	AssignOrphanTasks(userID int64) (int64, error)
+	//Токен подписки на календарь хранится в виде хэша, заменяет прежний токен пользователя
+	SaveFeedToken(userID int64, tokenHash string) error
+	//FindFeedToken возвращает владельца токена, false — токен не найден
+	FindFeedToken(tokenHash string) (int64, bool, error)
+	DeleteFeedToken(userID int64) error
+}
+
+// ReminderRepository хранит отметки об отправленных напоминаниях,
+// чтобы после перезапуска они не отправлялись повторно
+type ReminderRepository interface {
+	ReminderSent(taskID, due string, offset time.Duration, channel string) (bool, error)
+	SaveReminder(taskID, due string, offset time.Duration, channel string) error
+}
@@ -1,12 +1,45 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrID             = errors.New("некорректный id")
 	ErrBadTitle       = errors.New("не указан заголовок задачи")
 	ErrDate           = errors.New("неправильный формат даты")
-	ErrInternalServer = errors.New("внутренняя ошибка сервера")
+	ErrRepeat         = errors.New("неверное правило повторения")
+	ErrPriority       = errors.New("неверный приоритет задачи")
+	ErrTag            = errors.New("неверное название метки или проекта")
+	ErrTime           = errors.New("неправильный формат времени, ожидается ЧЧ:ММ")
+	ErrTimezone       = errors.New("неизвестный часовой пояс")
+	ErrNotFound       = errors.New("задача не найдена")
+	ErrTaskDone       = errors.New("задача уже выполнена")
+	ErrStatus         = errors.New("неизвестное состояние задачи")
+	ErrPatch          = errors.New("неверные значения полей задачи")
+	ErrConflict       = errors.New("задача изменена другим запросом")
+	ErrSort           = errors.New("неизвестный порядок сортировки")
+	ErrCursor         = errors.New("некорректный курсор страницы")
+	ErrLimit          = errors.New("размер страницы должен быть от 1 до 100")
+	ErrQuery          = errors.New("ошибка в запросе поиска")
+	ErrAgendaRange    = errors.New("период повестки должен быть не длиннее 92 дней и to не раньше from")
+	ErrFilterName     = errors.New("не указано название фильтра или оно длиннее 64 символов")
+	ErrFilterExists   = errors.New("фильтр с таким названием уже существует")
+	ErrFilterNotFound = errors.New("фильтр не найден")
+	ErrFilterBuiltIn  = errors.New("встроенный список нельзя изменить или удалить")
+	ErrLogin          = errors.New("неверный логин")
+	ErrPassword       = errors.New("пароль должен быть не короче 4 символов")
+	ErrCredentials    = errors.New("неправильный логин или пароль")
+	ErrUserExists     = errors.New("пользователь с таким логином уже существует")
+	ErrUnauthorized   = errors.New("не авторизован")
+	ErrForbidden      = errors.New("недостаточно прав")
+	ErrWebhookURL     = errors.New("адрес webhook должен быть абсолютным URL http или https")
+	ErrWebhookEvent   = errors.New("неизвестный тип события")
+	ErrWebhookHost    = errors.New("адрес webhook не должен указывать на локальный узел или внутреннюю сеть")
+	//ErrWebhookNotFound возвращает хранилище, если подписки пользователя с таким id нет
+	ErrWebhookNotFound = errors.New("webhook не найден")
+	ErrInternalServer  = errors.New("внутренняя ошибка сервера")
 )
 
 type CustomError struct {
@@ -22,3 +55,11 @@ func NewCustomError(code int, err error, errStorage error) *CustomError {
 		ErrStorage: errStorage,
 	}
 }
+
+// Error возвращает текст ошибки вместе с подробностями из ErrStorage
+func (e *CustomError) Error() string {
+	if e.ErrStorage != nil {
+		return fmt.Sprintf("%v: %v", e.Err, e.ErrStorage)
+	}
+	return fmt.Sprintf("%v", e.Err)
+}
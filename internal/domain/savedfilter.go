@@ -0,0 +1,31 @@
+package domain
+
+// SavedFilter — сохранённый поиск пользователя. Query записан на языке запросов поиска,
+// как параметр search списка задач. Встроенные списки не хранятся в БД, их id — название
+type SavedFilter struct {
+	ID        string `json:"id"`
+	UserID    int64  `json:"-"`
+	Name      string `json:"name"`
+	Query     string `json:"query"`
+	Sort      string `json:"sort,omitempty"`
+	Order     string `json:"order,omitempty"`
+	BuiltIn   bool   `json:"builtin,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// Встроенные списки задач
+const (
+	FilterToday     = "today"
+	FilterOverdue   = "overdue"
+	FilterNext7     = "next7"
+	FilterRecurring = "recurring"
+)
+
+type FilterRepository interface {
+	CreateFilter(filter *SavedFilter) (int64, error)
+	FindFilters(userID int64) ([]*SavedFilter, error)
+	//FindFilter ищет фильтр пользователя, возвращает nil, если такого нет
+	FindFilter(userID, id int64) (*SavedFilter, error)
+	UpdateFilter(filter *SavedFilter) error
+	DeleteFilter(userID, id int64) error
+}
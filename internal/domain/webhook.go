@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+// Webhook — подписка пользователя на события задач
+type Webhook struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"-"`
+	URL    string `json:"url"`
+	//Секрет подписи показывается только при создании подписки
+	Secret string `json:"secret,omitempty"`
+	//Пустой список — все события
+	Events    []string `json:"events"`
+	Active    bool     `json:"active"`
+	CreatedAt string   `json:"created_at,omitempty"`
+}
+
+// Состояния доставки webhook
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery — запись очереди и журнала доставки события одной подписке
+type WebhookDelivery struct {
+	ID            int64  `json:"id"`
+	WebhookID     int64  `json:"webhook_id"`
+	Event         string `json:"event"`
+	Payload       string `json:"payload"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	NextAttemptAt string `json:"next_attempt_at,omitempty"`
+	ResponseCode  int    `json:"response_code,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	CreatedAt     string `json:"created_at"`
+	DeliveredAt   string `json:"delivered_at,omitempty"`
+	//Адрес и секрет подписки заполняются для отправки
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type WebhookRepository interface {
+	CreateWebhook(hook *Webhook) (int64, error)
+	FindWebhooks(userID int64) ([]*Webhook, error)
+	FindWebhook(userID, id int64) (*Webhook, error)
+	UpdateWebhook(hook *Webhook) error
+	DeleteWebhook(userID, id int64) error
+	AddDeliveries(deliveries []*WebhookDelivery) error
+	PendingDeliveries(now time.Time, limit int) ([]*WebhookDelivery, error)
+	UpdateDelivery(delivery *WebhookDelivery) error
+	FindDeliveries(webhookID int64, limit int) ([]*WebhookDelivery, error)
+}
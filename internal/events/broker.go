@@ -0,0 +1,133 @@
+// Package events рассылает события задач подключённым клиентам (Server-Sent Events).
+// Последние события хранятся в кольцевом буфере, чтобы клиент после переподключения
+// мог получить пропущенное по номеру последнего события.
+package events
+
+import (
+	"sync"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+// Размер очереди одного подписчика. Подписчик, который не успевает забирать события,
+// отключается и получает пропущенное из буфера после переподключения
+const subscriberQueue = 64
+
+// EventReset сообщает клиенту, что пропущенные события уже вытеснены из буфера
+// и список задач нужно перечитать
+const EventReset = "reset"
+
+// Event — событие задачи с порядковым номером
+type Event struct {
+	ID uint64
+	domain.TaskEvent
+}
+
+// Subscription — подписка клиента на события своих задач
+type Subscription struct {
+	userID int64
+	ch     chan Event
+}
+
+// Events возвращает канал событий, он закрывается при отключении подписчика
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+type Broker struct {
+	mu     sync.Mutex
+	buffer []Event
+	start  int
+	lastID uint64
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewBroker создаёт рассыльщика, хранящего size последних событий
+func NewBroker(size int) *Broker {
+	if size < 1 {
+		size = 1
+	}
+	return &Broker{buffer: make([]Event, 0, size), subs: make(map[*Subscription]struct{})}
+}
+
+// Publish присваивает событию номер, сохраняет его в буфере и отправляет подписчикам владельца задачи.
+// Подходит как обработчик для TaskService.Subscribe
+func (b *Broker) Publish(event domain.TaskEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.lastID++
+	e := Event{ID: b.lastID, TaskEvent: event}
+	if len(b.buffer) < cap(b.buffer) {
+		b.buffer = append(b.buffer, e)
+	} else {
+		b.buffer[b.start] = e
+		b.start = (b.start + 1) % len(b.buffer)
+	}
+	for sub := range b.subs {
+		if sub.userID != event.UserID {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe подписывает клиента пользователя userID. Если известен номер последнего
+// полученного события after, возвращаются более поздние события из буфера.
+// Если часть из них уже вытеснена, вместо них возвращается одно событие EventReset
+// с номером последнего события. Для закрытого рассыльщика возвращается nil
+func (b *Broker) Subscribe(userID int64, after uint64, resume bool) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil
+	}
+	var missed []Event
+	if resume {
+		//Номер больше последнего остаётся от прошлого запуска сервера
+		lost := after > b.lastID || (len(b.buffer) > 0 && after+1 < b.buffer[b.start].ID)
+		for i := 0; i < len(b.buffer) && !lost; i++ {
+			e := b.buffer[(b.start+i)%len(b.buffer)]
+			if e.ID > after && e.UserID == userID {
+				missed = append(missed, e)
+			}
+		}
+		if lost {
+			missed = []Event{{ID: b.lastID, TaskEvent: domain.TaskEvent{Type: EventReset, UserID: userID}}}
+		}
+	}
+	sub := &Subscription{userID: userID, ch: make(chan Event, subscriberQueue)}
+	b.subs[sub] = struct{}{}
+	return sub, missed
+}
+
+// Unsubscribe отключает подписчика
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(sub)
+}
+
+// Close отключает всех подписчиков, чтобы открытые соединения не задерживали остановку сервера
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		b.drop(sub)
+	}
+}
+
+func (b *Broker) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
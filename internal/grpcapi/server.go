@@ -0,0 +1,344 @@
+// Package grpcapi — gRPC-сервис TaskScheduler поверх TaskService для внутренних клиентов.
+// Описание сервиса — pb/scheduler.proto, код в pb генерируется командой go generate.
+package grpcapi
+
+//go:generate protoc --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative -I pb pb/scheduler.proto
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/api"
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/events"
+	"github.com/agidelle/todo_web/internal/grpcapi/pb"
+	"github.com/agidelle/todo_web/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// codeMap сопоставляет ошибки сервиса кодам gRPC, как errorMap в api — HTTP-статусам
+var codeMap = map[error]codes.Code{
+	domain.ErrID:             codes.InvalidArgument,
+	domain.ErrBadTitle:       codes.InvalidArgument,
+	domain.ErrDate:           codes.InvalidArgument,
+	domain.ErrRepeat:         codes.InvalidArgument,
+	domain.ErrPriority:       codes.InvalidArgument,
+	domain.ErrTag:            codes.InvalidArgument,
+	domain.ErrTime:           codes.InvalidArgument,
+	domain.ErrTimezone:       codes.InvalidArgument,
+	domain.ErrNotFound:       codes.NotFound,
+	domain.ErrTaskDone:       codes.FailedPrecondition,
+	domain.ErrStatus:         codes.InvalidArgument,
+	domain.ErrConflict:       codes.Aborted,
+	domain.ErrQuery:          codes.InvalidArgument,
+	domain.ErrSort:           codes.InvalidArgument,
+	domain.ErrCursor:         codes.InvalidArgument,
+	domain.ErrLimit:          codes.InvalidArgument,
+	domain.ErrUnauthorized:   codes.Unauthenticated,
+	domain.ErrForbidden:      codes.PermissionDenied,
+	domain.ErrInternalServer: codes.Internal,
+}
+
+type ctxKey int
+
+const userKey ctxKey = iota
+
+type Server struct {
+	pb.UnimplementedTaskSchedulerServer
+	service *service.TaskService
+	events  *events.Broker
+}
+
+// NewServer создаёт gRPC-сервер с сервисом TaskScheduler. При включённой аутентификации
+// каждый вызов должен передавать токен в метаданных authorization: Bearer <токен>
+func NewServer(svc *service.TaskService, broker *events.Broker, jwtkey string, authEnabled bool) *grpc.Server {
+	a := authenticator{jwtkey: jwtkey, enabled: authEnabled}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(a.unary), grpc.StreamInterceptor(a.stream))
+	pb.RegisterTaskSchedulerServer(srv, &Server{service: svc, events: broker})
+	return srv
+}
+
+// authenticator проверяет токен тем же способом, что и JWTMiddleware REST API
+type authenticator struct {
+	jwtkey  string
+	enabled bool
+}
+
+func (a authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	if !a.enabled {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if raw, ok := strings.CutPrefix(value, "Bearer "); ok {
+			if id, ok := api.ParseToken(a.jwtkey, raw); ok {
+				return context.WithValue(ctx, userKey, id), nil
+			}
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, domain.ErrUnauthorized.Error())
+}
+
+func (a authenticator) unary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a authenticator) stream(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+}
+
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
+
+// userID возвращает id пользователя вызова, 0 — аутентификация отключена
+func userID(ctx context.Context) int64 {
+	id, _ := ctx.Value(userKey).(int64)
+	return id
+}
+
+// toStatus переводит ошибку сервиса в статус gRPC
+func toStatus(cErr *domain.CustomError) error {
+	code, ok := codeMap[cErr.Err]
+	if !ok {
+		code = codes.Internal
+	}
+	return status.Error(code, cErr.Error())
+}
+
+func (s *Server) CreateTask(ctx context.Context, req *pb.CreateTaskRequest) (*pb.CreateTaskResponse, error) {
+	task := fromProto(req.GetTask())
+	task.ID = ""
+	task.UserID = userID(ctx)
+	id, cErr := s.service.Create(task)
+	if cErr != nil {
+		return nil, toStatus(cErr)
+	}
+	return &pb.CreateTaskResponse{Id: id}, nil
+}
+
+func (s *Server) GetTask(ctx context.Context, req *pb.GetTaskRequest) (*pb.Task, error) {
+	filter, err := idFilter(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	task, cErr := s.service.GetTask(filter)
+	if cErr != nil {
+		return nil, toStatus(cErr)
+	}
+	task.ID = strconv.FormatInt(req.GetId(), 10)
+	return toProto(task), nil
+}
+
+func (s *Server) ListTasks(ctx context.Context, req *pb.ListTasksRequest) (*pb.ListTasksResponse, error) {
+	filter := &domain.Filter{
+		UserID:   userID(ctx),
+		Priority: int(req.GetPriority()),
+		Project:  req.GetProject(),
+		Tags:     req.GetTags(),
+		Limit:    int(req.GetLimit()),
+		Cursor:   req.GetCursor(),
+		Sort:     req.GetSort(),
+		Desc:     req.GetDesc(),
+		From:     req.GetFrom(),
+		To:       req.GetTo(),
+	}
+	var page *domain.TaskPage
+	var cErr *domain.CustomError
+	if req.GetSearch() != "" {
+		filter.SearchTerm = req.GetSearch()
+		page, cErr = s.service.Search(filter)
+	} else {
+		page, cErr = s.service.GetTasks(filter)
+	}
+	if cErr != nil {
+		return nil, toStatus(cErr)
+	}
+	tasks := page.Tasks
+	resp := &pb.ListTasksResponse{
+		Tasks:      make([]*pb.Task, len(tasks)),
+		NextCursor: page.NextCursor,
+		Total:      int32(page.Total),
+	}
+	for i, task := range tasks {
+		resp.Tasks[i] = toProto(task)
+	}
+	return resp, nil
+}
+
+func (s *Server) UpdateTask(ctx context.Context, req *pb.UpdateTaskRequest) (*pb.UpdateTaskResponse, error) {
+	task := fromProto(req.GetTask())
+	task.UserID = userID(ctx)
+	if cErr := s.service.Update(task); cErr != nil {
+		return nil, toStatus(cErr)
+	}
+	return &pb.UpdateTaskResponse{}, nil
+}
+
+func (s *Server) DeleteTask(ctx context.Context, req *pb.DeleteTaskRequest) (*pb.DeleteTaskResponse, error) {
+	filter, err := idFilter(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if cErr := s.service.Delete(filter); cErr != nil {
+		return nil, toStatus(cErr)
+	}
+	return &pb.DeleteTaskResponse{}, nil
+}
+
+func (s *Server) Done(ctx context.Context, req *pb.DoneRequest) (*pb.DoneResponse, error) {
+	filter, err := idFilter(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if cErr := s.service.Done(filter); cErr != nil {
+		return nil, toStatus(cErr)
+	}
+	return &pb.DoneResponse{}, nil
+}
+
+// NextDate повторяет проверки GET /api/nextdate
+func (s *Server) NextDate(_ context.Context, req *pb.NextDateRequest) (*pb.NextDateResponse, error) {
+	loc := time.Local
+	if tz := req.GetTz(); tz != "" {
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil || tz == "Local" {
+			return nil, status.Error(codes.InvalidArgument, domain.ErrTimezone.Error())
+		}
+	}
+	now := time.Now().In(loc)
+	if req.GetNow() != "" {
+		var err error
+		if now, err = time.Parse("20060102", req.GetNow()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, domain.ErrDate.Error())
+		}
+	}
+	if _, err := time.Parse("20060102", req.GetDate()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, domain.ErrDate.Error())
+	}
+	//Ошибки NextDate вызваны неверными датой или правилом
+	next, err := s.service.NextDate(now, req.GetDate(), req.GetRepeat())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "ошибка вычисления следующей даты: %v", err)
+	}
+	if next == "" {
+		return nil, status.Error(codes.InvalidArgument, domain.ErrRepeat.Error())
+	}
+	return &pb.NextDateResponse{Date: next}, nil
+}
+
+// Watch передаёт события задач пользователя до отмены вызова или остановки сервера.
+// Заголовки ответа отправляются сразу после подписки: дождавшись их, клиент не пропустит события
+func (s *Server) Watch(req *pb.WatchRequest, stream grpc.ServerStreamingServer[pb.TaskEvent]) error {
+	ctx := stream.Context()
+	sub, missed := s.events.Subscribe(userID(ctx), req.GetLastEventId(), req.GetLastEventId() > 0)
+	if sub == nil {
+		return status.Error(codes.Unavailable, "сервер останавливается")
+	}
+	defer s.events.Unsubscribe(sub)
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	for _, e := range missed {
+		if err := stream.Send(eventToProto(e)); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.Unavailable, "подписка закрыта, переподключитесь с last_event_id")
+			}
+			if err := stream.Send(eventToProto(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func idFilter(ctx context.Context, id int64) (*domain.Filter, error) {
+	if id <= 0 {
+		return nil, status.Error(codes.InvalidArgument, domain.ErrID.Error())
+	}
+	n := int(id)
+	return &domain.Filter{ID: &n, UserID: userID(ctx)}, nil
+}
+
+func toProto(task *domain.Task) *pb.Task {
+	id, _ := strconv.ParseInt(task.ID, 10, 64)
+	return &pb.Task{
+		Id:       id,
+		Date:     task.Date,
+		Title:    task.Title,
+		Comment:  task.Comment,
+		Repeat:   task.Repeat,
+		Priority: int32(task.Priority),
+		Project:  task.Project,
+		Tags:     task.Tags,
+		Time:     task.Time,
+		Timezone: task.Timezone,
+		Due:      task.Due,
+		Status:   task.Status,
+	}
+}
+
+func fromProto(task *pb.Task) *domain.Task {
+	t := &domain.Task{
+		Date:     task.GetDate(),
+		Title:    task.GetTitle(),
+		Comment:  task.GetComment(),
+		Repeat:   task.GetRepeat(),
+		Priority: int(task.GetPriority()),
+		Project:  task.GetProject(),
+		Tags:     task.GetTags(),
+		Time:     task.GetTime(),
+		Timezone: task.GetTimezone(),
+	}
+	if task.GetId() > 0 {
+		t.ID = strconv.FormatInt(task.GetId(), 10)
+	}
+	return t
+}
+
+func eventToProto(e events.Event) *pb.TaskEvent {
+	taskID, _ := strconv.ParseInt(e.TaskID, 10, 64)
+	event := &pb.TaskEvent{
+		Id:         e.ID,
+		Event:      e.Type,
+		TaskId:     taskID,
+		OccurredAt: e.OccurredAt,
+	}
+	if e.Task != nil {
+		event.Task = toProto(e.Task)
+	}
+	if e.Completion != nil {
+		event.Completion = &pb.Completion{
+			Date:        e.Completion.Date,
+			NextDate:    e.Completion.NextDate,
+			CompletedAt: e.Completion.CompletedAt,
+		}
+	}
+	return event
+}
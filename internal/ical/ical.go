@@ -0,0 +1,352 @@
+// Package ical формирует календарь iCalendar (RFC 5545) из задач планировщика
+// для подписки в календарных приложениях.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/recurrence"
+)
+
+const (
+	dateForm     = "20060102"
+	localForm    = "20060102T150405"
+	utcForm      = "20060102T150405Z"
+	taskTimeForm = "20060102 15:04"
+	//Максимальная длина строки содержимого в октетах без CRLF
+	lineLimit = 75
+	prodID    = "-//todo_web//scheduler//RU"
+	//На сколько лет после последней даты задач описываются переходы пояса в VTIMEZONE.
+	//Дальше клиент применяет последнее описанное смещение, но календарь подписки
+	//перечитывается регулярно, и описание продлевается вместе с ним
+	zoneYears = 5
+)
+
+// Виды компонентов календаря
+const (
+	KindEvent = "event"
+	KindTodo  = "todo"
+)
+
+// Options — параметры календаря
+type Options struct {
+	//Название календаря, отображаемое клиентом
+	Name string
+	//KindEvent — задачи как события VEVENT, KindTodo — как задачи VTODO
+	Kind string
+	//Время формирования календаря, записывается в DTSTAMP
+	Now time.Time
+}
+
+// Write записывает задачи в w в формате iCalendar.
+// Задачи без времени становятся событиями на весь день, задачи со временем
+// и собственным поясом записываются с TZID и описанием пояса в VTIMEZONE, остальные — в UTC по сроку Due
+func Write(w io.Writer, tasks []*domain.Task, opts Options) error {
+	zones, err := taskZones(tasks, opts.Now)
+	if err != nil {
+		return err
+	}
+	cw := &writer{w: bufio.NewWriter(w)}
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:" + prodID)
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	if opts.Name != "" {
+		cw.line("X-WR-CALNAME:" + escape(opts.Name))
+	}
+	for _, zone := range zones {
+		cw.timezone(zone)
+	}
+	stamp := opts.Now.UTC().Format(utcForm)
+	for _, task := range tasks {
+		if err := cw.task(task, opts.Kind, stamp); err != nil {
+			return fmt.Errorf("задача %s: %w", task.ID, err)
+		}
+	}
+	cw.line("END:VCALENDAR")
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.w.Flush()
+}
+
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (cw *writer) task(task *domain.Task, kind, stamp string) error {
+	start, err := startProperty(task)
+	if err != nil {
+		return err
+	}
+	component := "VEVENT"
+	if kind == KindTodo {
+		component = "VTODO"
+	}
+	cw.line("BEGIN:" + component)
+	cw.line("UID:task-" + task.ID + "@todo_web")
+	cw.line("DTSTAMP:" + stamp)
+	cw.line("DTSTART" + start)
+	if kind == KindTodo {
+		cw.line("DUE" + start)
+		cw.line("STATUS:NEEDS-ACTION")
+	} else {
+		cw.line("TRANSP:TRANSPARENT")
+	}
+	cw.line("SUMMARY:" + escape(task.Title))
+	if task.Comment != "" {
+		cw.line("DESCRIPTION:" + escape(task.Comment))
+	}
+	if len(task.Tags) > 0 {
+		categories := make([]string, len(task.Tags))
+		for i, tag := range task.Tags {
+			categories[i] = escape(tag)
+		}
+		cw.line("CATEGORIES:" + strings.Join(categories, ","))
+	}
+	if task.Project != "" {
+		cw.line("X-TODO-PROJECT:" + escape(task.Project))
+	}
+	if p := priority(task.Priority); p > 0 {
+		cw.line("PRIORITY:" + strconv.Itoa(p))
+	}
+	if rule, ok := RRule(task.Repeat); ok {
+		cw.line("RRULE:" + rule)
+	}
+	cw.line("END:" + component)
+	return nil
+}
+
+// startProperty возвращает параметры и значение свойства DTSTART задачи
+func startProperty(task *domain.Task) (string, error) {
+	if task.Time == "" {
+		date, err := time.Parse(dateForm, task.Date)
+		if err != nil {
+			return "", err
+		}
+		return ";VALUE=DATE:" + date.Format(dateForm), nil
+	}
+	local, err := time.Parse(taskTimeForm, task.Date+" "+task.Time)
+	if err != nil {
+		return "", err
+	}
+	//Повторения в поясе задачи не смещаются при переходе на летнее время
+	if task.Timezone != "" {
+		return ";TZID=" + task.Timezone + ":" + local.Format(localForm), nil
+	}
+	due, err := time.Parse(time.RFC3339, task.Due)
+	if err != nil {
+		return "", err
+	}
+	return ":" + due.UTC().Format(utcForm), nil
+}
+
+// zoneRange — пояс, используемый в TZID, и период, для которого нужны его переходы
+type zoneRange struct {
+	loc      *time.Location
+	from, to time.Time
+}
+
+// taskZones собирает пояса задач, которые записываются с TZID, в порядке первого появления.
+// Период пояса — от самой ранней даты его задач до zoneYears лет после самой поздней даты или now
+func taskZones(tasks []*domain.Task, now time.Time) ([]*zoneRange, error) {
+	var zones []*zoneRange
+	byName := make(map[string]*zoneRange)
+	for _, task := range tasks {
+		if task.Time == "" || task.Timezone == "" {
+			continue
+		}
+		//Ошибку в дате сообщит startProperty
+		date, err := time.Parse(dateForm, task.Date)
+		if err != nil {
+			continue
+		}
+		zone, ok := byName[task.Timezone]
+		if !ok {
+			loc, err := time.LoadLocation(task.Timezone)
+			if err != nil {
+				return nil, fmt.Errorf("задача %s: %w", task.ID, err)
+			}
+			zone = &zoneRange{loc: loc, from: date, to: now}
+			byName[task.Timezone] = zone
+			zones = append(zones, zone)
+		}
+		if date.Before(zone.from) {
+			zone.from = date
+		}
+		if date.After(zone.to) {
+			zone.to = date
+		}
+	}
+	return zones, nil
+}
+
+// timezone записывает VTIMEZONE: смещение, действующее в начале периода пояса,
+// и все переходы до его конца. Границы смещений берутся из базы часовых поясов Go
+func (cw *writer) timezone(zone *zoneRange) {
+	cw.line("BEGIN:VTIMEZONE")
+	cw.line("TZID:" + zone.loc.String())
+	end := zone.to.AddDate(zoneYears, 0, 0)
+	for t := zone.from.AddDate(0, 0, -1).In(zone.loc); ; {
+		name, offset := t.Zone()
+		start, next := t.ZoneBounds()
+		//Смещение, действующее с начала времён, описывается одним наблюдением с 1970 года
+		onset, from := time.Unix(0, 0).UTC(), offset
+		if !start.IsZero() {
+			_, from = start.Add(-time.Second).Zone()
+			onset = start.In(time.FixedZone("", from))
+		}
+		kind := "STANDARD"
+		if t.IsDST() {
+			kind = "DAYLIGHT"
+		}
+		cw.line("BEGIN:" + kind)
+		//Начало наблюдения записывается по местному времени до перехода
+		cw.line("DTSTART:" + onset.Format(localForm))
+		cw.line("TZOFFSETFROM:" + formatOffset(from))
+		cw.line("TZOFFSETTO:" + formatOffset(offset))
+		cw.line("TZNAME:" + escape(name))
+		cw.line("END:" + kind)
+		if next.IsZero() || !next.Before(end) {
+			break
+		}
+		t = next
+	}
+	cw.line("END:VTIMEZONE")
+}
+
+// formatOffset записывает смещение от UTC в секундах как ±ЧЧММ[СС]
+func formatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	res := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset/60%60)
+	if offset%60 != 0 {
+		res += fmt.Sprintf("%02d", offset%60)
+	}
+	return res
+}
+
+// priority переводит приоритет задачи в шкалу PRIORITY: 1 — высший, 9 — низший
+func priority(p int) int {
+	switch p {
+	case domain.PriorityHigh:
+		return 1
+	case domain.PriorityMedium:
+		return 5
+	case domain.PriorityLow:
+		return 9
+	}
+	return 0
+}
+
+// line записывает строку содержимого, перенося её по 75 октетов, как требует RFC 5545
+func (cw *writer) line(s string) {
+	if cw.err != nil {
+		return
+	}
+	var b strings.Builder
+	limit := lineLimit
+	for len(s) > limit {
+		cut := limit
+		//Не разрываем многобайтовый символ UTF-8
+		for !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		//Пробел в начале продолжения входит в лимит строки
+		limit = lineLimit - 1
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+	_, cw.err = cw.w.WriteString(b.String())
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escape экранирует значение типа TEXT
+func escape(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// RRule переводит правило повторения задачи в RRULE.
+// Правила RRULE возвращаются без префикса, сокращённые переводятся:
+// «y» — FREQ=YEARLY, «d N» — FREQ=DAILY;INTERVAL=N, «w 1,3» — FREQ=WEEKLY;BYDAY=MO,WE,
+// «m 1,-1 [1,6]» — FREQ=MONTHLY;BYMONTHDAY=1,-1[;BYMONTH=1,6].
+// Второе значение равно false, если правило пустое или не переводится
+func RRule(repeat string) (string, bool) {
+	switch {
+	case repeat == "":
+		return "", false
+	case recurrence.IsRRule(repeat):
+		if _, err := recurrence.Parse(repeat); err != nil {
+			return "", false
+		}
+		return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(repeat)), "RRULE:"), true
+	case repeat == "y":
+		return "FREQ=YEARLY", true
+	case strings.HasPrefix(repeat, "d "):
+		days, err := strconv.Atoi(strings.TrimPrefix(repeat, "d "))
+		if err != nil || days <= 0 || days > 400 {
+			return "", false
+		}
+		if days == 1 {
+			return "FREQ=DAILY", true
+		}
+		return "FREQ=DAILY;INTERVAL=" + strconv.Itoa(days), true
+	case strings.HasPrefix(repeat, "w "):
+		weekdays := []string{"MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+		var days []string
+		for _, s := range strings.Split(strings.TrimPrefix(repeat, "w "), ",") {
+			day, err := strconv.Atoi(s)
+			if err != nil || day < 1 || day > 7 {
+				return "", false
+			}
+			days = append(days, weekdays[day-1])
+		}
+		return "FREQ=WEEKLY;BYDAY=" + strings.Join(days, ","), true
+	case strings.HasPrefix(repeat, "m "):
+		parts := strings.Split(strings.TrimPrefix(repeat, "m "), " ")
+		if len(parts) > 2 {
+			return "", false
+		}
+		rule := "FREQ=MONTHLY;BYMONTHDAY="
+		days, ok := numbers(parts[0], -2, 31)
+		if !ok {
+			return "", false
+		}
+		rule += days
+		if len(parts) == 2 {
+			months, ok := numbers(parts[1], 1, 12)
+			if !ok {
+				return "", false
+			}
+			rule += ";BYMONTH=" + months
+		}
+		return rule, true
+	}
+	return "", false
+}
+
+// numbers проверяет список чисел через запятую в диапазоне low..high без нуля
+func numbers(list string, low, high int) (string, bool) {
+	for _, s := range strings.Split(list, ",") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n == 0 || n < low || n > high {
+			return "", false
+		}
+	}
+	return list, true
+}
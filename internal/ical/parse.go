@@ -0,0 +1,371 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/recurrence"
+)
+
+// Максимальная длина развёрнутой строки содержимого
+const maxLine = 1 << 20
+
+var ErrNotCalendar = errors.New("файл не является календарём iCalendar")
+
+// property — строка содержимого NAME;PARAM=VALUE:value
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse читает календарь и переводит компоненты VTODO и VEVENT в задачи.
+// Каждому компоненту соответствует запись отчёта: с задачей, пропущенная
+// (выполненные и отменённые) или с ошибкой разбора.
+// Ошибка возвращается, только если весь файл не удаётся прочитать как календарь
+func Parse(r io.Reader) ([]*domain.ImportItem, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		items      []*domain.ImportItem
+		calendar   bool
+		depth      int
+		component  string
+		properties []property
+	)
+	for n, line := range lines {
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: строка %d: %v", ErrNotCalendar, n+1, err)
+		}
+		switch prop.name {
+		case "BEGIN":
+			value := strings.ToUpper(prop.value)
+			switch {
+			case depth == 0 && value == "VCALENDAR":
+				calendar = true
+			case depth == 1 && (value == "VTODO" || value == "VEVENT"):
+				component = value
+				properties = nil
+			}
+			depth++
+		case "END":
+			depth--
+			if depth == 1 && component != "" {
+				items = append(items, toItem(len(items)+1, component, properties))
+				component = ""
+			}
+		default:
+			//Свойства вложенных компонентов, например VALARM, не нужны
+			if component != "" && depth == 2 {
+				properties = append(properties, prop)
+			}
+		}
+	}
+	if !calendar {
+		return nil, ErrNotCalendar
+	}
+	return items, nil
+}
+
+// unfold читает строки содержимого и склеивает перенесённые строки
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotCalendar, err)
+	}
+	return lines, nil
+}
+
+// parseLine разбирает строку содержимого. Двоеточие внутри значения параметра в кавычках
+// не отделяет значение свойства
+func parseLine(line string) (property, error) {
+	prop := property{params: make(map[string]string)}
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return prop, fmt.Errorf("нет значения в %q", line)
+	}
+	prop.value = line[colon+1:]
+	parts := strings.Split(line[:colon], ";")
+	prop.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return prop, nil
+}
+
+// toItem переводит свойства компонента в задачу
+func toItem(index int, component string, properties []property) *domain.ImportItem {
+	item := &domain.ImportItem{Index: index}
+	task := &domain.Task{}
+	var start, due *property
+	var rrule string
+	for i := range properties {
+		prop := &properties[i]
+		switch prop.name {
+		case "UID":
+			item.Ref = prop.value
+		case "SUMMARY":
+			task.Title = unescape(prop.value)
+		case "DESCRIPTION":
+			task.Comment = unescape(prop.value)
+		case "CATEGORIES":
+			task.Tags = append(task.Tags, splitText(prop.value)...)
+		case "X-TODO-PROJECT":
+			task.Project = unescape(prop.value)
+		case "PRIORITY":
+			p, _ := strconv.Atoi(prop.value)
+			task.Priority = fromPriority(p)
+		case "DTSTART":
+			start = prop
+		case "DUE":
+			due = prop
+		case "RRULE":
+			rrule = prop.value
+		case "STATUS":
+			switch strings.ToUpper(prop.value) {
+			case "COMPLETED", "CANCELLED":
+				item.Status = domain.ImportSkipped
+				item.Error = "задача выполнена или отменена"
+			}
+		}
+	}
+	item.Title = task.Title
+	if item.Status != "" {
+		return item
+	}
+
+	//Для VTODO срок задаёт DUE, повторения отсчитываются от DTSTART
+	date := start
+	if component == "VTODO" && due != nil && (start == nil || rrule == "") {
+		date = due
+	}
+	var dtstart time.Time
+	if date != nil {
+		var err error
+		if dtstart, err = setDate(task, date); err != nil {
+			item.Status = domain.ImportFailed
+			item.Error = err.Error()
+			return item
+		}
+	}
+	if rrule != "" {
+		repeat, err := Repeat(rrule, dtstart)
+		if err != nil {
+			item.Status = domain.ImportFailed
+			item.Error = err.Error()
+			return item
+		}
+		task.Repeat = repeat
+	}
+	item.Task = task
+	return item
+}
+
+// setDate заполняет дату, время и часовой пояс задачи по значению DATE или DATE-TIME
+// и возвращает начало повторений
+func setDate(task *domain.Task, prop *property) (time.Time, error) {
+	value := prop.value
+	if prop.params["VALUE"] == "DATE" || len(value) == len(dateForm) {
+		t, err := time.Parse(dateForm, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("неверная дата %q", value)
+		}
+		task.Date = t.Format(dateForm)
+		return t, nil
+	}
+	var t time.Time
+	var err error
+	switch {
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse(utcForm, value)
+		task.Timezone = "UTC"
+	case prop.params["TZID"] != "":
+		//Пояса не из базы IANA (например, имена Windows) не переносятся,
+		//время остаётся местным временем пользователя
+		loc, lerr := time.LoadLocation(prop.params["TZID"])
+		if lerr != nil || prop.params["TZID"] == "Local" {
+			loc = time.UTC
+		} else {
+			task.Timezone = prop.params["TZID"]
+		}
+		t, err = time.ParseInLocation(localForm, value, loc)
+	default:
+		t, err = time.Parse(localForm, value)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверные дата и время %q", value)
+	}
+	task.Date = t.Format(dateForm)
+	task.Time = t.Format("15:04")
+	return t, nil
+}
+
+// fromPriority переводит PRIORITY (1 — высший, 9 — низший, 0 — не задан) в приоритет задачи
+func fromPriority(p int) int {
+	switch {
+	case p >= 1 && p <= 4:
+		return domain.PriorityHigh
+	case p == 5:
+		return domain.PriorityMedium
+	case p >= 6 && p <= 9:
+		return domain.PriorityLow
+	}
+	return domain.PriorityNone
+}
+
+// unescape восстанавливает значение типа TEXT
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// splitText разбирает список значений TEXT через запятую с учётом экранирования
+func splitText(s string) []string {
+	var values []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, unescape(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, unescape(s[start:]))
+}
+
+// Repeat переводит RRULE в правило повторения задачи. Правила, для которых есть
+// равнозначное сокращённое, заменяются им: FREQ=DAILY;INTERVAL=N — «d N»,
+// FREQ=WEEKLY;BYDAY=MO,WE — «w 1,3», FREQ=MONTHLY;BYMONTHDAY=1,-1 — «m 1,-1», FREQ=YEARLY — «y».
+// Недостающие дни недели и месяца берутся из dtstart. Остальные правила сохраняются
+// в формате RRULE, если планировщик их поддерживает, иначе возвращается ошибка
+func Repeat(rrule string, dtstart time.Time) (string, error) {
+	rule, err := recurrence.Parse(rrule)
+	if err != nil {
+		return "", err
+	}
+	normalized := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rrule)), "RRULE:")
+	if rule.Count > 0 || !rule.Until.IsZero() || len(rule.BySetPos) > 0 {
+		return normalized, nil
+	}
+	switch rule.Freq {
+	case recurrence.Daily:
+		if len(rule.ByDay)+len(rule.ByMonthDay)+len(rule.ByMonth) == 0 && rule.Interval <= 400 {
+			return "d " + strconv.Itoa(rule.Interval), nil
+		}
+	case recurrence.Weekly:
+		if len(rule.ByMonth) > 0 {
+			break
+		}
+		if len(rule.ByDay) == 0 {
+			if rule.Interval == 1 && !dtstart.IsZero() {
+				return "w " + strconv.Itoa(isoWeekday(dtstart.Weekday())), nil
+			}
+			if rule.Interval*7 <= 400 {
+				return "d " + strconv.Itoa(rule.Interval*7), nil
+			}
+			break
+		}
+		if rule.Interval != 1 {
+			break
+		}
+		days := make([]string, 0, len(rule.ByDay))
+		for _, day := range rule.ByDay {
+			if day.N != 0 {
+				return normalized, nil
+			}
+			days = append(days, strconv.Itoa(isoWeekday(day.Weekday)))
+		}
+		return "w " + strings.Join(days, ","), nil
+	case recurrence.Monthly:
+		if rule.Interval != 1 || len(rule.ByDay) > 0 {
+			break
+		}
+		days := make([]string, 0, len(rule.ByMonthDay))
+		for _, day := range rule.ByMonthDay {
+			if day < -2 {
+				return normalized, nil
+			}
+			days = append(days, strconv.Itoa(day))
+		}
+		if len(days) == 0 {
+			if dtstart.IsZero() {
+				break
+			}
+			days = append(days, strconv.Itoa(dtstart.Day()))
+		}
+		repeat := "m " + strings.Join(days, ",")
+		if len(rule.ByMonth) > 0 {
+			months := make([]string, len(rule.ByMonth))
+			for i, month := range rule.ByMonth {
+				months[i] = strconv.Itoa(month)
+			}
+			repeat += " " + strings.Join(months, ",")
+		}
+		return repeat, nil
+	case recurrence.Yearly:
+		if rule.Interval == 1 && len(rule.ByDay)+len(rule.ByMonthDay)+len(rule.ByMonth) == 0 {
+			return "y", nil
+		}
+	}
+	return normalized, nil
+}
+
+// isoWeekday возвращает номер дня недели с понедельника (1) по воскресенье (7)
+func isoWeekday(wd time.Weekday) int {
+	if wd == time.Sunday {
+		return 7
+	}
+	return int(wd)
+}
@@ -0,0 +1,230 @@
+// Package query разбирает язык запросов поиска задач и переводит его в условия domain.Filter.
+//
+// Запрос состоит из условий через пробел, задача должна соответствовать всем:
+//
+//	title:отчёт repeat:w before:20251201 -comment:черновик is:recurring
+//
+// Слово без поля ищется в заголовке и комментарии. Значение с пробелами берётся в кавычки:
+// title:"квартальный отчёт". Минус перед условием исключает подходящие задачи.
+// Поля: title, comment, repeat (начало правила), tag, project, priority (не ниже указанного),
+// date, before и after (20060102 или 02.01.2006, before и after не включают дату),
+// is:recurring, is:done и is:active. Исключать нельзя только priority и даты.
+// Слово с двоеточием, которое не начинается с имени поля (Re:, http://…, 18:00), ищется как текст.
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+const dateForm = "20060102"
+
+// fields — имена полей запроса. Другое слово с двоеточием ищется как текст
+var fields = map[string]bool{
+	"title": true, "comment": true, "repeat": true, "tag": true, "project": true,
+	"priority": true, "date": true, "before": true, "after": true, "is": true,
+}
+
+// Error — ошибка в запросе, Pos — номер символа запроса, начиная с 1
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("позиция %d: %s", e.Pos, e.Msg)
+}
+
+// term — одно условие запроса. pos и valuePos — позиции условия и его значения
+type term struct {
+	pos      int
+	valuePos int
+	not      bool
+	field    string
+	value    string
+}
+
+// Compile разбирает запрос и дополняет им фильтр. Слова без поля заменяют filter.SearchTerm,
+// остальные условия добавляются к заданным в фильтре. При ошибке возвращается *Error
+func Compile(input string, filter *domain.Filter) error {
+	terms, err := parse([]rune(input))
+	if err != nil {
+		return err
+	}
+	var words []string
+	for _, t := range terms {
+		if err = t.apply(filter, &words); err != nil {
+			return err
+		}
+	}
+	filter.SearchTerm = strings.Join(words, " ")
+	return nil
+}
+
+func parse(runes []rune) ([]term, error) {
+	var terms []term
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		t := term{pos: i + 1}
+		if runes[i] == '-' {
+			t.not = true
+			i++
+		}
+		//Поле — известное имя перед двоеточием, иначе двоеточие — часть слова, как в 18:00 или Re:
+		j := i
+		for j < len(runes) && (runes[j] >= 'a' && runes[j] <= 'z' || runes[j] >= 'A' && runes[j] <= 'Z') {
+			j++
+		}
+		if name := strings.ToLower(string(runes[i:j])); j < len(runes) && runes[j] == ':' && fields[name] {
+			t.field = name
+			i = j + 1
+		}
+		t.valuePos = i + 1
+		value, next, err := readValue(runes, i)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(value) == "" {
+			switch {
+			case t.field != "":
+				return nil, &Error{Pos: t.valuePos, Msg: fmt.Sprintf("не указано значение поля %s", t.field)}
+			case t.not:
+				return nil, &Error{Pos: t.pos, Msg: "после минуса нет условия"}
+			default:
+				return nil, &Error{Pos: t.pos, Msg: "пустые кавычки"}
+			}
+		}
+		t.value = value
+		i = next
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+// readValue читает значение с позиции i до пробела или в кавычках и возвращает позицию после него
+func readValue(runes []rune, i int) (string, int, error) {
+	if i < len(runes) && runes[i] == '"' {
+		for end := i + 1; end < len(runes); end++ {
+			if runes[end] != '"' {
+				continue
+			}
+			if end+1 < len(runes) && !unicode.IsSpace(runes[end+1]) {
+				return "", 0, &Error{Pos: end + 2, Msg: "после закрывающей кавычки ожидается пробел"}
+			}
+			return string(runes[i+1 : end]), end + 1, nil
+		}
+		return "", 0, &Error{Pos: i + 1, Msg: "нет закрывающей кавычки"}
+	}
+	j := i
+	for j < len(runes) && !unicode.IsSpace(runes[j]) {
+		j++
+	}
+	return string(runes[i:j]), j, nil
+}
+
+func (t term) apply(filter *domain.Filter, words *[]string) error {
+	switch t.field {
+	case "":
+		if !t.not {
+			*words = append(*words, strings.Fields(t.value)...)
+			return nil
+		}
+		t.condition(filter, domain.FieldText, t.value)
+	case "title", "comment":
+		t.condition(filter, t.field, t.value)
+	case "repeat":
+		t.condition(filter, domain.FieldRepeat, strings.ToLower(t.value))
+	case "tag":
+		tag := strings.ToLower(strings.TrimSpace(t.value))
+		if !t.not {
+			filter.Tags = append(filter.Tags, tag)
+			return nil
+		}
+		t.condition(filter, domain.FieldTag, tag)
+	case "project":
+		if !t.not {
+			filter.Project = t.value
+			return nil
+		}
+		t.condition(filter, domain.FieldProject, strings.TrimSpace(t.value))
+	case "is":
+		return t.applyIs(filter)
+	case "priority":
+		if t.not {
+			return t.notNegatable()
+		}
+		p, err := strconv.Atoi(t.value)
+		if err != nil {
+			return &Error{Pos: t.valuePos, Msg: "приоритет должен быть числом от 0 до 3"}
+		}
+		filter.Priority = p
+	case "date", "before", "after":
+		if t.not {
+			return t.notNegatable()
+		}
+		return t.applyDate(filter)
+	}
+	return nil
+}
+
+func (t term) condition(filter *domain.Filter, field, value string) {
+	filter.Conditions = append(filter.Conditions, domain.Condition{Field: field, Value: value, Not: t.not})
+}
+
+func (t term) notNegatable() error {
+	return &Error{Pos: t.pos, Msg: fmt.Sprintf("условие %s нельзя исключить", t.field)}
+}
+
+func (t term) applyIs(filter *domain.Filter) error {
+	switch strings.ToLower(t.value) {
+	case "recurring":
+		t.condition(filter, domain.FieldRecurring, "")
+	case domain.StatusDone:
+		filter.Status = domain.StatusDone
+		if t.not {
+			filter.Status = domain.StatusActive
+		}
+	case domain.StatusActive:
+		filter.Status = domain.StatusActive
+		if t.not {
+			filter.Status = domain.StatusDone
+		}
+	default:
+		return &Error{Pos: t.valuePos, Msg: "ожидается is:recurring, is:done или is:active"}
+	}
+	return nil
+}
+
+// applyDate сужает диапазон дат фильтра: before и after не включают указанную дату
+func (t term) applyDate(filter *domain.Filter) error {
+	date, err := time.Parse(dateForm, t.value)
+	if err != nil {
+		date, err = time.Parse("02.01.2006", t.value)
+	}
+	if err != nil {
+		return &Error{Pos: t.valuePos, Msg: "дата должна быть в формате 20060102 или 02.01.2006"}
+	}
+	switch t.field {
+	case "date":
+		filter.Date = date.Format(dateForm)
+	case "before":
+		to := date.AddDate(0, 0, -1).Format(dateForm)
+		if filter.To == "" || to < filter.To {
+			filter.To = to
+		}
+	case "after":
+		from := date.AddDate(0, 0, 1).Format(dateForm)
+		if from > filter.From {
+			filter.From = from
+		}
+	}
+	return nil
+}
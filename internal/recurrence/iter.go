@@ -0,0 +1,332 @@
+package recurrence
+
+import (
+	"sort"
+	"time"
+)
+
+// Максимальное число подряд идущих периодов без повторений,
+// после которого правило считается исчерпанным (например, FREQ=MONTHLY;BYMONTHDAY=31;BYMONTH=2).
+const maxEmptyPeriods = 5000
+
+// Iterate перебирает повторения правила по порядку, пока fn возвращает true.
+// Первым повторением всегда считается dtstart, n — порядковый номер повторения начиная с 1.
+func (r *Rule) Iterate(dtstart time.Time, fn func(n int, t time.Time) bool) {
+	r.iterate(dtstart, 0, fn)
+}
+
+// Next возвращает первое повторение правила строго после after.
+// Если повторений больше нет, второе значение равно false.
+func (r *Rule) Next(dtstart, after time.Time) (time.Time, bool) {
+	var res time.Time
+	found := false
+	startPeriod := 0
+	//Без COUNT номера повторений не важны, поэтому можно пропустить периоды до after
+	if r.Count == 0 && after.After(dtstart) {
+		startPeriod = r.periodsBetween(dtstart, after)/r.Interval - 1
+		if startPeriod < 0 {
+			startPeriod = 0
+		}
+	}
+	r.iterate(dtstart, startPeriod, func(_ int, t time.Time) bool {
+		if t.After(after) {
+			res, found = t, true
+			return false
+		}
+		return true
+	})
+	return res, found
+}
+
+// Between возвращает повторения правила в интервале [from, to].
+func (r *Rule) Between(dtstart, from, to time.Time) []time.Time {
+	var res []time.Time
+	r.Iterate(dtstart, func(_ int, t time.Time) bool {
+		if t.After(to) {
+			return false
+		}
+		if !t.Before(from) {
+			res = append(res, t)
+		}
+		return true
+	})
+	return res
+}
+
+// Remaining возвращает копию правила, в которой COUNT уменьшен на число повторений,
+// предшествующих next. Так правило остаётся верным, когда дата задачи (DTSTART)
+// переносится на следующее повторение.
+func (r *Rule) Remaining(dtstart, next time.Time) *Rule {
+	res := *r
+	if r.Count == 0 {
+		return &res
+	}
+	passed := 0
+	r.Iterate(dtstart, func(n int, t time.Time) bool {
+		if !t.Before(next) {
+			return false
+		}
+		passed = n
+		return true
+	})
+	res.Count -= passed
+	if res.Count < 1 {
+		res.Count = 1
+	}
+	return &res
+}
+
+func (r *Rule) iterate(dtstart time.Time, startPeriod int, fn func(n int, t time.Time) bool) {
+	until, hasUntil := r.untilIn(dtstart.Location())
+	n := 0
+	emit := func(t time.Time) bool {
+		if hasUntil && t.After(until) {
+			return false
+		}
+		if r.Count > 0 && n >= r.Count {
+			return false
+		}
+		n++
+		return fn(n, t)
+	}
+
+	if startPeriod == 0 && !emit(dtstart) {
+		return
+	}
+	empty := 0
+	for k := startPeriod; empty < maxEmptyPeriods; k++ {
+		candidates := r.expand(dtstart, k*r.Interval)
+		if len(candidates) == 0 {
+			empty++
+		} else {
+			empty = 0
+		}
+		for _, t := range candidates {
+			if t.Year() > 9999 {
+				return
+			}
+			if !t.After(dtstart) {
+				continue
+			}
+			if !emit(t) {
+				return
+			}
+		}
+	}
+}
+
+func (r *Rule) untilIn(loc *time.Location) (time.Time, bool) {
+	if r.Until.IsZero() {
+		return time.Time{}, false
+	}
+	u := r.Until
+	switch {
+	case r.UntilDate:
+		//Дата без времени включает весь день
+		return time.Date(u.Year(), u.Month(), u.Day(), 23, 59, 59, 999999999, loc), true
+	case u.Location() == time.UTC:
+		return u, true
+	default:
+		return time.Date(u.Year(), u.Month(), u.Day(), u.Hour(), u.Minute(), u.Second(), 0, loc), true
+	}
+}
+
+// periodsBetween возвращает число периодов частоты FREQ между dtstart и t.
+func (r *Rule) periodsBetween(dtstart, t time.Time) int {
+	switch r.Freq {
+	case Daily:
+		return daysBetween(civil(dtstart), civil(t))
+	case Weekly:
+		return daysBetween(r.weekStart(civil(dtstart)), r.weekStart(civil(t))) / 7
+	case Monthly:
+		return (t.Year()-dtstart.Year())*12 + int(t.Month()) - int(dtstart.Month())
+	default:
+		return t.Year() - dtstart.Year()
+	}
+}
+
+// expand возвращает отсортированные повторения периода со смещением offset
+// (в днях, неделях, месяцах или годах от периода dtstart).
+func (r *Rule) expand(dtstart time.Time, offset int) []time.Time {
+	start := civil(dtstart)
+	var days []time.Time
+
+	switch r.Freq {
+	case Daily:
+		d := start.AddDate(0, 0, offset)
+		if r.matchMonth(d.Month()) && r.matchMonthDay(d) && r.matchWeekday(d) {
+			days = append(days, d)
+		}
+	case Weekly:
+		ws := r.weekStart(start).AddDate(0, 0, 7*offset)
+		for i := 0; i < 7; i++ {
+			d := ws.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && d.Weekday() != start.Weekday() {
+				continue
+			}
+			if r.matchWeekday(d) && r.matchMonth(d.Month()) {
+				days = append(days, d)
+			}
+		}
+	case Monthly:
+		m := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, offset, 0)
+		if r.matchMonth(m.Month()) {
+			days = r.monthDays(m.Year(), m.Month(), start.Day())
+		}
+	case Yearly:
+		days = r.yearDays(start.Year()+offset, start)
+	}
+
+	days = r.applySetPos(days)
+	res := make([]time.Time, 0, len(days))
+	for _, d := range days {
+		res = append(res, time.Date(d.Year(), d.Month(), d.Day(),
+			dtstart.Hour(), dtstart.Minute(), dtstart.Second(), dtstart.Nanosecond(), dtstart.Location()))
+	}
+	return res
+}
+
+func (r *Rule) monthDays(year int, month time.Month, defaultDay int) []time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1)
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if defaultDay > last.Day() {
+			return nil
+		}
+		return []time.Time{first.AddDate(0, 0, defaultDay-1)}
+	}
+	return r.filterDays(first, last)
+}
+
+func (r *Rule) yearDays(year int, start time.Time) []time.Time {
+	var days []time.Time
+	if len(r.ByMonth) > 0 {
+		for _, m := range r.ByMonth {
+			days = append(days, r.monthDays(year, time.Month(m), start.Day())...)
+		}
+		sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+		return days
+	}
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		d := time.Date(year, start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		if d.Month() != start.Month() {
+			return nil
+		}
+		return []time.Time{d}
+	}
+	return r.filterDays(time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC))
+}
+
+// filterDays отбирает дни интервала [first, last], подходящие под BYMONTHDAY и BYDAY.
+// Порядковые номера BYDAY считаются относительно этого интервала.
+func (r *Rule) filterDays(first, last time.Time) []time.Time {
+	var byDay map[time.Time]bool
+	if len(r.ByDay) > 0 {
+		byDay = make(map[time.Time]bool)
+		for _, wd := range r.ByDay {
+			var matches []time.Time
+			for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+				if d.Weekday() == wd.Weekday {
+					matches = append(matches, d)
+				}
+			}
+			switch {
+			case wd.N == 0:
+				for _, d := range matches {
+					byDay[d] = true
+				}
+			case wd.N > 0 && wd.N <= len(matches):
+				byDay[matches[wd.N-1]] = true
+			case wd.N < 0 && -wd.N <= len(matches):
+				byDay[matches[len(matches)+wd.N]] = true
+			}
+		}
+	}
+
+	var days []time.Time
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		if byDay != nil && !byDay[d] {
+			continue
+		}
+		if len(r.ByMonthDay) > 0 && !r.matchMonthDay(d) {
+			continue
+		}
+		days = append(days, d)
+	}
+	return days
+}
+
+func (r *Rule) applySetPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(days) == 0 {
+		return days
+	}
+	picked := make(map[int]bool)
+	for _, p := range r.BySetPos {
+		i := p - 1
+		if p < 0 {
+			i = len(days) + p
+		}
+		if i >= 0 && i < len(days) {
+			picked[i] = true
+		}
+	}
+	res := make([]time.Time, 0, len(picked))
+	for i, d := range days {
+		if picked[i] {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+func (r *Rule) matchMonth(m time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, v := range r.ByMonth {
+		if time.Month(v) == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchMonthDay(d time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, v := range r.ByMonthDay {
+		if v == d.Day() || (v < 0 && last+v+1 == d.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchWeekday(d time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Weekday == d.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) weekStart(d time.Time) time.Time {
+	shift := (int(d.Weekday()) - int(r.WeekStart) + 7) % 7
+	return d.AddDate(0, 0, -shift)
+}
+
+// civil возвращает календарную дату t в UTC без времени.
+func civil(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(a, b time.Time) int {
+	//Через Unix-секунды, так как time.Duration переполняется на интервалах больше ~290 лет
+	return int((b.Unix() - a.Unix()) / 86400)
+}
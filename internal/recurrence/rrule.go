@@ -0,0 +1,307 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency — частота повторения правила (FREQ).
+type Frequency int
+
+const (
+	Daily Frequency = iota + 1
+	Weekly
+	Monthly
+	Yearly
+)
+
+var freqNames = map[Frequency]string{
+	Daily:   "DAILY",
+	Weekly:  "WEEKLY",
+	Monthly: "MONTHLY",
+	Yearly:  "YEARLY",
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+const rrulePrefix = "RRULE:"
+
+// WeekdayNum — элемент BYDAY: день недели с необязательным порядковым номером
+// (2TU — второй вторник, -1FR — последняя пятница периода).
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// Rule — правило повторения в формате RFC 5545 (RRULE).
+// Поддерживаются частоты DAILY, WEEKLY, MONTHLY, YEARLY и части
+// INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH, BYSETPOS, WKST.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	UntilDate  bool
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+// IsRRule сообщает, записано ли правило повторения в формате RRULE,
+// а не в сокращённом формате планировщика (d, w, m, y).
+func IsRRule(s string) bool {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	return strings.HasPrefix(s, rrulePrefix) || strings.HasPrefix(s, "FREQ=") || strings.Contains(s, ";FREQ=")
+}
+
+// Parse разбирает строку RRULE, префикс "RRULE:" необязателен.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= len(rrulePrefix) && strings.EqualFold(s[:len(rrulePrefix)], rrulePrefix) {
+		s = s[len(rrulePrefix):]
+	}
+	if s == "" {
+		return nil, errors.New("пустое правило RRULE")
+	}
+
+	r := &Rule{Interval: 1, WeekStart: time.Monday}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("неверная часть правила RRULE: %q", part)
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if seen[key] {
+			return nil, fmt.Errorf("повторяющаяся часть правила RRULE: %s", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			err = r.parseFreq(value)
+		case "INTERVAL":
+			r.Interval, err = parseInt(value, 1, 1000)
+		case "COUNT":
+			r.Count, err = parseInt(value, 1, 10000)
+		case "UNTIL":
+			err = r.parseUntil(value)
+		case "BYDAY":
+			err = r.parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseIntList(value, -31, 31)
+		case "BYMONTH":
+			r.ByMonth, err = parseIntList(value, 1, 12)
+		case "BYSETPOS":
+			r.BySetPos, err = parseIntList(value, -366, 366)
+		case "WKST":
+			wd, ok := weekdayNames[value]
+			if !ok {
+				err = fmt.Errorf("неверный день недели %q", value)
+			}
+			r.WeekStart = wd
+		default:
+			err = fmt.Errorf("часть правила %s не поддерживается", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("RRULE %s: %w", key, err)
+		}
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Rule) parseFreq(value string) error {
+	for f, name := range freqNames {
+		if name == value {
+			r.Freq = f
+			return nil
+		}
+	}
+	switch value {
+	case "SECONDLY", "MINUTELY", "HOURLY":
+		return fmt.Errorf("частота %s не поддерживается, минимальный шаг — день", value)
+	}
+	return fmt.Errorf("неизвестная частота %q", value)
+}
+
+func (r *Rule) parseUntil(value string) error {
+	var err error
+	switch {
+	case len(value) == 8:
+		r.Until, err = time.Parse("20060102", value)
+		r.UntilDate = true
+	case strings.HasSuffix(value, "Z"):
+		r.Until, err = time.Parse("20060102T150405Z", value)
+	default:
+		r.Until, err = time.Parse("20060102T150405", value)
+	}
+	if err != nil {
+		return fmt.Errorf("неверный формат даты %q", value)
+	}
+	return nil
+}
+
+func (r *Rule) parseByDay(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return fmt.Errorf("неверный день недели %q", item)
+		}
+		wd, ok := weekdayNames[item[len(item)-2:]]
+		if !ok {
+			return fmt.Errorf("неверный день недели %q", item)
+		}
+		n := 0
+		if prefix := item[:len(item)-2]; prefix != "" {
+			var err error
+			n, err = strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return fmt.Errorf("неверный порядковый номер дня %q", item)
+			}
+		}
+		r.ByDay = append(r.ByDay, WeekdayNum{N: n, Weekday: wd})
+	}
+	return nil
+}
+
+func (r *Rule) validate() error {
+	if r.Freq == 0 {
+		return errors.New("в правиле RRULE не указана частота FREQ")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return errors.New("в правиле RRULE нельзя одновременно указывать COUNT и UNTIL")
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return errors.New("BYMONTHDAY нельзя использовать с FREQ=WEEKLY")
+	}
+	for _, d := range r.ByMonthDay {
+		if d == 0 {
+			return errors.New("BYMONTHDAY не может быть равен 0")
+		}
+	}
+	for _, p := range r.BySetPos {
+		if p == 0 {
+			return errors.New("BYSETPOS не может быть равен 0")
+		}
+	}
+	if len(r.BySetPos) > 0 && len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByMonth) == 0 {
+		return errors.New("BYSETPOS используется только вместе с другими частями BYxxx")
+	}
+	for _, wd := range r.ByDay {
+		if wd.N == 0 {
+			continue
+		}
+		if r.Freq != Monthly && r.Freq != Yearly {
+			return errors.New("порядковый номер в BYDAY допустим только для MONTHLY и YEARLY")
+		}
+		if r.Freq == Monthly && (wd.N > 5 || wd.N < -5) {
+			return errors.New("порядковый номер в BYDAY для MONTHLY должен быть от -5 до 5")
+		}
+	}
+	return nil
+}
+
+// String возвращает правило в каноническом виде без префикса "RRULE:".
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + freqNames[r.Freq]}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		switch {
+		case r.UntilDate:
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+		case r.Until.Location() == time.UTC:
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405Z"))
+		default:
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405"))
+		}
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			s := weekdayCode(wd.Weekday)
+			if wd.N != 0 {
+				s = strconv.Itoa(wd.N) + s
+			}
+			days = append(days, s)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayCode(r.WeekStart))
+	}
+	return strings.Join(parts, ";")
+}
+
+func weekdayCode(wd time.Weekday) string {
+	for code, d := range weekdayNames {
+		if d == wd {
+			return code
+		}
+	}
+	return ""
+}
+
+func parseInt(value string, min, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("значение %q вне диапазона %d..%d", value, min, max)
+	}
+	return n, nil
+}
+
+func parseIntList(value string, min, max int) ([]int, error) {
+	var res []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := parseInt(strings.TrimPrefix(item, "+"), min, max)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	sort.Ints(res)
+	return res, nil
+}
+
+func joinInts(values []int) string {
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		strs = append(strs, strconv.Itoa(v))
+	}
+	return strings.Join(strs, ",")
+}
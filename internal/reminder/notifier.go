@@ -0,0 +1,101 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Notifier доставляет напоминание по одному каналу.
+// Name различает каналы в журнале отправленных напоминаний
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, r Reminder) error
+}
+
+// LogNotifier пишет напоминания в журнал сервера
+type LogNotifier struct{}
+
+func (LogNotifier) Name() string { return "log" }
+
+func (LogNotifier) Notify(_ context.Context, r Reminder) error {
+	log.Printf("Напоминание: задача %s «%s», срок %s", r.TaskID, r.Title, r.Due)
+	return nil
+}
+
+// SMTPNotifier отправляет напоминания письмом
+type SMTPNotifier struct {
+	Addr     string //host:port
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+func (n *SMTPNotifier) Name() string { return "smtp" }
+
+func (n *SMTPNotifier) Notify(_ context.Context, r Reminder) error {
+	var auth smtp.Auth
+	if n.Username != "" {
+		host, _, err := net.SplitHostPort(n.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", n.Username, n.Password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Напоминание: "+r.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Задача: %s\r\nСрок: %s\r\n", r.Title, r.Due)
+	if r.Comment != "" {
+		fmt.Fprintf(&msg, "\r\n%s\r\n", r.Comment)
+	}
+	return smtp.SendMail(n.Addr, auth, n.From, n.To, msg.Bytes())
+}
+
+// WebhookNotifier отправляет напоминание JSON-запросом POST на заданный адрес
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n *WebhookNotifier) Name() string { return "webhook" }
+
+func (n *WebhookNotifier) Notify(ctx context.Context, r Reminder) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook ответил статусом %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Package reminder периодически просматривает ближайшие задачи и отправляет
+// напоминания за заданное время до срока выполнения.
+package reminder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+// Reminder — напоминание о задаче, которое получают уведомители
+type Reminder struct {
+	TaskID  string `json:"task_id"`
+	UserID  int64  `json:"user_id,omitempty"`
+	Title   string `json:"title"`
+	Comment string `json:"comment,omitempty"`
+	Due     string `json:"due"`
+	//Время до срока, за которое отправлено напоминание, например 1h0m0s
+	Before string `json:"before"`
+}
+
+// TaskSource возвращает задачи всех пользователей с датами от now до until,
+// у задач должно быть заполнено поле Due
+type TaskSource interface {
+	Upcoming(now, until time.Time) ([]*domain.Task, *domain.CustomError)
+}
+
+type Engine struct {
+	tasks     TaskSource
+	store     domain.ReminderRepository
+	notifiers []Notifier
+	offsets   []time.Duration
+	interval  time.Duration
+}
+
+// NewEngine создаёт планировщик напоминаний. offsets — за сколько до срока напоминать,
+// interval — как часто просматривать задачи
+func NewEngine(tasks TaskSource, store domain.ReminderRepository, notifiers []Notifier, offsets []time.Duration, interval time.Duration) *Engine {
+	sorted := append([]time.Duration(nil), offsets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return &Engine{
+		tasks:     tasks,
+		store:     store,
+		notifiers: notifiers,
+		offsets:   sorted,
+		interval:  interval,
+	}
+}
+
+// Start запускает просмотр задач в фоне до отмены ctx
+func (e *Engine) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := e.Tick(ctx, time.Now()); err != nil {
+				log.Printf("Ошибка отправки напоминаний: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Tick отправляет напоминания, время которых наступило к now, и возвращает их число.
+// Если подошло время нескольких смещений, отправляется только ближайшее к сроку,
+// остальные отмечаются отправленными
+func (e *Engine) Tick(ctx context.Context, now time.Time) (int, error) {
+	if len(e.offsets) == 0 {
+		return 0, nil
+	}
+	tasks, cErr := e.tasks.Upcoming(now, now.Add(e.offsets[len(e.offsets)-1]))
+	if cErr != nil {
+		return 0, fmt.Errorf("%v: %v", cErr.Err, cErr.ErrStorage)
+	}
+
+	sent := 0
+	for _, task := range tasks {
+		due, err := time.Parse(time.RFC3339, task.Due)
+		if err != nil || !due.After(now) {
+			continue
+		}
+		var dueOffsets []time.Duration
+		for _, offset := range e.offsets {
+			if !due.Add(-offset).After(now) {
+				dueOffsets = append(dueOffsets, offset)
+			}
+		}
+		if len(dueOffsets) == 0 {
+			continue
+		}
+		reminder := Reminder{
+			TaskID:  task.ID,
+			UserID:  task.UserID,
+			Title:   task.Title,
+			Comment: task.Comment,
+			Due:     task.Due,
+			Before:  dueOffsets[0].String(),
+		}
+		for _, n := range e.notifiers {
+			ok, err := e.notify(ctx, n, reminder, dueOffsets)
+			if err != nil {
+				log.Printf("Напоминание о задаче %s через %s не отправлено: %v", task.ID, n.Name(), err)
+				continue
+			}
+			if ok {
+				sent++
+			}
+		}
+	}
+	return sent, nil
+}
+
+// notify отправляет напоминание через один канал, если оно ещё не отправлялось
+func (e *Engine) notify(ctx context.Context, n Notifier, r Reminder, offsets []time.Duration) (bool, error) {
+	already, err := e.store.ReminderSent(r.TaskID, r.Due, offsets[0], n.Name())
+	if err != nil || already {
+		return false, err
+	}
+	if err = n.Notify(ctx, r); err != nil {
+		return false, err
+	}
+	for _, offset := range offsets {
+		if err = e.store.SaveReminder(r.TaskID, r.Due, offset, n.Name()); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// ParseOffsets разбирает список смещений через запятую: длительности Go (1h, 30m)
+// и дни с суффиксом d (1d)
+func ParseOffsets(list []string) ([]time.Duration, error) {
+	var res []time.Duration
+	for _, item := range list {
+		for _, part := range strings.Split(item, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			var offset time.Duration
+			if days, ok := strings.CutSuffix(part, "d"); ok {
+				n, err := strconv.Atoi(days)
+				if err != nil {
+					return nil, fmt.Errorf("неверное смещение напоминания: %s", part)
+				}
+				offset = time.Duration(n) * 24 * time.Hour
+			} else {
+				var err error
+				if offset, err = time.ParseDuration(part); err != nil {
+					return nil, fmt.Errorf("неверное смещение напоминания: %s", part)
+				}
+			}
+			if offset <= 0 {
+				return nil, fmt.Errorf("смещение напоминания должно быть положительным: %s", part)
+			}
+			res = append(res, offset)
+		}
+	}
+	return res, nil
+}
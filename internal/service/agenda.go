@@ -0,0 +1,147 @@
+package service
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+// agendaDays — период повестки по умолчанию, maxAgendaDays — наибольший период
+const (
+	agendaDays    int = 7
+	maxAgendaDays int = 92
+)
+
+// Agenda собирает повестку пользователя с from по to включительно, по умолчанию неделю от сегодняшнего дня.
+// Повторяющиеся задачи разворачиваются в повторения периода по тем же правилам, что и при выполнении задачи,
+// задачи без повторения с датой раньше сегодняшнего дня помечаются просроченными
+func (s *TaskService) Agenda(userID int64, from, to string) (*domain.Agenda, *domain.CustomError) {
+	now, cErr := s.now(&domain.Task{UserID: userID})
+	if cErr != nil {
+		return nil, cErr
+	}
+	today := now.Format(dateForm)
+	start, end, cErr := agendaRange(today, from, to)
+	if cErr != nil {
+		return nil, cErr
+	}
+	agenda := &domain.Agenda{
+		From:    start.Format(dateForm),
+		To:      end.Format(dateForm),
+		Overdue: make([]*domain.Task, 0),
+	}
+	days := make(map[string]*domain.AgendaDay)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		agendaDay := &domain.AgendaDay{Date: day.Format(dateForm), Tasks: make([]*domain.Task, 0)}
+		agenda.Days = append(agenda.Days, agendaDay)
+		days[agendaDay.Date] = agendaDay
+	}
+
+	tasks, err := s.repo.FindTask(&domain.Filter{UserID: userID, To: agenda.To})
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	var items []*domain.Task
+	for _, task := range tasks {
+		if task.Repeat == "" {
+			task.Overdue = task.Date < today
+			if task.Date < agenda.From {
+				if task.Overdue {
+					agenda.Overdue = append(agenda.Overdue, task)
+				}
+				continue
+			}
+			items = append(items, task)
+			continue
+		}
+		occurrences, err := s.occurrences(task, agenda.From, agenda.To)
+		if err != nil {
+			return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+		}
+		items = append(items, occurrences...)
+	}
+	if err = s.fillDue(append(items, agenda.Overdue...)); err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	for _, item := range items {
+		day := days[item.Date]
+		day.Tasks = append(day.Tasks, item)
+	}
+	//Внутри дня задачи идут как в списке по дате: сначала без времени, затем по времени и id
+	for _, day := range agenda.Days {
+		sort.Slice(day.Tasks, func(i, j int) bool {
+			a, b := day.Tasks[i], day.Tasks[j]
+			if a.Time != b.Time {
+				return a.Time < b.Time
+			}
+			aID, _ := strconv.Atoi(a.ID)
+			bID, _ := strconv.Atoi(b.ID)
+			return aID < bID
+		})
+	}
+	return agenda, nil
+}
+
+// agendaRange разбирает границы повестки: без from период начинается сегодня, без to длится agendaDays дней
+func agendaRange(today, from, to string) (time.Time, time.Time, *domain.CustomError) {
+	if from == "" {
+		from = today
+	}
+	start, err := time.Parse(dateForm, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, domain.NewCustomError(0, domain.ErrDate, err)
+	}
+	end := start.AddDate(0, 0, agendaDays-1)
+	if to != "" {
+		if end, err = time.Parse(dateForm, to); err != nil {
+			return time.Time{}, time.Time{}, domain.NewCustomError(0, domain.ErrDate, err)
+		}
+	}
+	if end.Before(start) || end.Sub(start) >= time.Duration(maxAgendaDays)*24*time.Hour {
+		return time.Time{}, time.Time{}, domain.NewCustomError(0, domain.ErrAgendaRange, nil)
+	}
+	return start, end, nil
+}
+
+// occurrences возвращает повторения задачи с from по to. Первое повторение — сама задача,
+// следующие вычисляются через NextDate, как при выполнении задачи, и помечаются Virtual.
+// Задача с датой раньше from сразу переносится на первое повторение не раньше from
+func (s *TaskService) occurrences(task *domain.Task, from, to string) ([]*domain.Task, error) {
+	var res []*domain.Task
+	date, repeat := task.Date, task.Repeat
+	for date <= to {
+		if date >= from {
+			occurrence := *task
+			occurrence.Date = date
+			occurrence.Virtual = date != task.Date
+			res = append(res, &occurrence)
+		}
+		after := date
+		if after < from {
+			start, err := time.Parse(dateForm, from)
+			if err != nil {
+				return nil, err
+			}
+			after = start.AddDate(0, 0, -1).Format(dateForm)
+		}
+		day, err := time.Parse(dateForm, after)
+		if err != nil {
+			return nil, err
+		}
+		next, err := s.NextDate(day, date, repeat)
+		if err != nil {
+			return nil, err
+		}
+		//Правило закончилось или не сдвигает дату
+		if next == "delete" || next <= date {
+			break
+		}
+		if repeat, err = shiftRepeat(repeat, date, next); err != nil {
+			return nil, err
+		}
+		date = next
+	}
+	return res, nil
+}
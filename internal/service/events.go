@@ -0,0 +1,36 @@
+package service
+
+import (
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+// Subscribe добавляет обработчик событий задач. Обработчики вызываются синхронно
+// после успешной операции и должны быстро возвращать управление.
+// Подписываться нужно при сборке приложения, до обработки запросов
+func (s *TaskService) Subscribe(fn func(domain.TaskEvent)) {
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *TaskService) emit(eventType string, task *domain.Task, completion *domain.Completion) {
+	if len(s.listeners) == 0 {
+		return
+	}
+	event := domain.TaskEvent{
+		Type:       eventType,
+		TaskID:     task.ID,
+		UserID:     task.UserID,
+		Completion: completion,
+		OccurredAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if eventType != domain.EventTaskDeleted {
+		snapshot := *task
+		if s.fillDue([]*domain.Task{&snapshot}) == nil {
+			event.Task = &snapshot
+		}
+	}
+	for _, fn := range s.listeners {
+		fn(event)
+	}
+}
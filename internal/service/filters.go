@@ -0,0 +1,193 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/query"
+)
+
+// builtInFilters — встроенные списки в порядке вывода. Запрос строится от текущей даты пользователя
+var builtInFilters = []struct {
+	id    string
+	name  string
+	query func(today time.Time) string
+}{
+	{domain.FilterToday, "Сегодня", func(today time.Time) string {
+		return "date:" + today.Format(dateForm)
+	}},
+	{domain.FilterOverdue, "Просроченные", func(today time.Time) string {
+		return "before:" + today.Format(dateForm)
+	}},
+	{domain.FilterNext7, "Ближайшие 7 дней", func(today time.Time) string {
+		return "after:" + today.AddDate(0, 0, -1).Format(dateForm) + " before:" + today.AddDate(0, 0, 7).Format(dateForm)
+	}},
+	{domain.FilterRecurring, "Повторяющиеся", func(time.Time) string {
+		return "is:recurring"
+	}},
+}
+
+// FilterService управляет сохранёнными поисками пользователей и встроенными списками задач.
+// Задачи фильтра ищутся через TaskService.Search, как и задачи списка /api/tasks
+type FilterService struct {
+	repo  domain.FilterRepository
+	tasks *TaskService
+}
+
+func NewFilterService(repo domain.FilterRepository, tasks *TaskService) *FilterService {
+	return &FilterService{repo: repo, tasks: tasks}
+}
+
+// List возвращает встроенные списки и сохранённые фильтры пользователя
+func (s *FilterService) List(userID int64) ([]*domain.SavedFilter, *domain.CustomError) {
+	today, cErr := s.tasks.now(&domain.Task{UserID: userID})
+	if cErr != nil {
+		return nil, cErr
+	}
+	saved, err := s.repo.FindFilters(userID)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	filters := make([]*domain.SavedFilter, 0, len(builtInFilters)+len(saved))
+	for _, b := range builtInFilters {
+		filters = append(filters, &domain.SavedFilter{ID: b.id, Name: b.name, Query: b.query(today), BuiltIn: true})
+	}
+	return append(filters, saved...), nil
+}
+
+// Get возвращает встроенный список или сохранённый фильтр пользователя
+func (s *FilterService) Get(userID int64, id string) (*domain.SavedFilter, *domain.CustomError) {
+	for _, b := range builtInFilters {
+		if b.id != id {
+			continue
+		}
+		today, cErr := s.tasks.now(&domain.Task{UserID: userID})
+		if cErr != nil {
+			return nil, cErr
+		}
+		return &domain.SavedFilter{ID: b.id, Name: b.name, Query: b.query(today), BuiltIn: true}, nil
+	}
+	filterID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrFilterNotFound, err)
+	}
+	filter, err := s.repo.FindFilter(userID, filterID)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	if filter == nil {
+		return nil, domain.NewCustomError(0, domain.ErrFilterNotFound, nil)
+	}
+	return filter, nil
+}
+
+func (s *FilterService) Create(filter *domain.SavedFilter) (*domain.SavedFilter, *domain.CustomError) {
+	filter.ID = ""
+	if cErr := s.validate(filter); cErr != nil {
+		return nil, cErr
+	}
+	//Проверка названия в validate не защищает от одновременного создания, поэтому
+	//занятое название определяется и по ограничению уникальности в БД
+	_, err := s.repo.CreateFilter(filter)
+	if errors.Is(err, domain.ErrFilterExists) {
+		return nil, domain.NewCustomError(0, domain.ErrFilterExists, err)
+	}
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return filter, nil
+}
+
+// Update изменяет сохранённый фильтр и возвращает его, встроенные списки не меняются
+func (s *FilterService) Update(filter *domain.SavedFilter) (*domain.SavedFilter, *domain.CustomError) {
+	current, cErr := s.Get(filter.UserID, filter.ID)
+	if cErr != nil {
+		return nil, cErr
+	}
+	if current.BuiltIn {
+		return nil, domain.NewCustomError(0, domain.ErrFilterBuiltIn, nil)
+	}
+	if cErr = s.validate(filter); cErr != nil {
+		return nil, cErr
+	}
+	err := s.repo.UpdateFilter(filter)
+	if errors.Is(err, domain.ErrFilterExists) || errors.Is(err, domain.ErrFilterNotFound) {
+		return nil, domain.NewCustomError(0, err, nil)
+	}
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	filter.CreatedAt = current.CreatedAt
+	return filter, nil
+}
+
+func (s *FilterService) Delete(userID int64, id string) *domain.CustomError {
+	current, cErr := s.Get(userID, id)
+	if cErr != nil {
+		return cErr
+	}
+	if current.BuiltIn {
+		return domain.NewCustomError(0, domain.ErrFilterBuiltIn, nil)
+	}
+	filterID, _ := strconv.ParseInt(current.ID, 10, 64)
+	err := s.repo.DeleteFilter(userID, filterID)
+	if errors.Is(err, domain.ErrFilterNotFound) {
+		return domain.NewCustomError(0, domain.ErrFilterNotFound, nil)
+	}
+	if err != nil {
+		return domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return nil
+}
+
+// Tasks возвращает страницу задач фильтра. page задаёт параметры страницы, как у списка задач;
+// сортировка фильтра используется, если в page она не указана
+func (s *FilterService) Tasks(userID int64, id string, page *domain.Filter) (*domain.TaskPage, *domain.CustomError) {
+	filter, cErr := s.Get(userID, id)
+	if cErr != nil {
+		return nil, cErr
+	}
+	page.UserID = userID
+	page.SearchTerm = filter.Query
+	if page.Sort == "" && !page.Desc {
+		page.Sort = filter.Sort
+		page.Desc = filter.Order == "desc"
+	}
+	return s.tasks.Search(page)
+}
+
+// validate проверяет название, запрос и сортировку фильтра. Название уникально у пользователя
+func (s *FilterService) validate(filter *domain.SavedFilter) *domain.CustomError {
+	filter.Name = strings.TrimSpace(filter.Name)
+	filter.Query = strings.TrimSpace(filter.Query)
+	filter.BuiltIn = false
+	if filter.Name == "" || utf8.RuneCountInString(filter.Name) > maxNameLen {
+		return domain.NewCustomError(0, domain.ErrFilterName, nil)
+	}
+	if err := query.Compile(filter.Query, &domain.Filter{}); err != nil {
+		return domain.NewCustomError(0, domain.ErrQuery, err)
+	}
+	if filter.Sort != "" && !validSort(filter.Sort) {
+		return domain.NewCustomError(0, domain.ErrSort, nil)
+	}
+	switch filter.Order {
+	case "", "asc", "desc":
+	default:
+		return domain.NewCustomError(0, domain.ErrSort, nil)
+	}
+
+	saved, err := s.repo.FindFilters(filter.UserID)
+	if err != nil {
+		return domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	for _, other := range saved {
+		if other.Name == filter.Name && other.ID != filter.ID {
+			return domain.NewCustomError(0, domain.ErrFilterExists, nil)
+		}
+	}
+	return nil
+}
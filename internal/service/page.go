@@ -0,0 +1,122 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+const maxPageSize int = 100
+
+// normalizePage проверяет диапазон дат, сортировку и размер страницы
+// и разбирает курсор, который должен быть выдан для того же порядка сортировки
+func normalizePage(filter *domain.Filter) *domain.CustomError {
+	for _, date := range []string{filter.From, filter.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(dateForm, date); err != nil {
+			return domain.NewCustomError(0, domain.ErrDate, err)
+		}
+	}
+	if filter.Sort == "" {
+		filter.Sort = domain.SortDate
+	}
+	if !validSort(filter.Sort) {
+		return domain.NewCustomError(0, domain.ErrSort, nil)
+	}
+	if filter.Limit == 0 {
+		filter.Limit = limitSearch
+	}
+	if filter.Limit < 0 || filter.Limit > maxPageSize {
+		return domain.NewCustomError(0, domain.ErrLimit, nil)
+	}
+	if filter.Cursor == "" {
+		return nil
+	}
+	cursor, err := decodeCursor(filter.Cursor)
+	if err != nil {
+		return domain.NewCustomError(0, domain.ErrCursor, err)
+	}
+	if cursor.Sort != filter.Sort || cursor.Desc != filter.Desc {
+		return domain.NewCustomError(0, domain.ErrCursor, nil)
+	}
+	filter.After = cursor
+	return nil
+}
+
+func validSort(sort string) bool {
+	switch sort {
+	case domain.SortDate, domain.SortTitle, domain.SortPriority, domain.SortCreated, domain.SortRank:
+		return true
+	}
+	return false
+}
+
+// page ищет страницу задач: запрашивает на одну задачу больше размера страницы,
+// чтобы узнать, есть ли следующая, и вычисляет сроки только у задач страницы
+func (s *TaskService) page(filter *domain.Filter) (*domain.TaskPage, *domain.CustomError) {
+	total, err := s.repo.CountTasks(filter)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	size := filter.Limit
+	lookup := *filter
+	lookup.Limit = size + 1
+	tasks, err := s.repo.FindTask(&lookup)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	page := &domain.TaskPage{Tasks: tasks, Total: total}
+	if len(tasks) > size {
+		page.Tasks = tasks[:size]
+		page.NextCursor, err = encodeCursor(filter, tasks[size-1])
+		if err != nil {
+			return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+		}
+	}
+	if err = s.fillDue(page.Tasks); err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return page, nil
+}
+
+// encodeCursor запоминает ключ сортировки последней задачи страницы
+func encodeCursor(filter *domain.Filter, last *domain.Task) (string, error) {
+	id, err := strconv.ParseInt(last.ID, 10, 64)
+	if err != nil {
+		return "", err
+	}
+	cursor := domain.Cursor{Sort: filter.Sort, Desc: filter.Desc, ID: id}
+	switch filter.Sort {
+	case domain.SortDate:
+		cursor.Date, cursor.Time = last.Date, last.Time
+	case domain.SortRank:
+		//Без полнотекстового индекса релевантности нет и задачи идут по дате
+		cursor.Rank, cursor.Date, cursor.Time = last.Rank, last.Date, last.Time
+	case domain.SortTitle:
+		cursor.Title = last.Title
+	case domain.SortPriority:
+		cursor.Priority = last.Priority
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(value string) (*domain.Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor domain.Cursor
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+// Поля задачи, которые вычисляются сервером и не меняются патчем
+var readOnlyFields = []string{"id", "due", "status"}
+
+// Patch применяет к активной задаче filter патч JSON Merge Patch (RFC 7396): поля из патча заменяют
+// поля задачи, null сбрасывает поле, отсутствующие поля не меняются. Результат проверяется
+// и сохраняется по правилам Update в одной транзакции с чтением задачи. Возвращается задача после изменения
+func (s *TaskService) Patch(filter *domain.Filter, patch map[string]any) (*domain.Task, *domain.CustomError) {
+	for _, field := range readOnlyFields {
+		delete(patch, field)
+	}
+	var task domain.Task
+	cErr := s.inTx(func(repo domain.TaskRepository) *domain.CustomError {
+		lookup := *filter
+		lookup.ForUpdate = true
+		res, err := repo.FindTask(&lookup)
+		if err != nil {
+			return domain.NewCustomError(0, domain.ErrInternalServer, err)
+		}
+		if len(res) == 0 {
+			return missing(repo, filter)
+		}
+		current, err := toMap(res[0])
+		if err != nil {
+			return domain.NewCustomError(0, domain.ErrInternalServer, err)
+		}
+		data, err := json.Marshal(mergePatch(current, patch))
+		if err != nil {
+			return domain.NewCustomError(0, domain.ErrInternalServer, err)
+		}
+		if err = json.Unmarshal(data, &task); err != nil {
+			return domain.NewCustomError(0, domain.ErrPatch, err)
+		}
+		task.ID = strconv.Itoa(*filter.ID)
+		task.UserID = filter.UserID
+		task.Version = filter.Version
+		return s.update(repo, &task)
+	})
+	if cErr != nil {
+		return nil, cErr
+	}
+	s.emit(domain.EventTaskUpdated, &task, nil)
+	updated, cErr := s.GetTask(&domain.Filter{ID: filter.ID, UserID: filter.UserID})
+	if cErr != nil {
+		return nil, cErr
+	}
+	updated.ID = task.ID
+	return updated, nil
+}
+
+func toMap(task *domain.Task) (map[string]any, error) {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]any)
+	return m, json.Unmarshal(data, &m)
+}
+
+// mergePatch применяет патч к документу по алгоритму RFC 7396
+func mergePatch(target any, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = mergePatch(t[key], value)
+	}
+	return t
+}
@@ -2,22 +2,30 @@ package service
 
 import (
 	"errors"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/query"
+	"github.com/agidelle/todo_web/internal/recurrence"
 )
 
 type TaskService struct {
-	repo domain.TaskRepository
+	repo      domain.TaskRepository
+	users     domain.UserRepository
+	listeners []func(domain.TaskEvent)
 }
 
 const limitSearch int = 25
 const dateForm string = "20060102"
+const maxNameLen int = 64
 
-func NewService(repo domain.TaskRepository) *TaskService {
-	return &TaskService{repo: repo}
+// NewService создаёт сервис задач, users нужен для часового пояса пользователя и может быть nil
+func NewService(repo domain.TaskRepository, users domain.UserRepository) *TaskService {
+	return &TaskService{repo: repo, users: users}
 }
 
 func (s *TaskService) CloseDB() error {
@@ -68,13 +76,15 @@ func (s *TaskService) FindAll(filter *domain.Filter) ([]*domain.Task, *domain.Cu
 	}
 }
 
-func (s *TaskService) GetTasks(filter *domain.Filter) ([]*domain.Task, *domain.CustomError) {
-	filter.Limit = limitSearch
-	res, err := s.repo.FindTask(filter)
-	if err != nil {
-		return []*domain.Task{}, domain.NewCustomError(0, domain.ErrInternalServer, err)
+// GetTasks возвращает страницу задач по фильтру, по умолчанию limitSearch задач по дате
+func (s *TaskService) GetTasks(filter *domain.Filter) (*domain.TaskPage, *domain.CustomError) {
+	if cErr := normalizeFilter(filter); cErr != nil {
+		return nil, cErr
 	}
-	return res, nil
+	if cErr := normalizePage(filter); cErr != nil {
+		return nil, cErr
+	}
+	return s.page(filter)
 }
 
 func (s *TaskService) GetTask(filter *domain.Filter) (*domain.Task, *domain.CustomError) {
@@ -83,53 +93,52 @@ func (s *TaskService) GetTask(filter *domain.Filter) (*domain.Task, *domain.Cust
 		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
 	}
 	if len(res) == 0 {
-		return nil, domain.NewCustomError(0, domain.ErrID, nil)
+		return nil, domain.NewCustomError(0, domain.ErrNotFound, nil)
+	}
+	if err = s.fillDue(res); err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
 	}
 	return res[0], nil
 }
 
-func (s *TaskService) Search(filter *domain.Filter) ([]*domain.Task, *domain.CustomError) {
-	filter.Limit = limitSearch
+// Search ищет задачи по дате 02.01.2006 или по запросу на языке пакета query.
+// Найденные по словам задачи по умолчанию идут по релевантности
+func (s *TaskService) Search(filter *domain.Filter) (*domain.TaskPage, *domain.CustomError) {
 	if date, err := time.Parse("02.01.2006", filter.SearchTerm); err == nil {
 		filter.Date = date.Format(dateForm)
 		filter.SearchTerm = ""
-		tasks, err := s.repo.FindTask(filter)
-		if err != nil {
-			return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
-		}
-		return tasks, nil
+	} else if err = query.Compile(filter.SearchTerm, filter); err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrQuery, err)
+	}
+	if cErr := normalizeFilter(filter); cErr != nil {
+		return nil, cErr
+	}
+	if filter.SearchTerm != "" && filter.Sort == "" {
+		filter.Sort = domain.SortRank
 	}
+	if cErr := normalizePage(filter); cErr != nil {
+		return nil, cErr
+	}
+	return s.page(filter)
+}
 
+// findTasks ищет задачи и вычисляет их сроки выполнения
+func (s *TaskService) findTasks(filter *domain.Filter) ([]*domain.Task, *domain.CustomError) {
 	tasks, err := s.repo.FindTask(filter)
 	if err != nil {
 		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
 	}
+	if err = s.fillDue(tasks); err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
 	return tasks, nil
 }
 
 func (s *TaskService) Create(task *domain.Task) (int64, *domain.CustomError) {
-	now := time.Now()
-	nowF := now.Format(dateForm)
-
-	//Проверки и исправления запроса
-	if task.Title == "" {
-		return 0, domain.NewCustomError(0, domain.ErrBadTitle, nil)
-	}
-	if task.Date == "" {
-		task.Date = time.Now().Format(dateForm) //если дата пустая, присваиваем текущую
-	}
-	date, err := time.Parse(dateForm, task.Date)
-	if err != nil {
-		return 0, domain.NewCustomError(0, domain.ErrDate, err)
-	}
-	if task.Repeat == "" && nowF > date.Format(dateForm) {
-		task.Date = nowF
-	}
-	if nowF > task.Date {
-		task.Date, err = s.NextDate(now, task.Date, task.Repeat)
-		if err != nil {
-			return 0, domain.NewCustomError(0, domain.ErrInternalServer, err)
-		}
+	//Новая задача всегда активна, состояние из запроса учитывается только при импорте
+	task.Status = ""
+	if cErr := s.prepare(task); cErr != nil {
+		return 0, cErr
 	}
 
 	//Создаем задачу в БД
@@ -137,73 +146,389 @@ func (s *TaskService) Create(task *domain.Task) (int64, *domain.CustomError) {
 	if err != nil {
 		return 0, domain.NewCustomError(0, domain.ErrInternalServer, err)
 	}
+	task.ID = strconv.FormatInt(id, 10)
+	s.emit(domain.EventTaskCreated, task, nil)
 	return id, nil
 }
 
 func (s *TaskService) Update(task *domain.Task) *domain.CustomError {
-	now := time.Now()
-	nowF := now.Format(dateForm)
-	//Проверки и исправления запроса
+	if cErr := s.update(s.repo, task); cErr != nil {
+		return cErr
+	}
+	s.emit(domain.EventTaskUpdated, task, nil)
+	return nil
+}
+
+func (s *TaskService) update(repo domain.TaskRepository, task *domain.Task) *domain.CustomError {
+	task.Status = ""
+	if cErr := s.prepare(task); cErr != nil {
+		return cErr
+	}
+	err := repo.UpdateTask(task)
+	if errors.Is(err, domain.ErrNotFound) {
+		id, _ := strconv.Atoi(task.ID)
+		return missing(repo, &domain.Filter{ID: &id, UserID: task.UserID, Version: task.Version})
+	}
+	if err != nil {
+		return domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return nil
+}
+
+// prepare проверяет и исправляет задачу перед сохранением:
+// пустая дата становится сегодняшней, просроченная активная задача переносится на сегодня или следующее повторение
+func (s *TaskService) prepare(task *domain.Task) *domain.CustomError {
 	if task.Title == "" {
 		return domain.NewCustomError(0, domain.ErrBadTitle, nil)
 	}
+	if cErr := normalizeTime(task); cErr != nil {
+		return cErr
+	}
+	now, cErr := s.now(task)
+	if cErr != nil {
+		return cErr
+	}
+	nowF := now.Format(dateForm)
 	if task.Date == "" {
-		task.Date = time.Now().Format(dateForm)
+		task.Date = nowF //если дата пустая, присваиваем текущую
 	}
 	date, err := time.Parse(dateForm, task.Date)
 	if err != nil {
 		return domain.NewCustomError(0, domain.ErrDate, err)
 	}
+	if err = validateRepeat(task.Repeat); err != nil {
+		return domain.NewCustomError(0, domain.ErrRepeat, err)
+	}
+	if cErr := normalizeMeta(task); cErr != nil {
+		return cErr
+	}
+	//Выполненные задачи из импорта сохраняют свою дату
+	if task.Status == domain.StatusDone {
+		return nil
+	}
 	if task.Repeat == "" && nowF > date.Format(dateForm) {
 		task.Date = nowF
 	}
 	if nowF > task.Date {
-		task.Date, err = s.NextDate(now, task.Date, task.Repeat)
-		if err != nil {
-			return domain.NewCustomError(0, domain.ErrInternalServer, err)
+		if cErr := s.moveToNext(now, task); cErr != nil {
+			return cErr
 		}
 	}
-	err = s.repo.UpdateTask(task)
+	return nil
+}
+
+// Import проверяет задачи записей импорта по правилам Create и создаёт прошедшие проверку одной транзакцией.
+// Записи с ошибками попадают в отчёт и не мешают остальным. Задачи, совпадающие с уже существующими
+// или с предыдущими записями по заголовку, дате, времени и правилу повторения, пропускаются.
+// При dryRun задачи только проверяются, отчёт показывает, что было бы создано.
+// Поиск повторов и создание выполняются одной транзакцией, поэтому одновременная загрузка
+// одного файла не создаёт задачи дважды
+func (s *TaskService) Import(userID int64, items []*domain.ImportItem, dryRun bool) (*domain.ImportReport, *domain.CustomError) {
+	var report *domain.ImportReport
+	var created []*domain.Task
+	cErr := s.inTx(func(repo domain.TaskRepository) *domain.CustomError {
+		var cErr *domain.CustomError
+		report, created, cErr = s.importItems(repo, userID, items, dryRun)
+		return cErr
+	})
+	if cErr != nil {
+		return nil, cErr
+	}
+	for _, task := range created {
+		s.emit(domain.EventTaskCreated, task, nil)
+	}
+	return report, nil
+}
+
+// importItems проверяет записи импорта и создаёт задачи, возвращает отчёт и созданные задачи
+func (s *TaskService) importItems(repo domain.TaskRepository, userID int64, items []*domain.ImportItem, dryRun bool) (*domain.ImportReport, []*domain.Task, *domain.CustomError) {
+	existing, err := repo.FindTask(&domain.Filter{UserID: userID, Status: domain.StatusAll})
 	if err != nil {
-		return domain.NewCustomError(0, domain.ErrInternalServer, err)
+		return nil, nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
 	}
-	return nil
+	seen := make(map[string]string, len(existing))
+	for _, task := range existing {
+		seen[duplicateKey(task)] = task.ID
+	}
+
+	report := &domain.ImportReport{Items: items, DryRun: dryRun}
+	var valid []*domain.ImportItem
+	var tasks []*domain.Task
+	for _, item := range items {
+		if item.Task == nil || item.Status != "" {
+			continue
+		}
+		task := item.Task
+		task.UserID = userID
+		switch task.Status {
+		case "", domain.StatusActive, domain.StatusDone:
+		default:
+			item.Status = domain.ImportFailed
+			item.Error = "неизвестное состояние задачи " + task.Status
+			continue
+		}
+		//Дата до переноса совпадает с выгрузкой, после переноса — с ранее импортированной задачей
+		key := duplicateKey(task)
+		if cErr := s.prepare(task); cErr != nil {
+			item.Status = domain.ImportFailed
+			item.Error = cErr.Error()
+			continue
+		}
+		if id, ok := seen[key]; ok {
+			item.Status, item.Error = domain.ImportSkipped, duplicateError(id)
+			continue
+		}
+		if id, ok := seen[duplicateKey(task)]; ok {
+			item.Status, item.Error = domain.ImportSkipped, duplicateError(id)
+			continue
+		}
+		seen[key] = ""
+		seen[duplicateKey(task)] = ""
+		item.Status = domain.ImportCreated
+		valid = append(valid, item)
+		tasks = append(tasks, task)
+	}
+	var created []*domain.Task
+	if len(tasks) > 0 && !dryRun {
+		ids, err := repo.CreateTasks(tasks)
+		if err != nil {
+			return nil, nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+		}
+		for i, item := range valid {
+			item.Task.ID = strconv.FormatInt(ids[i], 10)
+			item.ID = item.Task.ID
+		}
+		created = tasks
+	}
+	for _, item := range items {
+		switch item.Status {
+		case domain.ImportCreated:
+			report.Created++
+		case domain.ImportSkipped:
+			report.Skipped++
+		default:
+			item.Status = domain.ImportFailed
+			report.Failed++
+		}
+	}
+	return report, created, nil
+}
+
+// duplicateKey — признаки, по которым задача импорта считается повтором
+func duplicateKey(task *domain.Task) string {
+	return strings.Join([]string{task.Title, task.Date, task.Time, task.Repeat}, "\x00")
+}
+
+func duplicateError(id string) string {
+	if id == "" {
+		return "повтор записи из того же файла"
+	}
+	return "задача уже существует, id " + id
 }
 
+// Done выполняет задачу одной транзакцией, поэтому одновременные вызовы
+// не переносят задачу от одной и той же даты и не выполняют разовую задачу дважды
 func (s *TaskService) Done(filter *domain.Filter) *domain.CustomError {
-	now := time.Now()
-	task, err := s.repo.FindTask(filter)
+	var task *domain.Task
+	var completion *domain.Completion
+	cErr := s.inTx(func(repo domain.TaskRepository) *domain.CustomError {
+		var cErr *domain.CustomError
+		task, completion, cErr = s.done(repo, filter)
+		return cErr
+	})
+	if cErr != nil {
+		return cErr
+	}
+	s.emit(domain.EventTaskDone, task, completion)
+	return nil
+}
+
+func (s *TaskService) done(repo domain.TaskRepository, filter *domain.Filter) (*domain.Task, *domain.Completion, *domain.CustomError) {
+	lookup := *filter
+	lookup.ForUpdate = true
+	task, err := repo.FindTask(&lookup)
 	if err != nil {
-		return domain.NewCustomError(0, domain.ErrID, err)
+		return nil, nil, domain.NewCustomError(0, domain.ErrID, err)
 	}
 	if len(task) == 0 {
-		return domain.NewCustomError(0, domain.ErrID, nil)
+		return nil, nil, missing(repo, filter)
+	}
+	if filter.Version > 0 && task[0].Version != filter.Version {
+		return nil, nil, domain.NewCustomError(0, domain.ErrConflict, nil)
+	}
+	now, cErr := s.now(task[0])
+	if cErr != nil {
+		return nil, nil, cErr
 	}
 	rDay, err := s.NextDate(now, task[0].Date, task[0].Repeat)
 	if err != nil {
-		return domain.NewCustomError(0, domain.ErrInternalServer, err)
+		return nil, nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	completion := &domain.Completion{
+		TaskID:      strconv.Itoa(*filter.ID),
+		Title:       task[0].Title,
+		Date:        task[0].Date,
+		CompletedAt: now.UTC().Format(time.RFC3339),
+		UserID:      filter.UserID,
 	}
+	//Задача без повторения не удаляется, а переводится в состояние "выполнена"
 	if rDay == "delete" {
-		err = s.repo.DeleteTask(filter.ID)
+		err = repo.SetStatus(filter, domain.StatusDone)
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, nil, missing(repo, filter)
+		}
 		if err != nil {
-			domain.NewCustomError(0, domain.ErrInternalServer, err)
+			return nil, nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+		}
+	} else {
+		task[0].ID = strconv.Itoa(*filter.ID)
+		task[0].Repeat, err = shiftRepeat(task[0].Repeat, task[0].Date, rDay)
+		if err != nil {
+			return nil, nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+		}
+		task[0].Date = rDay
+		task[0].Version = filter.Version
+		err = repo.UpdateTask(task[0])
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, nil, missing(repo, filter)
+		}
+		if err != nil {
+			return nil, nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+		}
+		completion.NextDate = rDay
+	}
+	completionID, err := repo.AddCompletion(completion)
+	if err != nil {
+		return nil, nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	completion.ID = strconv.FormatInt(completionID, 10)
+	task[0].ID = completion.TaskID
+	return task[0], completion, nil
+}
+
+// inTx выполняет fn в транзакции репозитория задач, ошибка fn отменяет транзакцию
+func (s *TaskService) inTx(fn func(repo domain.TaskRepository) *domain.CustomError) *domain.CustomError {
+	var cErr *domain.CustomError
+	err := s.repo.WithTx(func(repo domain.TaskRepository) error {
+		if cErr = fn(repo); cErr != nil {
+			return cErr
 		}
 		return nil
+	})
+	if cErr != nil {
+		return cErr
 	}
-	task[0].ID = strconv.Itoa(*filter.ID)
-	task[0].Date = rDay
-	err = s.repo.UpdateTask(task[0])
 	if err != nil {
 		return domain.NewCustomError(0, domain.ErrInternalServer, err)
 	}
 	return nil
 }
 
-func (s *TaskService) Delete(id int) *domain.CustomError {
-	err := s.repo.DeleteTask(&id)
+// missing объясняет, почему задача filter не изменена: задача пользователя может существовать,
+// но иметь другую версию или быть уже выполненной
+func missing(repo domain.TaskRepository, filter *domain.Filter) *domain.CustomError {
+	res, err := repo.FindTask(&domain.Filter{ID: filter.ID, UserID: filter.UserID, Status: domain.StatusAll})
 	if err != nil {
 		return domain.NewCustomError(0, domain.ErrInternalServer, err)
 	}
+	if len(res) > 0 && filter.Version > 0 && res[0].Version != filter.Version {
+		return domain.NewCustomError(0, domain.ErrConflict, nil)
+	}
+	if len(res) > 0 && res[0].Status == domain.StatusDone {
+		return domain.NewCustomError(0, domain.ErrTaskDone, nil)
+	}
+	return domain.NewCustomError(0, domain.ErrNotFound, nil)
+}
+
+// History возвращает журнал выполнения задачи пользователя
+func (s *TaskService) History(userID int64, id int) ([]*domain.Completion, *domain.CustomError) {
+	res, err := s.repo.FindCompletions(&domain.CompletionFilter{TaskID: &id, UserID: userID})
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return res, nil
+}
+
+// Completed возвращает выполненные задачи за период, границы from и to включаются.
+// Дни периода отсчитываются в часовом поясе пользователя, как в повестке
+func (s *TaskService) Completed(userID int64, from, to string) ([]*domain.Completion, *domain.CustomError) {
+	filter := domain.CompletionFilter{UserID: userID}
+	loc, err := s.location(&domain.Task{UserID: userID})
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	if from != "" {
+		date, err := time.ParseInLocation(dateForm, from, loc)
+		if err != nil {
+			return nil, domain.NewCustomError(0, domain.ErrDate, err)
+		}
+		filter.From = date
+	}
+	if to != "" {
+		date, err := time.ParseInLocation(dateForm, to, loc)
+		if err != nil {
+			return nil, domain.NewCustomError(0, domain.ErrDate, err)
+		}
+		filter.To = date.AddDate(0, 0, 1)
+	}
+	res, err := s.repo.FindCompletions(&filter)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return res, nil
+}
+
+// Upcoming возвращает активные задачи всех пользователей с датами от now до until.
+// Диапазон расширен на сутки в обе стороны, так как пояс задачи сдвигает её дату,
+// точный срок выполнения проверяет вызывающий по полю Due
+func (s *TaskService) Upcoming(now, until time.Time) ([]*domain.Task, *domain.CustomError) {
+	return s.findTasks(&domain.Filter{
+		AllUsers: true,
+		From:     now.UTC().AddDate(0, 0, -1).Format(dateForm),
+		To:       until.UTC().AddDate(0, 0, 1).Format(dateForm),
+	})
+}
+
+// Export возвращает все задачи пользователя, активные и выполненные, для выгрузки
+func (s *TaskService) Export(userID int64) ([]*domain.Task, *domain.CustomError) {
+	tasks, err := s.repo.FindTask(&domain.Filter{UserID: userID, Status: domain.StatusAll})
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return tasks, nil
+}
+
+// CalendarTasks возвращает все активные задачи пользователя для календаря.
+// Задачам без собственного пояса назначается пояс пользователя, если он задан
+func (s *TaskService) CalendarTasks(userID int64) ([]*domain.Task, *domain.CustomError) {
+	tasks, cErr := s.findTasks(&domain.Filter{UserID: userID})
+	if cErr != nil {
+		return nil, cErr
+	}
+	for _, task := range tasks {
+		if task.Timezone != "" {
+			continue
+		}
+		loc, err := s.location(task)
+		if err != nil {
+			return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+		}
+		if loc != time.Local {
+			task.Timezone = loc.String()
+		}
+	}
+	return tasks, nil
+}
+
+func (s *TaskService) Delete(filter *domain.Filter) *domain.CustomError {
+	err := s.repo.DeleteTask(filter)
+	if errors.Is(err, domain.ErrNotFound) {
+		return missing(s.repo, filter)
+	}
+	if err != nil {
+		return domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	s.emit(domain.EventTaskDeleted, &domain.Task{ID: strconv.Itoa(*filter.ID), UserID: filter.UserID}, nil)
 	return nil
 }
 
@@ -213,9 +538,26 @@ func (s *TaskService) NextDate(now time.Time, dstart string, repeat string) (str
 	if err != nil {
 		return "", errors.New("неправильный формат даты")
 	}
+	//Даты задач не привязаны к поясу, поэтому сравниваем с календарной датой now в её поясе
+	now = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
 	switch {
 	case repeat == "":
 		return "delete", nil
+	case recurrence.IsRRule(repeat):
+		rule, err := recurrence.Parse(repeat)
+		if err != nil {
+			return "", err
+		}
+		//Следующее повторение ищем после сегодняшнего дня и после текущей даты задачи
+		after := now
+		if pDate.After(after) {
+			after = pDate
+		}
+		next, ok := rule.Next(pDate, after)
+		if !ok {
+			return "delete", nil
+		}
+		return next.Format(dateForm), nil
 	case strings.HasPrefix(repeat, "d "):
 		daysStr := strings.TrimPrefix(repeat, "d ")
 		days, err := strconv.Atoi(daysStr)
@@ -302,6 +644,113 @@ func (s *TaskService) NextDate(now time.Time, dstart string, repeat string) (str
 	}
 }
 
+// moveToNext переносит задачу с прошедшей датой на ближайшее повторение
+func (s *TaskService) moveToNext(now time.Time, task *domain.Task) *domain.CustomError {
+	next, err := s.NextDate(now, task.Date, task.Repeat)
+	if err != nil {
+		return domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	if next == "delete" {
+		return domain.NewCustomError(0, domain.ErrRepeat, errors.New("у правила повторения не осталось будущих дат"))
+	}
+	task.Repeat, err = shiftRepeat(task.Repeat, task.Date, next)
+	if err != nil {
+		return domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	task.Date = next
+	return nil
+}
+
+// normalizeMeta проверяет приоритет и приводит к единому виду проект и метки задачи
+func normalizeMeta(task *domain.Task) *domain.CustomError {
+	if task.Priority < domain.PriorityNone || task.Priority > domain.PriorityHigh {
+		return domain.NewCustomError(0, domain.ErrPriority, nil)
+	}
+	task.Project = strings.TrimSpace(task.Project)
+	if utf8.RuneCountInString(task.Project) > maxNameLen {
+		return domain.NewCustomError(0, domain.ErrTag, errors.New(task.Project))
+	}
+
+	tags, err := normalizeTags(task.Tags)
+	if err != nil {
+		return domain.NewCustomError(0, domain.ErrTag, err)
+	}
+	task.Tags = tags
+	return nil
+}
+
+// normalizeFilter проверяет условия отбора по приоритету, проекту и меткам
+func normalizeFilter(filter *domain.Filter) *domain.CustomError {
+	if filter.Priority < domain.PriorityNone || filter.Priority > domain.PriorityHigh {
+		return domain.NewCustomError(0, domain.ErrPriority, nil)
+	}
+	switch filter.Status {
+	case "", domain.StatusActive, domain.StatusDone, domain.StatusAll:
+	default:
+		return domain.NewCustomError(0, domain.ErrStatus, nil)
+	}
+	filter.Project = strings.TrimSpace(filter.Project)
+	tags, err := normalizeTags(filter.Tags)
+	if err != nil {
+		return domain.NewCustomError(0, domain.ErrTag, err)
+	}
+	filter.Tags = tags
+	return nil
+}
+
+// normalizeTags приводит метки к нижнему регистру, убирает пустые и повторы
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxNameLen {
+			return nil, errors.New(tag)
+		}
+		seen[tag] = true
+		res = append(res, tag)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// validateRepeat проверяет правило RRULE до сохранения задачи,
+// сокращённый формат проверяется при вычислении следующей даты
+func validateRepeat(repeat string) error {
+	if !recurrence.IsRRule(repeat) {
+		return nil
+	}
+	_, err := recurrence.Parse(repeat)
+	return err
+}
+
+// shiftRepeat уменьшает COUNT правила RRULE на число повторений,
+// пропущенных при переносе задачи с dstart на next
+func shiftRepeat(repeat, dstart, next string) (string, error) {
+	if !recurrence.IsRRule(repeat) {
+		return repeat, nil
+	}
+	rule, err := recurrence.Parse(repeat)
+	if err != nil {
+		return "", err
+	}
+	if rule.Count == 0 {
+		return repeat, nil
+	}
+	start, err := time.Parse(dateForm, dstart)
+	if err != nil {
+		return "", err
+	}
+	nextDate, err := time.Parse(dateForm, next)
+	if err != nil {
+		return "", err
+	}
+	return rule.Remaining(start, nextDate).String(), nil
+}
+
 func findNextWeekday(now time.Time, targetDays []int) (time.Time, error) {
 	currentDay := int(now.Weekday())
 	if currentDay == 0 {
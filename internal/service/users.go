@@ -0,0 +1,178 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AdminLogin — логин администратора, создаваемого из TODO_PASSWORD
+const AdminLogin = "admin"
+
+const minPasswordLen = 4
+
+var loginPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{3,64}$`)
+
+type UserService struct {
+	repo domain.UserRepository
+}
+
+func NewUserService(repo domain.UserRepository) *UserService {
+	return &UserService{repo: repo}
+}
+
+// Bootstrap создаёт администратора с паролем из TODO_PASSWORD или обновляет его пароль,
+// если переменная изменилась. Задачи без владельца передаются администратору
+func (s *UserService) Bootstrap(password string) (*domain.User, error) {
+	admin, err := s.repo.FindUser(AdminLogin)
+	if err != nil {
+		return nil, err
+	}
+	if admin == nil {
+		hash, err := hashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		admin = &domain.User{Login: AdminLogin, PasswordHash: hash, IsAdmin: true}
+		if _, err = s.repo.CreateUser(admin); err != nil {
+			return nil, err
+		}
+	} else if checkPassword(admin.PasswordHash, password) != nil {
+		hash, err := hashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		if err = s.repo.SetPassword(admin.ID, hash); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = s.repo.AssignOrphanTasks(admin.ID); err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+// Register создаёт учётную запись с паролем, хранящимся в виде bcrypt-хэша
+func (s *UserService) Register(login, password string, isAdmin bool) (*domain.User, *domain.CustomError) {
+	if !loginPattern.MatchString(login) {
+		return nil, domain.NewCustomError(0, domain.ErrLogin, nil)
+	}
+	if len(password) < minPasswordLen {
+		return nil, domain.NewCustomError(0, domain.ErrPassword, nil)
+	}
+	existing, err := s.repo.FindUser(login)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	if existing != nil {
+		return nil, domain.NewCustomError(0, domain.ErrUserExists, nil)
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	user := &domain.User{Login: login, PasswordHash: hash, IsAdmin: isAdmin}
+	if _, err = s.repo.CreateUser(user); err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return user, nil
+}
+
+// Authenticate проверяет логин и пароль и возвращает пользователя
+func (s *UserService) Authenticate(login, password string) (*domain.User, *domain.CustomError) {
+	user, err := s.repo.FindUser(login)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	if user == nil || checkPassword(user.PasswordHash, password) != nil {
+		return nil, domain.NewCustomError(0, domain.ErrCredentials, nil)
+	}
+	return user, nil
+}
+
+func (s *UserService) Get(id int64) (*domain.User, *domain.CustomError) {
+	user, err := s.repo.FindUserByID(id)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	if user == nil {
+		return nil, domain.NewCustomError(0, domain.ErrUnauthorized, nil)
+	}
+	return user, nil
+}
+
+// SetTimezone задаёт часовой пояс пользователя для задач без собственного пояса
+func (s *UserService) SetTimezone(id int64, timezone string) (*domain.User, *domain.CustomError) {
+	if _, err := loadLocation(timezone); err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrTimezone, err)
+	}
+	if err := s.repo.SetTimezone(id, timezone); err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return s.Get(id)
+}
+
+func (s *UserService) List() ([]*domain.User, *domain.CustomError) {
+	users, err := s.repo.ListUsers()
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return users, nil
+}
+
+// IssueFeedToken выдаёт новый токен подписки на календарь, прежний токен перестаёт действовать.
+// В БД хранится только хэш, поэтому токен можно получить лишь при выпуске
+func (s *UserService) IssueFeedToken(userID int64) (string, *domain.CustomError) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	token := hex.EncodeToString(raw)
+	if err := s.repo.SaveFeedToken(userID, hashToken(token)); err != nil {
+		return "", domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return token, nil
+}
+
+// FeedUser возвращает id владельца токена подписки на календарь
+func (s *UserService) FeedUser(token string) (int64, *domain.CustomError) {
+	if token == "" {
+		return 0, domain.NewCustomError(0, domain.ErrUnauthorized, nil)
+	}
+	userID, ok, err := s.repo.FindFeedToken(hashToken(token))
+	if err != nil {
+		return 0, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	if !ok {
+		return 0, domain.NewCustomError(0, domain.ErrUnauthorized, nil)
+	}
+	return userID, nil
+}
+
+func (s *UserService) RevokeFeedToken(userID int64) *domain.CustomError {
+	if err := s.repo.DeleteFeedToken(userID); err != nil {
+		return domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashPassword(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
+
+func checkPassword(hashedPassword, password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+}
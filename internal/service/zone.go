@@ -0,0 +1,89 @@
+package service
+
+import (
+	"time"
+	_ "time/tzdata" //База часовых поясов на случай, если в системе её нет
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+const timeForm string = "15:04"
+
+// loadLocation проверяет имя часового пояса IANA, пустое имя — пояс сервера
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	//"Local" зависит от настроек сервера и не годится для хранения
+	if name == "Local" {
+		return nil, domain.ErrTimezone
+	}
+	return time.LoadLocation(name)
+}
+
+// normalizeTime проверяет время и часовой пояс задачи
+func normalizeTime(task *domain.Task) *domain.CustomError {
+	if task.Time != "" {
+		t, err := time.Parse(timeForm, task.Time)
+		if err != nil {
+			return domain.NewCustomError(0, domain.ErrTime, err)
+		}
+		task.Time = t.Format(timeForm)
+	}
+	if _, err := loadLocation(task.Timezone); err != nil {
+		return domain.NewCustomError(0, domain.ErrTimezone, err)
+	}
+	return nil
+}
+
+// location возвращает часовой пояс задачи: собственный, пользователя или сервера
+func (s *TaskService) location(task *domain.Task) (*time.Location, error) {
+	if task.Timezone != "" || task.UserID == 0 || s.users == nil {
+		return loadLocation(task.Timezone)
+	}
+	user, err := s.users.FindUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return time.Local, nil
+	}
+	return loadLocation(user.Timezone)
+}
+
+// now возвращает текущее время в часовом поясе задачи
+func (s *TaskService) now(task *domain.Task) (time.Time, *domain.CustomError) {
+	loc, err := s.location(task)
+	if err != nil {
+		return time.Time{}, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return time.Now().In(loc), nil
+}
+
+// fillDue вычисляет срок выполнения задач в формате RFC 3339.
+// Для задач без времени срок — начало дня в их часовом поясе
+func (s *TaskService) fillDue(tasks []*domain.Task) error {
+	userZones := make(map[int64]*time.Location)
+	for _, task := range tasks {
+		loc, ok := userZones[task.UserID]
+		if task.Timezone != "" || !ok {
+			var err error
+			if loc, err = s.location(task); err != nil {
+				return err
+			}
+			if task.Timezone == "" {
+				userZones[task.UserID] = loc
+			}
+		}
+		clock := task.Time
+		if clock == "" {
+			clock = "00:00"
+		}
+		due, err := time.ParseInLocation(dateForm+" "+timeForm, task.Date+" "+clock, loc)
+		if err != nil {
+			return err
+		}
+		task.Due = due.Format(time.RFC3339)
+	}
+	return nil
+}
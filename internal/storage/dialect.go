@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dialect описывает различия SQL между поддерживаемыми СУБД
+type dialect struct {
+	name string
+	//Имя драйвера database/sql
+	driver string
+	//Стиль плейсхолдеров: ? для SQLite, $n для PostgreSQL
+	bindType int
+	//Оператор поиска без учёта регистра
+	like string
+	//Получение id через INSERT ... RETURNING id вместо LastInsertId
+	returning bool
+	//Параметры, добавляемые к строке подключения
+	params string
+	//Блокировка найденных строк задач до конца транзакции
+	lockRows string
+	//Поиск по индексу FTS5 scheduler_fts вместо сравнения с шаблоном
+	fts bool
+	//Проверка, что запрос нарушил ограничение уникальности
+	unique func(err error) bool
+}
+
+var dialects = map[string]*dialect{
+	"sqlite":   sqliteDialect,
+	"postgres": postgresDialect,
+}
+
+func dialectFor(driver string) (*dialect, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("неподдерживаемый драйвер БД: %s", driver)
+	}
+	return d, nil
+}
+
+// dsn дополняет строку подключения параметрами диалекта, не переопределяя заданные явно
+func (d *dialect) dsn(dsn string) string {
+	if d.params == "" || strings.Contains(dsn, d.params) {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + d.params
+	}
+	return dsn + "?" + d.params
+}
+
+func (d *dialect) rebind(query string) string {
+	return sqlx.Rebind(d.bindType, query)
+}
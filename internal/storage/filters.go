@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+// CreateFilter сохраняет фильтр, при занятом названии возвращает domain.ErrFilterExists
+func (s *Storage) CreateFilter(filter *domain.SavedFilter) (int64, error) {
+	filter.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	id, err := s.insert(s.conn(), "INSERT INTO saved_filters (user_id, name, query, sort, sort_order, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		filter.UserID, filter.Name, filter.Query, filter.Sort, filter.Order, filter.CreatedAt)
+	if s.dialect.unique(err) {
+		return 0, domain.ErrFilterExists
+	}
+	if err != nil {
+		return 0, err
+	}
+	filter.ID = strconv.FormatInt(id, 10)
+	return id, nil
+}
+
+func (s *Storage) FindFilters(userID int64) ([]*domain.SavedFilter, error) {
+	filters := make([]*domain.SavedFilter, 0)
+	rows, err := s.conn().Query(s.dialect.rebind(`SELECT id, user_id, name, query, sort, sort_order, created_at
+		FROM saved_filters WHERE user_id = ? ORDER BY name, id`), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+	for rows.Next() {
+		filter, err := scanFilter(rows)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, rows.Err()
+}
+
+// FindFilter ищет фильтр пользователя, возвращает nil, если такого нет
+func (s *Storage) FindFilter(userID, id int64) (*domain.SavedFilter, error) {
+	row := s.conn().QueryRow(s.dialect.rebind(`SELECT id, user_id, name, query, sort, sort_order, created_at
+		FROM saved_filters WHERE user_id = ? AND id = ?`), userID, id)
+	filter, err := scanFilter(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return filter, err
+}
+
+func scanFilter(row interface{ Scan(dest ...any) error }) (*domain.SavedFilter, error) {
+	var filter domain.SavedFilter
+	var id int64
+	err := row.Scan(&id, &filter.UserID, &filter.Name, &filter.Query, &filter.Sort, &filter.Order, &filter.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	filter.ID = strconv.FormatInt(id, 10)
+	return &filter, nil
+}
+
+// UpdateFilter изменяет фильтр пользователя. Если фильтра нет, возвращает domain.ErrFilterNotFound,
+// при занятом названии — domain.ErrFilterExists
+func (s *Storage) UpdateFilter(filter *domain.SavedFilter) error {
+	id, err := strconv.ParseInt(filter.ID, 10, 64)
+	if err != nil {
+		return err
+	}
+	res, err := s.conn().Exec(s.dialect.rebind("UPDATE saved_filters SET name = ?, query = ?, sort = ?, sort_order = ? WHERE user_id = ? AND id = ?"),
+		filter.Name, filter.Query, filter.Sort, filter.Order, filter.UserID, id)
+	if s.dialect.unique(err) {
+		return domain.ErrFilterExists
+	}
+	if err != nil {
+		return err
+	}
+	return checkAffected(res, domain.ErrFilterNotFound)
+}
+
+func (s *Storage) DeleteFilter(userID, id int64) error {
+	res, err := s.conn().Exec(s.dialect.rebind("DELETE FROM saved_filters WHERE user_id = ? AND id = ?"), userID, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res, domain.ErrFilterNotFound)
+}
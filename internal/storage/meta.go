@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+// saveMeta сохраняет владельца, состояние, приоритет, проект, время, часовой пояс и метки задачи.
+// Версия задачи увеличивается, её новое значение записывается в task.Version
+func (s *Storage) saveMeta(q querier, id int64, task *domain.Task) error {
+	var projectID sql.NullInt64
+	if task.Project != "" {
+		pid, err := s.ensureName(q, "projects", task.Project)
+		if err != nil {
+			return err
+		}
+		projectID = sql.NullInt64{Int64: pid, Valid: true}
+	}
+	//Состояние задаётся только при создании, дальше его меняет SetStatus
+	status := domain.StatusActive
+	if task.Status == domain.StatusDone {
+		status = domain.StatusDone
+	}
+	_, err := q.Exec(s.dialect.rebind(`INSERT INTO task_meta (task_id, user_id, priority, project_id, due_time, timezone, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (task_id) DO UPDATE SET priority = excluded.priority, project_id = excluded.project_id,
+		due_time = excluded.due_time, timezone = excluded.timezone, version = task_meta.version + 1`),
+		id, task.UserID, task.Priority, projectID, task.Time, task.Timezone, status)
+	if err != nil {
+		return err
+	}
+	err = q.QueryRow(s.dialect.rebind("SELECT version FROM task_meta WHERE task_id = ?"), id).Scan(&task.Version)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(s.dialect.rebind("DELETE FROM task_tags WHERE task_id = ?"), id)
+	if err != nil {
+		return err
+	}
+	for _, tag := range task.Tags {
+		tagID, err := s.ensureName(q, "tags", tag)
+		if err != nil {
+			return err
+		}
+		_, err = q.Exec(s.dialect.rebind("INSERT INTO task_tags (task_id, tag_id) VALUES (?, ?)"), id, tagID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureName возвращает id записи справочника (projects или tags), создавая её при необходимости
+func (s *Storage) ensureName(q querier, table, name string) (int64, error) {
+	_, err := q.Exec(s.dialect.rebind("INSERT INTO "+table+" (name) VALUES (?) ON CONFLICT (name) DO NOTHING"), name)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = q.QueryRow(s.dialect.rebind("SELECT id FROM "+table+" WHERE name = ?"), name).Scan(&id)
+	return id, err
+}
+
+// loadTags заполняет метки найденных задач одним запросом
+func (s *Storage) loadTags(tasks []*domain.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	byID := make(map[string]*domain.Task, len(tasks))
+	args := make([]interface{}, 0, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+		args = append(args, t.ID)
+	}
+	query := `SELECT tt.task_id, t.name FROM task_tags tt JOIN tags t ON t.id = tt.tag_id
+		WHERE tt.task_id IN (?` + strings.Repeat(", ?", len(args)-1) + `) ORDER BY t.name`
+
+	rows, err := s.conn().Query(s.dialect.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var taskID, name string
+		if err = rows.Scan(&taskID, &name); err != nil {
+			return err
+		}
+		if t, ok := byID[taskID]; ok {
+			t.Tags = append(t.Tags, name)
+		}
+	}
+	return rows.Err()
+}
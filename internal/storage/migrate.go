@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Миграции каждого диалекта лежат в migrations/<диалект> и встраиваются в бинарный файл.
+// Имя файла: <версия>_<название>.up.sql или <версия>_<название>.down.sql
+//
+//go:embed migrations
+var migrationsFS embed.FS
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt string
+}
+
+func (s *Storage) migrations() ([]*Migration, error) {
+	dir := path.Join("migrations", s.dialect.name)
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		parts := migrationName.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("неверное имя файла миграции: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(parts[1])
+		body, err := fs.ReadFile(migrationsFS, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = m
+		}
+		if m.Name != parts[2] {
+			return nil, fmt.Errorf("у миграции %d разные названия: %s и %s", version, m.Name, parts[2])
+		}
+		if parts[3] == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	res := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("у миграции %04d_%s нет файла up", m.Version, m.Name)
+		}
+		res = append(res, m)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+func (s *Storage) appliedMigrations() (map[int]string, error) {
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(128) NOT NULL DEFAULT '',
+		applied_at VARCHAR(20) NOT NULL DEFAULT ''
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания таблицы schema_migrations: %w", err)
+	}
+
+	rows, err := s.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Migrate применяет все ещё не применённые миграции по порядку версий.
+// Первые миграции идемпотентны, поэтому БД, созданные до появления
+// schema_migrations, обновляются без потери данных.
+func (s *Storage) Migrate() error {
+	_, err := s.MigrateUp()
+	return err
+}
+
+// MigrateUp применяет все ожидающие миграции и возвращает их список
+func (s *Storage) MigrateUp() ([]*Migration, error) {
+	all, err := s.migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+	for _, m := range all {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		err = s.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(m.up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(s.dialect.rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+				m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("ошибка выполнения миграции %04d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrateDown откатывает steps последних применённых миграций
+func (s *Storage) MigrateDown(steps int) ([]*Migration, error) {
+	all, err := s.migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+	for i := len(all) - 1; i >= 0 && len(done) < steps; i-- {
+		m := all[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.down == "" {
+			return done, fmt.Errorf("миграция %04d_%s не поддерживает откат", m.Version, m.Name)
+		}
+		err = s.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(m.down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(s.dialect.rebind("DELETE FROM schema_migrations WHERE version = ?"), m.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("ошибка отката миграции %04d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrationStatus возвращает все известные миграции, AppliedAt пуст у неприменённых
+func (s *Storage) MigrationStatus() ([]MigrationStatus, error) {
+	all, err := s.migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]MigrationStatus, 0, len(all))
+	for _, m := range all {
+		res = append(res, MigrationStatus{Version: m.Version, Name: m.Name, AppliedAt: applied[m.Version]})
+	}
+	return res, nil
+}
+
+// inTx выполняет fn в транзакции. Хранилище, привязанное к транзакции WithTx, использует её
+func (s *Storage) inTx(fn func(tx *sql.Tx) error) error {
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS task_meta (
+	task_id BIGINT PRIMARY KEY REFERENCES scheduler (id) ON DELETE CASCADE,
+	status VARCHAR(16) NOT NULL DEFAULT 'active'
+);
+CREATE TABLE IF NOT EXISTS completions (
+	id BIGSERIAL PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	title VARCHAR(128) NOT NULL DEFAULT '',
+	date CHAR(8) NOT NULL DEFAULT '',
+	next_date CHAR(8) NOT NULL DEFAULT '',
+	completed_at VARCHAR(20) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS completions_task_index ON completions (task_id);
+CREATE INDEX IF NOT EXISTS completions_completed_index ON completions (completed_at);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS task_tags;
+ALTER TABLE task_meta DROP COLUMN project_id;
+ALTER TABLE task_meta DROP COLUMN priority;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS projects;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS projects (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(64) NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS tags (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(64) NOT NULL UNIQUE
+);
+ALTER TABLE task_meta ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE task_meta ADD COLUMN project_id BIGINT REFERENCES projects (id);
+CREATE TABLE IF NOT EXISTS task_tags (
+	task_id BIGINT NOT NULL REFERENCES scheduler (id) ON DELETE CASCADE,
+	tag_id BIGINT NOT NULL REFERENCES tags (id),
+	PRIMARY KEY (task_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS task_tags_tag_index ON task_tags (tag_id);
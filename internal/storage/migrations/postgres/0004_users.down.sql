@@ -0,0 +1,4 @@
+ALTER TABLE completions DROP COLUMN user_id;
+DROP INDEX IF EXISTS task_meta_user_index;
+ALTER TABLE task_meta DROP COLUMN user_id;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS users (
+	id BIGSERIAL PRIMARY KEY,
+	login VARCHAR(64) NOT NULL UNIQUE,
+	password_hash VARCHAR(100) NOT NULL DEFAULT '',
+	is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at VARCHAR(20) NOT NULL DEFAULT ''
+);
+-- Задачи без владельца (user_id = 0) доступны, когда аутентификация отключена
+ALTER TABLE task_meta ADD COLUMN user_id BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS task_meta_user_index ON task_meta (user_id);
+ALTER TABLE completions ADD COLUMN user_id BIGINT NOT NULL DEFAULT 0;
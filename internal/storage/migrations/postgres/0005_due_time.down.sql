@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN timezone;
+ALTER TABLE task_meta DROP COLUMN timezone;
+ALTER TABLE task_meta DROP COLUMN due_time;
@@ -0,0 +1,5 @@
+-- Необязательное время выполнения (ЧЧ:ММ) и часовой пояс IANA задачи
+ALTER TABLE task_meta ADD COLUMN due_time VARCHAR(5) NOT NULL DEFAULT '';
+ALTER TABLE task_meta ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT '';
+-- Часовой пояс пользователя для задач без собственного пояса
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+-- Отправленные напоминания: по одной записи на срок задачи, смещение и канал
+CREATE TABLE IF NOT EXISTS reminders_sent (
+	task_id BIGINT NOT NULL REFERENCES scheduler (id) ON DELETE CASCADE,
+	due VARCHAR(25) NOT NULL,
+	offset_minutes INTEGER NOT NULL,
+	channel VARCHAR(32) NOT NULL,
+	sent_at VARCHAR(20) NOT NULL DEFAULT '',
+	PRIMARY KEY (task_id, due, offset_minutes, channel)
+);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL DEFAULT 0,
+	url TEXT NOT NULL,
+	secret VARCHAR(64) NOT NULL,
+	events TEXT NOT NULL DEFAULT '',
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at VARCHAR(20) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS webhooks_user_index ON webhooks (user_id);
+-- Очередь и журнал доставки: записи в состоянии pending ждут отправки
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id BIGSERIAL PRIMARY KEY,
+	webhook_id BIGINT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+	event VARCHAR(32) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at VARCHAR(20) NOT NULL DEFAULT '',
+	response_code INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at VARCHAR(20) NOT NULL DEFAULT '',
+	delivered_at VARCHAR(20) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_index ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_index ON webhook_deliveries (webhook_id);
@@ -0,0 +1,6 @@
+-- Токены подписки на календарь: хранится только SHA-256 токена, по одному на пользователя
+CREATE TABLE IF NOT EXISTS feed_tokens (
+	user_id BIGINT PRIMARY KEY,
+	token_hash VARCHAR(64) NOT NULL UNIQUE,
+	created_at VARCHAR(20) NOT NULL DEFAULT ''
+);
@@ -0,0 +1,2 @@
+-- Версия задачи для оптимистической блокировки, увеличивается при каждом изменении
+ALTER TABLE task_meta ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS date_index;
+DROP TABLE IF EXISTS scheduler;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS completions;
+DROP TRIGGER IF EXISTS scheduler_delete_meta;
+DROP TABLE IF EXISTS task_meta;
@@ -0,0 +1,19 @@
+-- Состояние задачи хранится отдельно, чтобы не менять структуру scheduler
+CREATE TABLE IF NOT EXISTS task_meta (
+	task_id INTEGER PRIMARY KEY,
+	status VARCHAR(16) NOT NULL DEFAULT 'active'
+);
+CREATE TRIGGER IF NOT EXISTS scheduler_delete_meta AFTER DELETE ON scheduler
+BEGIN
+	DELETE FROM task_meta WHERE task_id = old.id;
+END;
+CREATE TABLE IF NOT EXISTS completions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id INTEGER NOT NULL,
+	title VARCHAR(128) NOT NULL DEFAULT '',
+	date CHAR(8) NOT NULL DEFAULT '',
+	next_date CHAR(8) NOT NULL DEFAULT '',
+	completed_at VARCHAR(20) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS completions_task_index ON completions (task_id);
+CREATE INDEX IF NOT EXISTS completions_completed_index ON completions (completed_at);
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS scheduler_delete_tags;
+DROP TABLE IF EXISTS task_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS projects;
+ALTER TABLE task_meta DROP COLUMN project_id;
+ALTER TABLE task_meta DROP COLUMN priority;
@@ -0,0 +1,20 @@
+ALTER TABLE task_meta ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE task_meta ADD COLUMN project_id INTEGER;
+CREATE TABLE IF NOT EXISTS projects (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(64) NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(64) NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS task_tags (
+	task_id INTEGER NOT NULL,
+	tag_id INTEGER NOT NULL,
+	PRIMARY KEY (task_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS task_tags_tag_index ON task_tags (tag_id);
+CREATE TRIGGER IF NOT EXISTS scheduler_delete_tags AFTER DELETE ON scheduler
+BEGIN
+	DELETE FROM task_tags WHERE task_id = old.id;
+END;
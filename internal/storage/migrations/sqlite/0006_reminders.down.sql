@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS scheduler_delete_reminders;
+DROP TABLE IF EXISTS reminders_sent;
@@ -0,0 +1,13 @@
+-- Отправленные напоминания: по одной записи на срок задачи, смещение и канал
+CREATE TABLE IF NOT EXISTS reminders_sent (
+	task_id INTEGER NOT NULL,
+	due VARCHAR(25) NOT NULL,
+	offset_minutes INTEGER NOT NULL,
+	channel VARCHAR(32) NOT NULL,
+	sent_at VARCHAR(20) NOT NULL DEFAULT '',
+	PRIMARY KEY (task_id, due, offset_minutes, channel)
+);
+CREATE TRIGGER IF NOT EXISTS scheduler_delete_reminders AFTER DELETE ON scheduler
+BEGIN
+	DELETE FROM reminders_sent WHERE task_id = old.id;
+END;
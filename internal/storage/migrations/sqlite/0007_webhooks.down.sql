@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS webhooks_delete_deliveries;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
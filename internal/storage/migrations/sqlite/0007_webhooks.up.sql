@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL DEFAULT 0,
+	url TEXT NOT NULL,
+	secret VARCHAR(64) NOT NULL,
+	events TEXT NOT NULL DEFAULT '',
+	active INTEGER NOT NULL DEFAULT 1,
+	created_at VARCHAR(20) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS webhooks_user_index ON webhooks (user_id);
+-- Очередь и журнал доставки: записи в состоянии pending ждут отправки
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	webhook_id INTEGER NOT NULL,
+	event VARCHAR(32) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at VARCHAR(20) NOT NULL DEFAULT '',
+	response_code INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at VARCHAR(20) NOT NULL DEFAULT '',
+	delivered_at VARCHAR(20) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_index ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_index ON webhook_deliveries (webhook_id);
+CREATE TRIGGER IF NOT EXISTS webhooks_delete_deliveries AFTER DELETE ON webhooks
+BEGIN
+	DELETE FROM webhook_deliveries WHERE webhook_id = old.id;
+END;
@@ -0,0 +1,11 @@
+-- Сохранённые поиски пользователей, название уникально у каждого пользователя
+CREATE TABLE IF NOT EXISTS saved_filters (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL DEFAULT 0,
+	name VARCHAR(64) NOT NULL,
+	query TEXT NOT NULL DEFAULT '',
+	sort VARCHAR(16) NOT NULL DEFAULT '',
+	sort_order VARCHAR(4) NOT NULL DEFAULT '',
+	created_at VARCHAR(20) NOT NULL DEFAULT '',
+	UNIQUE (user_id, name)
+);
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// pgUniqueViolation — код ошибки PostgreSQL при нарушении ограничения уникальности
+const pgUniqueViolation = "23505"
+
+var postgresDialect = &dialect{
+	name:      "postgres",
+	driver:    "pgx",
+	bindType:  sqlx.DOLLAR,
+	like:      "ILIKE",
+	returning: true,
+	lockRows:  " FOR UPDATE OF s",
+	unique: func(err error) bool {
+		var e *pgconn.PgError
+		return errors.As(err, &e) && e.Code == pgUniqueViolation
+	},
+}
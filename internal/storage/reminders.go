@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"time"
+)
+
+func (s *Storage) ReminderSent(taskID, due string, offset time.Duration, channel string) (bool, error) {
+	var count int
+	err := s.conn().QueryRow(s.dialect.rebind(`SELECT COUNT(*) FROM reminders_sent
+		WHERE task_id = ? AND due = ? AND offset_minutes = ? AND channel = ?`),
+		taskID, due, int(offset/time.Minute), channel).Scan(&count)
+	return count > 0, err
+}
+
+func (s *Storage) SaveReminder(taskID, due string, offset time.Duration, channel string) error {
+	_, err := s.conn().Exec(s.dialect.rebind(`INSERT INTO reminders_sent (task_id, due, offset_minutes, channel, sent_at)
+		VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`),
+		taskID, due, int(offset/time.Minute), channel, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+// Длина фрагмента текста в символах при поиске без FTS5
+const snippetLen = 80
+
+// snippet() FTS5 окружает найденные слова управляющими символами вместо тегов,
+// чтобы текст задачи можно было экранировать, не задев разметку
+var snippetMarks = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// escapeSnippet экранирует фрагмент от snippet() FTS5 для HTML и расставляет теги <mark></mark>
+func escapeSnippet(snippet string) string {
+	return snippetMarks.Replace(html.EscapeString(snippet))
+}
+
+// fullText сообщает, ищет ли фильтр по индексу FTS5
+func (s *Storage) fullText(filter *domain.Filter) bool {
+	return s.dialect.fts && strings.TrimSpace(filter.SearchTerm) != ""
+}
+
+// ftsQuery составляет запрос FTS5 из слов поиска: каждое слово ищется как начало слова в тексте,
+// задача должна содержать все слова. Слова берутся в кавычки, чтобы символы синтаксиса FTS5
+// из запроса пользователя не разбирались как операторы
+func ftsQuery(words []string) string {
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"*`
+	}
+	return strings.Join(terms, " ")
+}
+
+// searchCondition переводит условие поиска в выражение WHERE с аргументами.
+// Слова в заголовке и комментарии SQLite ищет по индексу FTS5 так же, как SearchTerm
+func (s *Storage) searchCondition(c domain.Condition) (string, []interface{}) {
+	var expr string
+	var args []interface{}
+	switch c.Field {
+	case domain.FieldText, domain.FieldTitle, domain.FieldComment:
+		if s.dialect.fts {
+			query := ftsQuery(strings.Fields(c.Value))
+			if c.Field != domain.FieldText {
+				//Фильтр колонки FTS5: title : (...)
+				query = c.Field + " : (" + query + ")"
+			}
+			expr = "s.id IN (SELECT rowid FROM scheduler_fts WHERE scheduler_fts MATCH ?)"
+			args = []interface{}{query}
+			break
+		}
+		pattern := "%" + c.Value + "%"
+		if c.Field == domain.FieldText {
+			expr = fmt.Sprintf("(s.title %[1]s ? OR s.comment %[1]s ?)", s.dialect.like)
+			args = []interface{}{pattern, pattern}
+		} else {
+			expr = fmt.Sprintf("s.%s %s ?", c.Field, s.dialect.like)
+			args = []interface{}{pattern}
+		}
+	case domain.FieldRepeat:
+		expr = "s.repeat LIKE ?"
+		args = []interface{}{c.Value + "%"}
+	case domain.FieldRecurring:
+		expr = "s.repeat <> ''"
+	case domain.FieldTag:
+		expr = `s.id IN (SELECT tt.task_id FROM task_tags tt
+			JOIN tags t ON t.id = tt.tag_id WHERE t.name = ?)`
+		args = []interface{}{c.Value}
+	case domain.FieldProject:
+		expr = "COALESCE(p.name, '') = ?"
+		args = []interface{}{c.Value}
+	default:
+		return "", nil
+	}
+	if c.Not {
+		expr = "NOT (" + expr + ")"
+	}
+	return expr, args
+}
+
+// highlight выделяет слова поиска в заголовке, а если их там нет — в комментарии.
+// Заменяет snippet() FTS5 для СУБД без полнотекстового индекса
+func highlight(title, comment string, words []string) string {
+	for _, text := range []string{title, comment} {
+		if snippet, ok := markWords(text, words); ok {
+			return snippet
+		}
+	}
+	return ""
+}
+
+// markWords окружает вхождения слов без учёта регистра тегами <mark></mark>
+// и обрезает длинный текст вокруг первого вхождения. Текст экранируется для HTML
+func markWords(text string, words []string) (string, bool) {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, word := range words {
+		w := []rune(strings.ToLower(word))
+		for i := 0; len(w) > 0 && i+len(w) <= len(lower); i++ {
+			if string(lower[i:i+len(w)]) != string(w) {
+				continue
+			}
+			for j := i; j < i+len(w); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	start, end := 0, len(runes)
+	if len(runes) > snippetLen {
+		start = max(0, first-snippetLen/4)
+		end = min(len(runes), start+snippetLen)
+	}
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; i++ {
+		if marked[i] && (i == start || !marked[i-1]) {
+			b.WriteString("<mark>")
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		if marked[i] && (i == end-1 || !marked[i+1]) {
+			b.WriteString("</mark>")
+		}
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var sqliteDialect = &dialect{
+	name:     "sqlite",
+	driver:   "sqlite",
+	bindType: sqlx.QUESTION,
+	like:     "LIKE",
+	//Ожидание блокировки вместо немедленной ошибки SQLITE_BUSY при параллельной записи.
+	//Транзакции сразу берут блокировку записи: иначе две транзакции, начавшие с чтения,
+	//не смогли бы обе перейти к записи и одна из них завершалась бы ошибкой
+	params: "_pragma=busy_timeout(5000)&_txlock=immediate",
+	fts:    true,
+	unique: func(err error) bool {
+		var e *sqlite.Error
+		return errors.As(err, &e) && e.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	},
+}
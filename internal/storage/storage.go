@@ -4,77 +4,114 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/agidelle/todo_web/internal/config"
 	"github.com/agidelle/todo_web/internal/domain"
-	_ "modernc.org/sqlite"
 )
 
 type Storage struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect *dialect
+	//Транзакция WithTx, в которой выполняются все запросы хранилища
+	tx *sql.Tx
 }
 
 func NewStorage(db *sql.DB) *Storage {
-	return &Storage{db: db}
+	return &Storage{db: db, dialect: sqliteDialect}
 }
 
 func NewConn(cfg *config.Config) *Storage {
-	//Проверка на наличие БД и миграции
-	CheckDB(cfg)
+	dsn := cfg.DBPath
+	if cfg.DBdriver == "postgres" {
+		dsn = cfg.DSN
+	}
 
 	//Инициализация БД
-	db, err := sql.Open(cfg.DBdriver, cfg.DBPath)
+	s, err := Open(cfg.DBdriver, dsn)
+	if err != nil {
+		log.Fatalf("Ошибка подключения к БД: %v", err)
+	}
+
+	//Миграции при запуске или проверка, что схема актуальна
+	if cfg.Migrate {
+		applied, err := s.MigrateUp()
+		if err != nil {
+			s.Close()
+			log.Fatalf("миграции не удались: %v", err)
+		}
+		for _, m := range applied {
+			log.Printf("Применена миграция %04d_%s", m.Version, m.Name)
+		}
+		return s
+	}
+	status, err := s.MigrationStatus()
 	if err != nil {
-		log.Fatalf("Ошибка открытия БД: %v", err)
+		s.Close()
+		log.Fatalf("Ошибка проверки миграций: %v", err)
+	}
+	for _, m := range status {
+		if m.AppliedAt == "" {
+			s.Close()
+			log.Fatalf("миграция %04d_%s не применена, выполните команду migrate up", m.Version, m.Name)
+		}
+	}
+	return s
+}
+
+// Open открывает соединение с БД выбранного драйвера (sqlite или postgres)
+func Open(driver, dsn string) (*Storage, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(d.driver, d.dsn(dsn))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия БД: %w", err)
 	}
 	err = db.Ping()
 	if err != nil {
 		db.Close()
-		log.Fatalf("Ошибка проверки соединения с БД: %v", err)
+		return nil, fmt.Errorf("ошибка проверки соединения с БД: %w", err)
 	}
-	return &Storage{db: db}
+	return &Storage{db: db, dialect: d}, nil
 }
 
-func CheckDB(cfg *config.Config) {
-	_, err := os.Stat(cfg.DBPath)
-	dbExists := !os.IsNotExist(err)
+// querier — общие методы *sql.DB и *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
 
-	//Если файл БД отсутствует выполняем миграции
-	if !dbExists {
-		if err = RunMigrations(cfg); err != nil {
-			os.Remove(cfg.DBPath)
-			log.Fatalf("миграции не удались: %v", err)
-		}
-		log.Println("База данных успешно создана")
+// conn возвращает транзакцию WithTx или соединение с БД
+func (s *Storage) conn() querier {
+	if s.tx != nil {
+		return s.tx
 	}
+	return s.db
 }
 
-func RunMigrations(cfg *config.Config) error {
-	db, err := sql.Open(cfg.DBdriver, cfg.DBPath)
-	if err != nil {
-		log.Printf("не удалось открыть БД: %v", err)
-	}
+func (s *Storage) WithTx(fn func(repo domain.TaskRepository) error) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		return fn(&Storage{db: s.db, dialect: s.dialect, tx: tx})
+	})
+}
 
-	//Миграция для SQLite
-	schema := []string{
-		`CREATE TABLE IF NOT EXISTS scheduler (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			date CHAR(8) NOT NULL DEFAULT '',
-			title VARCHAR(128) NOT NULL DEFAULT '',
-			comment TEXT NOT NULL DEFAULT '',
-			repeat VARCHAR(128) NOT NULL DEFAULT ''
-		);`,
-		`CREATE INDEX IF NOT EXISTS date_index ON scheduler (date);`,
+// insert выполняет INSERT и возвращает id новой записи
+func (s *Storage) insert(q querier, query string, args ...interface{}) (int64, error) {
+	if s.dialect.returning {
+		var id int64
+		err := q.QueryRow(s.dialect.rebind(query+" RETURNING id"), args...).Scan(&id)
+		return id, err
 	}
-
-	for _, query := range schema {
-		if _, err := db.Exec(query); err != nil {
-			return fmt.Errorf("ошибка выполнения миграции: %v\nЗапрос: %s", err, query)
-		}
+	res, err := q.Exec(s.dialect.rebind(query), args...)
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	return res.LastInsertId()
 }
 
 func (s *Storage) Close() error {
@@ -84,37 +121,100 @@ func (s *Storage) Close() error {
 	}
 	return nil
 }
-func (s *Storage) FindTask(filter *domain.Filter) ([]*domain.Task, error) {
-	tasks := make([]*domain.Task, 0)
-	query := "SELECT id, date, title, comment, repeat FROM scheduler"
-	args := []interface{}{}
-	conditions := []string{}
 
-	//Добавление условий в зависимости от фильтра
-	if filter.ID != nil {
-		conditions = append(conditions, "id = ?")
-		args = append(args, *filter.ID)
+// sortKeys — выражения сортировки для ключей domain.Sort*. Последним идёт id,
+// чтобы порядок был однозначным и курсор указывал на одну задачу.
+// Задачи без времени идут первыми в своём дне
+var sortKeys = map[string][]string{
+	domain.SortDate:     {"s.date", "COALESCE(m.due_time, '')", "s.id"},
+	domain.SortTitle:    {"s.title", "s.id"},
+	domain.SortPriority: {"COALESCE(m.priority, 0)", "s.id"},
+	domain.SortCreated:  {"s.id"},
+}
+
+// orderKeys возвращает выражения сортировки по фильтру. Релевантность есть только
+// у полнотекстового поиска, без него задачи сортируются по дате
+func (s *Storage) orderKeys(filter *domain.Filter) []string {
+	if filter.Sort == domain.SortRank && s.fullText(filter) {
+		return []string{"bm25(scheduler_fts)", "s.id"}
 	}
-	if filter.SearchTerm != "" {
-		searchPattern := "%" + filter.SearchTerm + "%"
-		conditions = append(conditions, "(title LIKE ? OR comment LIKE ?)")
-		args = append(args, searchPattern, searchPattern)
+	keys, ok := sortKeys[filter.Sort]
+	if !ok {
+		keys = sortKeys[domain.SortDate]
 	}
-	if filter.Date != "" {
-		conditions = append(conditions, "date = ?")
-		args = append(args, filter.Date)
+	return keys
+}
+
+// cursorValues возвращает значения ключа курсора в порядке выражений orderKeys
+func cursorValues(c *domain.Cursor, ranked bool) []interface{} {
+	switch {
+	case ranked:
+		return []interface{}{c.Rank, c.ID}
+	case c.Sort == domain.SortTitle:
+		return []interface{}{c.Title, c.ID}
+	case c.Sort == domain.SortPriority:
+		return []interface{}{c.Priority, c.ID}
+	case c.Sort == domain.SortCreated:
+		return []interface{}{c.ID}
+	default:
+		return []interface{}{c.Date, c.Time, c.ID}
+	}
+}
+
+// taskFrom возвращает источник выборки задач, при полнотекстовом поиске — вместе с индексом
+func (s *Storage) taskFrom(filter *domain.Filter) string {
+	from := "FROM scheduler s"
+	if s.fullText(filter) {
+		from += " JOIN scheduler_fts ON scheduler_fts.rowid = s.id"
+	}
+	return from + `
+		LEFT JOIN task_meta m ON m.task_id = s.id
+		LEFT JOIN projects p ON p.id = m.project_id`
+}
+
+func (s *Storage) FindTask(filter *domain.Filter) ([]*domain.Task, error) {
+	tasks := make([]*domain.Task, 0)
+	query := `SELECT s.id, s.date, s.title, s.comment, s.repeat, COALESCE(m.priority, 0), COALESCE(p.name, ''), COALESCE(m.user_id, 0),
+		COALESCE(m.due_time, ''), COALESCE(m.timezone, ''), COALESCE(m.status, 'active'), COALESCE(m.version, 1), `
+	//Релевантность и фрагмент текста с найденными словами даёт индекс FTS5
+	indexed := s.fullText(filter)
+	if indexed {
+		query += "bm25(scheduler_fts), snippet(scheduler_fts, -1, char(2), char(3), '…', 12) "
+	} else {
+		query += "0, '' "
 	}
+	query += s.taskFrom(filter)
+	conditions, args := s.taskConditions(filter)
 
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	keys := s.orderKeys(filter)
+	//Следующая страница начинается сразу после задачи из курсора
+	if filter.After != nil {
+		values := cursorValues(filter.After, indexed && filter.Sort == domain.SortRank)
+		op := ">"
+		if filter.Desc {
+			op = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s) %s (%s)",
+			strings.Join(keys, ", "), op, strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")))
+		args = append(args, values...)
 	}
-	query += " ORDER BY date"
+	order := strings.Join(keys, ", ")
+	if filter.Desc {
+		order = strings.Join(keys, " DESC, ") + " DESC"
+	}
+
+	query += " WHERE " + strings.Join(conditions, " AND ")
+	query += " ORDER BY " + order
 	if filter.Limit > 0 {
 		query += " LIMIT ?"
 		args = append(args, filter.Limit)
 	}
+	//SQLite блокирует всю БД в начале транзакции, PostgreSQL — только найденные строки
+	if filter.ForUpdate && s.tx != nil {
+		query += s.dialect.lockRows
+	}
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.conn().Query(s.dialect.rebind(query), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -125,49 +225,217 @@ func (s *Storage) FindTask(filter *domain.Filter) ([]*domain.Task, error) {
 	}()
 	for rows.Next() {
 		var t domain.Task
-		err = rows.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat)
+		err = rows.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat, &t.Priority, &t.Project, &t.UserID, &t.Time, &t.Timezone, &t.Status, &t.Version,
+			&t.Rank, &t.Snippet)
 		if err != nil {
 			return nil, err
 		}
+		//Без FTS5 фрагмент с найденными словами собирается здесь
+		switch {
+		case indexed:
+			t.Snippet = escapeSnippet(t.Snippet)
+		case filter.SearchTerm != "":
+			t.Snippet = highlight(t.Title, t.Comment, strings.Fields(filter.SearchTerm))
+		}
 		tasks = append(tasks, &t)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
+	if err = s.loadTags(tasks); err != nil {
+		return nil, err
+	}
 
 	return tasks, nil
 }
 
+func (s *Storage) CountTasks(filter *domain.Filter) (int, error) {
+	conditions, args := s.taskConditions(filter)
+	query := "SELECT COUNT(*) " + s.taskFrom(filter) + " WHERE " + strings.Join(conditions, " AND ")
+	var count int
+	if err := s.conn().QueryRow(s.dialect.rebind(query), args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// taskConditions собирает условия WHERE и их аргументы по фильтру задач
+func (s *Storage) taskConditions(filter *domain.Filter) ([]string, []interface{}) {
+	args := []interface{}{}
+	conditions := []string{}
+
+	//По умолчанию ищем только активные задачи
+	status := filter.Status
+	if status == "" {
+		status = domain.StatusActive
+	}
+	if status != domain.StatusAll {
+		conditions = append(conditions, "COALESCE(m.status, 'active') = ?")
+		args = append(args, status)
+	}
+	//Пользователь видит только свои задачи
+	if !filter.AllUsers {
+		conditions = append(conditions, "COALESCE(m.user_id, 0) = ?")
+		args = append(args, filter.UserID)
+	}
+
+	//Добавление условий в зависимости от фильтра
+	if filter.ID != nil {
+		conditions = append(conditions, "s.id = ?")
+		args = append(args, *filter.ID)
+	}
+	//Задача должна содержать все слова поиска
+	if words := strings.Fields(filter.SearchTerm); len(words) > 0 {
+		if s.dialect.fts {
+			conditions = append(conditions, "scheduler_fts MATCH ?")
+			args = append(args, ftsQuery(words))
+		} else {
+			for _, word := range words {
+				pattern := "%" + word + "%"
+				conditions = append(conditions, fmt.Sprintf("(s.title %[1]s ? OR s.comment %[1]s ?)", s.dialect.like))
+				args = append(args, pattern, pattern)
+			}
+		}
+	}
+	if filter.Date != "" {
+		conditions = append(conditions, "s.date = ?")
+		args = append(args, filter.Date)
+	}
+	if filter.From != "" {
+		conditions = append(conditions, "s.date >= ?")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		conditions = append(conditions, "s.date <= ?")
+		args = append(args, filter.To)
+	}
+	if filter.Priority > 0 {
+		conditions = append(conditions, "COALESCE(m.priority, 0) >= ?")
+		args = append(args, filter.Priority)
+	}
+	if filter.Project != "" {
+		conditions = append(conditions, "p.name = ?")
+		args = append(args, filter.Project)
+	}
+	//Задача должна иметь все указанные метки
+	for _, tag := range filter.Tags {
+		conditions = append(conditions, `s.id IN (SELECT tt.task_id FROM task_tags tt
+			JOIN tags t ON t.id = tt.tag_id WHERE t.name = ?)`)
+		args = append(args, tag)
+	}
+	for _, c := range filter.Conditions {
+		if expr, values := s.searchCondition(c); expr != "" {
+			conditions = append(conditions, expr)
+			args = append(args, values...)
+		}
+	}
+	return conditions, args
+}
+
 func (s *Storage) CreateTask(task *domain.Task) (int64, error) {
-	res, err := s.db.Exec("INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)", task.Date, task.Title, task.Comment, task.Repeat)
+	var id int64
+	err := s.inTx(func(tx *sql.Tx) error {
+		var err error
+		id, err = s.createTask(tx, task)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
+	return id, nil
+}
 
-	id, err := res.LastInsertId()
+func (s *Storage) CreateTasks(tasks []*domain.Task) ([]int64, error) {
+	ids := make([]int64, 0, len(tasks))
+	err := s.inTx(func(tx *sql.Tx) error {
+		for _, task := range tasks {
+			id, err := s.createTask(tx, task)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (s *Storage) createTask(tx *sql.Tx, task *domain.Task) (int64, error) {
+	id, err := s.insert(tx, "INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)", task.Date, task.Title, task.Comment, task.Repeat)
 	if err != nil {
 		return 0, err
 	}
-	return id, nil
+	return id, s.saveMeta(tx, id, task)
 }
 
 func (s *Storage) UpdateTask(task *domain.Task) error {
-	query, err := s.db.Exec("UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?", task.Date, task.Title, task.Comment, task.Repeat, task.ID)
+	id, err := strconv.ParseInt(task.ID, 10, 64)
+	if err != nil {
+		return domain.ErrNotFound
+	}
+	return s.inTx(func(tx *sql.Tx) error {
+		//Выполненные задачи остаются в истории без изменений, чужие задачи не изменяются
+		query := `UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?
+			AND id NOT IN (SELECT task_id FROM task_meta WHERE status = 'done')
+			AND ` + ownerCondition
+		args := []interface{}{task.Date, task.Title, task.Comment, task.Repeat, id, task.UserID}
+		if task.Version > 0 {
+			query += " AND " + versionCondition
+			args = append(args, task.Version)
+		}
+		res, err := tx.Exec(s.dialect.rebind(query), args...)
+		if err != nil {
+			return err
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return domain.ErrNotFound
+		}
+		return s.saveMeta(tx, id, task)
+	})
+}
+
+// ownerCondition ограничивает запрос к scheduler задачами одного пользователя
+const ownerCondition = "COALESCE((SELECT user_id FROM task_meta WHERE task_id = scheduler.id), 0) = ?"
+
+// versionCondition ограничивает запрос к scheduler задачей ожидаемой версии
+const versionCondition = "COALESCE((SELECT version FROM task_meta WHERE task_id = scheduler.id), 1) = ?"
+
+func (s *Storage) DeleteTask(filter *domain.Filter) error {
+	query := "DELETE FROM scheduler WHERE id = ? AND " + ownerCondition
+	args := []interface{}{filter.ID, filter.UserID}
+	if filter.Version > 0 {
+		query += " AND " + versionCondition
+		args = append(args, filter.Version)
+	}
+	res, err := s.conn().Exec(s.dialect.rebind(query), args...)
 	if err != nil {
 		return err
 	}
-	count, err := query.RowsAffected()
+	count, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
 	if count == 0 {
-		return fmt.Errorf("id задачи не найден в БД")
+		return domain.ErrNotFound
 	}
 	return nil
 }
 
-func (s *Storage) DeleteTask(id *int) error {
-	query, err := s.db.Exec("DELETE FROM scheduler WHERE id = ?", id)
+func (s *Storage) SetStatus(filter *domain.Filter, status string) error {
+	update := `UPDATE task_meta SET status = ?, version = version + 1 WHERE task_id = ? AND user_id = ?`
+	args := []interface{}{status, filter.ID, filter.UserID}
+	if filter.Version > 0 {
+		update += " AND version = ?"
+		args = append(args, filter.Version)
+	}
+	query, err := s.conn().Exec(s.dialect.rebind(update), args...)
 	if err != nil {
 		return err
 	}
@@ -175,8 +443,78 @@ func (s *Storage) DeleteTask(id *int) error {
 	if err != nil {
 		return err
 	}
+	if count > 0 {
+		return nil
+	}
+	//Задачи без записи в task_meta имеют версию 1
+	if filter.Version > 1 {
+		return domain.ErrNotFound
+	}
+	//Задачи, созданные до появления task_meta, получают запись при первой смене состояния
+	query, err = s.conn().Exec(s.dialect.rebind(`INSERT INTO task_meta (task_id, status, user_id, version)
+		SELECT id, ?, ?, 2 FROM scheduler WHERE id = ? AND `+ownerCondition+`
+		ON CONFLICT (task_id) DO NOTHING`), status, filter.UserID, filter.ID, filter.UserID)
+	if err != nil {
+		return err
+	}
+	count, err = query.RowsAffected()
+	if err != nil {
+		return err
+	}
 	if count == 0 {
-		return fmt.Errorf("id задачи не найден в БД")
+		return domain.ErrNotFound
 	}
 	return nil
 }
+
+func (s *Storage) AddCompletion(completion *domain.Completion) (int64, error) {
+	return s.insert(s.conn(), "INSERT INTO completions (task_id, title, date, next_date, completed_at, user_id) VALUES (?, ?, ?, ?, ?, ?)",
+		completion.TaskID, completion.Title, completion.Date, completion.NextDate, completion.CompletedAt, completion.UserID)
+}
+
+func (s *Storage) FindCompletions(filter *domain.CompletionFilter) ([]*domain.Completion, error) {
+	completions := make([]*domain.Completion, 0)
+	query := "SELECT id, task_id, title, date, next_date, completed_at FROM completions"
+	args := []interface{}{filter.UserID}
+	conditions := []string{"user_id = ?"}
+
+	if filter.TaskID != nil {
+		conditions = append(conditions, "task_id = ?")
+		args = append(args, *filter.TaskID)
+	}
+	//Время выполнения хранится в UTC в формате RFC 3339, поэтому строки сравнимы
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "completed_at >= ?")
+		args = append(args, filter.From.UTC().Format(time.RFC3339))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "completed_at < ?")
+		args = append(args, filter.To.UTC().Format(time.RFC3339))
+	}
+
+	query += " WHERE " + strings.Join(conditions, " AND ")
+	query += " ORDER BY completed_at, id"
+
+	rows, err := s.conn().Query(s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+	for rows.Next() {
+		var c domain.Completion
+		err = rows.Scan(&c.ID, &c.TaskID, &c.Title, &c.Date, &c.NextDate, &c.CompletedAt)
+		if err != nil {
+			return nil, err
+		}
+		completions = append(completions, &c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return completions, nil
+}
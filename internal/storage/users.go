@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+func (s *Storage) CreateUser(user *domain.User) (int64, error) {
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	id, err := s.insert(s.conn(), "INSERT INTO users (login, password_hash, is_admin, timezone, created_at) VALUES (?, ?, ?, ?, ?)",
+		user.Login, user.PasswordHash, user.IsAdmin, user.Timezone, createdAt)
+	if err != nil {
+		return 0, err
+	}
+	user.ID = id
+	user.CreatedAt = createdAt
+	return id, nil
+}
+
+// FindUser ищет пользователя по логину, возвращает nil, если такого нет
+func (s *Storage) FindUser(login string) (*domain.User, error) {
+	return s.findUser("login = ?", login)
+}
+
+// FindUserByID ищет пользователя по id, возвращает nil, если такого нет
+func (s *Storage) FindUserByID(id int64) (*domain.User, error) {
+	return s.findUser("id = ?", id)
+}
+
+func (s *Storage) findUser(condition string, arg interface{}) (*domain.User, error) {
+	var u domain.User
+	err := s.conn().QueryRow(s.dialect.rebind("SELECT id, login, password_hash, is_admin, timezone, created_at FROM users WHERE "+condition), arg).
+		Scan(&u.ID, &u.Login, &u.PasswordHash, &u.IsAdmin, &u.Timezone, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *Storage) ListUsers() ([]*domain.User, error) {
+	users := make([]*domain.User, 0)
+	rows, err := s.conn().Query("SELECT id, login, password_hash, is_admin, timezone, created_at FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+	for rows.Next() {
+		var u domain.User
+		if err = rows.Scan(&u.ID, &u.Login, &u.PasswordHash, &u.IsAdmin, &u.Timezone, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, &u)
+	}
+	return users, rows.Err()
+}
+
+func (s *Storage) SetPassword(id int64, hash string) error {
+	_, err := s.conn().Exec(s.dialect.rebind("UPDATE users SET password_hash = ? WHERE id = ?"), hash, id)
+	return err
+}
+
+func (s *Storage) SetTimezone(id int64, timezone string) error {
+	_, err := s.conn().Exec(s.dialect.rebind("UPDATE users SET timezone = ? WHERE id = ?"), timezone, id)
+	return err
+}
+
+// AssignOrphanTasks передаёт пользователю задачи и журнал без владельца,
+// созданные до включения учётных записей. Возвращает число переданных задач
+func (s *Storage) AssignOrphanTasks(userID int64) (int64, error) {
+	var count int64
+	err := s.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO task_meta (task_id)
+			SELECT id FROM scheduler WHERE id NOT IN (SELECT task_id FROM task_meta)`)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec(s.dialect.rebind("UPDATE task_meta SET user_id = ? WHERE user_id = 0"), userID)
+		if err != nil {
+			return err
+		}
+		if count, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		_, err = tx.Exec(s.dialect.rebind("UPDATE completions SET user_id = ? WHERE user_id = 0"), userID)
+		return err
+	})
+	return count, err
+}
+
+func (s *Storage) SaveFeedToken(userID int64, tokenHash string) error {
+	_, err := s.conn().Exec(s.dialect.rebind(`INSERT INTO feed_tokens (user_id, token_hash, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = excluded.created_at`),
+		userID, tokenHash, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func (s *Storage) FindFeedToken(tokenHash string) (int64, bool, error) {
+	var userID int64
+	err := s.conn().QueryRow(s.dialect.rebind("SELECT user_id FROM feed_tokens WHERE token_hash = ?"), tokenHash).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return userID, true, nil
+}
+
+func (s *Storage) DeleteFeedToken(userID int64) error {
+	_, err := s.conn().Exec(s.dialect.rebind("DELETE FROM feed_tokens WHERE user_id = ?"), userID)
+	return err
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+func (s *Storage) CreateWebhook(hook *domain.Webhook) (int64, error) {
+	hook.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	id, err := s.insert(s.conn(), "INSERT INTO webhooks (user_id, url, secret, events, active, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		hook.UserID, hook.URL, hook.Secret, strings.Join(hook.Events, ","), hook.Active, hook.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	hook.ID = id
+	return id, nil
+}
+
+func (s *Storage) FindWebhooks(userID int64) ([]*domain.Webhook, error) {
+	hooks := make([]*domain.Webhook, 0)
+	rows, err := s.conn().Query(s.dialect.rebind(`SELECT id, user_id, url, secret, events, active, created_at
+		FROM webhooks WHERE user_id = ? ORDER BY id`), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// FindWebhook ищет подписку пользователя, возвращает nil, если такой нет
+func (s *Storage) FindWebhook(userID, id int64) (*domain.Webhook, error) {
+	row := s.conn().QueryRow(s.dialect.rebind(`SELECT id, user_id, url, secret, events, active, created_at
+		FROM webhooks WHERE user_id = ? AND id = ?`), userID, id)
+	hook, err := scanWebhook(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return hook, err
+}
+
+func scanWebhook(row interface{ Scan(dest ...any) error }) (*domain.Webhook, error) {
+	var hook domain.Webhook
+	var events string
+	err := row.Scan(&hook.ID, &hook.UserID, &hook.URL, &hook.Secret, &events, &hook.Active, &hook.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	hook.Events = []string{}
+	if events != "" {
+		hook.Events = strings.Split(events, ",")
+	}
+	return &hook, nil
+}
+
+func (s *Storage) UpdateWebhook(hook *domain.Webhook) error {
+	res, err := s.conn().Exec(s.dialect.rebind("UPDATE webhooks SET url = ?, events = ?, active = ? WHERE user_id = ? AND id = ?"),
+		hook.URL, strings.Join(hook.Events, ","), hook.Active, hook.UserID, hook.ID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res, domain.ErrWebhookNotFound)
+}
+
+func (s *Storage) DeleteWebhook(userID, id int64) error {
+	res, err := s.conn().Exec(s.dialect.rebind("DELETE FROM webhooks WHERE user_id = ? AND id = ?"), userID, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res, domain.ErrWebhookNotFound)
+}
+
+// checkAffected возвращает notFound, если запрос не изменил ни одной строки
+func checkAffected(res sql.Result, notFound error) error {
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// AddDeliveries ставит события в очередь доставки одной транзакцией
+func (s *Storage) AddDeliveries(deliveries []*domain.WebhookDelivery) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		for _, d := range deliveries {
+			id, err := s.insert(tx, `INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt_at, created_at)
+				VALUES (?, ?, ?, ?, ?, ?)`, d.WebhookID, d.Event, d.Payload, d.Status, d.NextAttemptAt, d.CreatedAt)
+			if err != nil {
+				return err
+			}
+			d.ID = id
+		}
+		return nil
+	})
+}
+
+// PendingDeliveries возвращает доставки активных подписок, время попытки которых наступило
+func (s *Storage) PendingDeliveries(now time.Time, limit int) ([]*domain.WebhookDelivery, error) {
+	rows, err := s.conn().Query(s.dialect.rebind(`SELECT d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts,
+			d.next_attempt_at, d.response_code, d.last_error, d.created_at, d.delivered_at, w.url, w.secret
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND w.active = ?
+		ORDER BY d.next_attempt_at, d.id LIMIT ?`),
+		domain.DeliveryPending, now.UTC().Format(time.RFC3339), true, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+	deliveries := make([]*domain.WebhookDelivery, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.ResponseCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt, &d.URL, &d.Secret)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *Storage) UpdateDelivery(d *domain.WebhookDelivery) error {
+	_, err := s.conn().Exec(s.dialect.rebind(`UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?,
+		response_code = ?, last_error = ?, delivered_at = ? WHERE id = ?`),
+		d.Status, d.Attempts, d.NextAttemptAt, d.ResponseCode, d.LastError, d.DeliveredAt, d.ID)
+	return err
+}
+
+// FindDeliveries возвращает последние доставки подписки, новые первыми
+func (s *Storage) FindDeliveries(webhookID int64, limit int) ([]*domain.WebhookDelivery, error) {
+	rows, err := s.conn().Query(s.dialect.rebind(`SELECT id, webhook_id, event, payload, status, attempts,
+			next_attempt_at, response_code, last_error, created_at, delivered_at
+		FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?`), webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+	deliveries := make([]*domain.WebhookDelivery, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.ResponseCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,174 @@
+// Package taskfile выгружает список задач в CSV и JSON и читает его обратно
+// для резервного копирования и переноса задач между серверами.
+package taskfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+// Поддерживаемые форматы
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Columns — столбцы CSV в порядке выгрузки. Метки перечисляются через запятую
+var Columns = []string{"id", "date", "time", "timezone", "title", "comment", "repeat", "priority", "project", "tags", "status"}
+
+var ErrFormat = errors.New("неверный формат файла задач")
+
+// WriteCSV записывает задачи в CSV с заголовком Columns
+func WriteCSV(w io.Writer, tasks []*domain.Task) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Columns); err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		priority := ""
+		if t.Priority > 0 {
+			priority = strconv.Itoa(t.Priority)
+		}
+		err := cw.Write([]string{t.ID, t.Date, t.Time, t.Timezone, t.Title, t.Comment, t.Repeat,
+			priority, t.Project, strings.Join(t.Tags, ","), t.Status})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON записывает задачи объектом {"tasks": [...]}, по одной задаче за раз
+func WriteJSON(w io.Writer, tasks []*domain.Task) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(`{"tasks":[`); err != nil {
+		return err
+	}
+	for i, t := range tasks {
+		if i > 0 {
+			if err := bw.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		if _, err = bw.Write(data); err != nil {
+			return err
+		}
+	}
+	if _, err := bw.WriteString("]}\n"); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ReadCSV читает задачи из CSV. Первая строка — заголовок, порядок столбцов любой,
+// обязателен столбец title, неизвестные столбцы пропускаются.
+// Строки с ошибками попадают в отчёт, ошибка возвращается, если файл не читается целиком
+func ReadCSV(r io.Reader) ([]*domain.ImportItem, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	//Excel добавляет в начало файла BOM
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, fmt.Errorf("%w: нет столбца title", ErrFormat)
+	}
+
+	var items []*domain.ImportItem
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		item := &domain.ImportItem{Index: len(items) + 1, Ref: field("id"), Title: field("title")}
+		items = append(items, item)
+		task := &domain.Task{
+			Date:     field("date"),
+			Time:     field("time"),
+			Timezone: field("timezone"),
+			Title:    field("title"),
+			Comment:  field("comment"),
+			Repeat:   field("repeat"),
+			Project:  field("project"),
+			Status:   field("status"),
+		}
+		if tags := field("tags"); tags != "" {
+			task.Tags = strings.Split(tags, ",")
+		}
+		if priority := field("priority"); priority != "" {
+			if task.Priority, err = strconv.Atoi(priority); err != nil {
+				item.Status = domain.ImportFailed
+				item.Error = domain.ErrPriority.Error()
+				continue
+			}
+		}
+		item.Task = task
+	}
+	return items, nil
+}
+
+// ReadJSON читает задачи из объекта {"tasks": [...]} или массива задач
+func ReadJSON(r io.Reader) ([]*domain.ImportItem, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []*domain.Task
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &tasks)
+	} else {
+		var file struct {
+			Tasks []*domain.Task `json:"tasks"`
+		}
+		err = json.Unmarshal(data, &file)
+		tasks = file.Tasks
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	items := make([]*domain.ImportItem, 0, len(tasks))
+	for i, task := range tasks {
+		item := &domain.ImportItem{Index: i + 1}
+		if task == nil {
+			item.Status = domain.ImportFailed
+			item.Error = "пустая запись"
+			items = append(items, item)
+			continue
+		}
+		item.Ref, item.Title = task.ID, task.Title
+		//Идентификатор и срок вычисляются заново
+		task.ID, task.Due = "", ""
+		item.Task = task
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,275 @@
+// Package todotxt переводит задачи в формат todo.txt и обратно.
+//
+// Строка задачи: [x ](A) заголовок +проект @метка due:2030-01-15 rec:1w.
+// Приоритеты A, B и C соответствуют высокому, среднему и низкому, D-Z считаются низким.
+// Кроме стандартных due: и rec: используются расширения at:ЧЧ:ММ (время),
+// tz:<пояс IANA> и rrule:<RRULE> для правил, которые нельзя записать через rec:.
+// Пробелы в проектах и метках заменяются на «_», комментарии задач в todo.txt не переносятся.
+package todotxt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/ical"
+)
+
+const (
+	// Format — имя формата в параметре format и команде todotxt
+	Format = "todotxt"
+
+	dateForm    = "2006-01-02"
+	taskForm    = "20060102"
+	maxLineSize = 1 << 20
+)
+
+var (
+	priorityPattern = regexp.MustCompile(`^\(([A-Z])\)$`)
+	datePattern     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	recPattern      = regexp.MustCompile(`^\+?(\d+)([dwmyb])$`)
+)
+
+var ErrRec = errors.New("неподдерживаемое правило rec")
+
+// Write записывает задачи в w, по одной на строку
+func Write(w io.Writer, tasks []*domain.Task) error {
+	bw := bufio.NewWriter(w)
+	for _, task := range tasks {
+		if _, err := bw.WriteString(FormatTask(task) + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// FormatTask возвращает строку todo.txt для задачи
+func FormatTask(task *domain.Task) string {
+	var parts []string
+	if task.Status == domain.StatusDone {
+		parts = append(parts, "x")
+	}
+	switch task.Priority {
+	case domain.PriorityHigh:
+		parts = append(parts, "(A)")
+	case domain.PriorityMedium:
+		parts = append(parts, "(B)")
+	case domain.PriorityLow:
+		parts = append(parts, "(C)")
+	}
+	parts = append(parts, strings.Join(strings.Fields(task.Title), " "))
+	if task.Project != "" {
+		parts = append(parts, "+"+token(task.Project))
+	}
+	for _, tag := range task.Tags {
+		parts = append(parts, "@"+token(tag))
+	}
+	if date, err := time.Parse(taskForm, task.Date); err == nil {
+		parts = append(parts, "due:"+date.Format(dateForm))
+		if rec, ok := formatRec(task.Repeat, date); ok {
+			parts = append(parts, "rec:"+rec)
+		} else if rule, ok := ical.RRule(task.Repeat); ok {
+			parts = append(parts, "rrule:"+rule)
+		}
+	}
+	if task.Time != "" {
+		parts = append(parts, "at:"+task.Time)
+	}
+	if task.Timezone != "" {
+		parts = append(parts, "tz:"+task.Timezone)
+	}
+	return strings.Join(parts, " ")
+}
+
+func token(s string) string {
+	return strings.Join(strings.Fields(s), "_")
+}
+
+// formatRec записывает правило повторения через rec:, если это возможно без потерь
+func formatRec(repeat string, due time.Time) (string, bool) {
+	switch {
+	case repeat == "y":
+		return "1y", true
+	case strings.HasPrefix(repeat, "d "):
+		days, err := strconv.Atoi(strings.TrimPrefix(repeat, "d "))
+		if err != nil || days <= 0 {
+			return "", false
+		}
+		if days%7 == 0 {
+			return strconv.Itoa(days/7) + "w", true
+		}
+		return strconv.Itoa(days) + "d", true
+	case repeat == "w 1,2,3,4,5":
+		return "1b", true
+	case repeat == "w "+strconv.Itoa(isoWeekday(due.Weekday())):
+		return "1w", true
+	case repeat == "m "+strconv.Itoa(due.Day()):
+		return "1m", true
+	}
+	return "", false
+}
+
+// Parse читает задачи из todo.txt, пустые строки пропускаются.
+// Строки с ошибками попадают в отчёт, ошибка возвращается, если файл не читается целиком
+func Parse(r io.Reader) ([]*domain.ImportItem, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	var items []*domain.ImportItem
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		item := &domain.ImportItem{Index: len(items) + 1, Ref: "строка " + strconv.Itoa(n)}
+		items = append(items, item)
+		task, err := ParseLine(line)
+		if task != nil {
+			item.Title = task.Title
+		}
+		if err != nil {
+			item.Status = domain.ImportFailed
+			item.Error = err.Error()
+			continue
+		}
+		item.Task = task
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ParseLine разбирает строку todo.txt. Первые проект и метки переносятся в поля задачи,
+// неизвестные расширения key:value остаются в заголовке
+func ParseLine(line string) (*domain.Task, error) {
+	task := &domain.Task{}
+	fields := strings.Fields(line)
+	if len(fields) > 0 && fields[0] == "x" {
+		task.Status = domain.StatusDone
+		fields = fields[1:]
+		//Дата выполнения и дата создания
+		for i := 0; i < 2 && len(fields) > 0 && datePattern.MatchString(fields[0]); i++ {
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 0 {
+		if m := priorityPattern.FindStringSubmatch(fields[0]); m != nil {
+			task.Priority = fromPriority(m[1][0])
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 0 && datePattern.MatchString(fields[0]) {
+		fields = fields[1:]
+	}
+
+	var title []string
+	var rec, rrule string
+	var err error
+	for _, field := range fields {
+		switch {
+		case len(field) > 1 && field[0] == '+':
+			if task.Project == "" {
+				task.Project = field[1:]
+			}
+			continue
+		case len(field) > 1 && field[0] == '@':
+			task.Tags = append(task.Tags, field[1:])
+			continue
+		}
+		key, value, ok := strings.Cut(field, ":")
+		if !ok || value == "" {
+			title = append(title, field)
+			continue
+		}
+		switch key {
+		case "due":
+			date, perr := time.Parse(dateForm, value)
+			if perr != nil {
+				err = fmt.Errorf("неверная дата due:%s", value)
+				continue
+			}
+			task.Date = date.Format(taskForm)
+		case "at":
+			task.Time = value
+		case "tz":
+			task.Timezone = value
+		case "rec":
+			rec = value
+		case "rrule":
+			rrule = value
+		default:
+			title = append(title, field)
+		}
+	}
+	task.Title = strings.Join(title, " ")
+	if err != nil {
+		return task, err
+	}
+
+	var due time.Time
+	if task.Date != "" {
+		due, _ = time.Parse(taskForm, task.Date)
+	}
+	switch {
+	case rrule != "":
+		task.Repeat, err = ical.Repeat(rrule, due)
+	case rec != "":
+		task.Repeat, err = parseRec(rec, due)
+	}
+	return task, err
+}
+
+// parseRec переводит rec: в правило повторения через равнозначное RRULE.
+// «+» (отсчёт от срока, а не от выполнения) не влияет на результат:
+// повторения планировщика всегда отсчитываются от даты задачи
+func parseRec(rec string, due time.Time) (string, error) {
+	m := recPattern.FindStringSubmatch(rec)
+	if m == nil {
+		return "", fmt.Errorf("%w: %s", ErrRec, rec)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n <= 0 {
+		return "", fmt.Errorf("%w: %s", ErrRec, rec)
+	}
+	interval := ";INTERVAL=" + strconv.Itoa(n)
+	switch m[2] {
+	case "d":
+		return ical.Repeat("FREQ=DAILY"+interval, due)
+	case "w":
+		return ical.Repeat("FREQ=WEEKLY"+interval, due)
+	case "m":
+		return ical.Repeat("FREQ=MONTHLY"+interval, due)
+	case "y":
+		return ical.Repeat("FREQ=YEARLY"+interval, due)
+	case "b":
+		//Рабочие дни задаются только с шагом в один день
+		if n == 1 {
+			return ical.Repeat("FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", due)
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrRec, rec)
+}
+
+// fromPriority переводит приоритет todo.txt в приоритет задачи
+func fromPriority(p byte) int {
+	switch p {
+	case 'A':
+		return domain.PriorityHigh
+	case 'B':
+		return domain.PriorityMedium
+	}
+	return domain.PriorityLow
+}
+
+func isoWeekday(wd time.Weekday) int {
+	if wd == time.Sunday {
+		return 7
+	}
+	return int(wd)
+}
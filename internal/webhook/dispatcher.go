@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+const (
+	// MaxAttempts — после стольких неудачных попыток доставка получает состояние failed
+	MaxAttempts = 8
+	//Задержки между попытками: 10s, 20s, 40s ... не больше часа
+	baseBackoff = 10 * time.Second
+	maxBackoff  = time.Hour
+	//Число доставок за один проход очереди
+	batchSize = 50
+)
+
+// Заголовки запроса доставки
+const (
+	HeaderEvent     = "X-Todo-Event"
+	HeaderDelivery  = "X-Todo-Delivery"
+	HeaderSignature = "X-Todo-Signature"
+)
+
+// Backoff возвращает задержку перед следующей попыткой после attempts неудачных
+func Backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// Sign возвращает значение заголовка X-Todo-Signature: HMAC-SHA256 тела запроса
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher отправляет доставки из очереди в БД
+type Dispatcher struct {
+	repo     domain.WebhookRepository
+	client   *http.Client
+	interval time.Duration
+	wake     chan struct{}
+	//Разрешены ли получатели на локальном узле и во внутренних сетях
+	private bool
+}
+
+// NewDispatcher создаёт рассыльщик. Без allowPrivate соединения с локальными и внутренними адресами
+// запрещены на уровне dialer: проверяется адрес после разрешения имени, поэтому не проходят
+// и имена, указывающие на такие адреса, и перенаправления на них
+func NewDispatcher(repo domain.WebhookRepository, interval time.Duration, allowPrivate bool) *Dispatcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		//Через прокси с получателем соединялся бы прокси, в обход проверки адреса
+		transport.Proxy = nil
+		transport.DialContext = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   dialControl,
+		}).DialContext
+	}
+	return &Dispatcher{
+		repo:     repo,
+		client:   &http.Client{Timeout: 10 * time.Second, Transport: transport},
+		interval: interval,
+		wake:     make(chan struct{}, 1),
+		private:  allowPrivate,
+	}
+}
+
+// dialControl отклоняет соединение с локальным или внутренним адресом
+func dialControl(network, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if privateAddr(addr.Addr()) {
+		return fmt.Errorf("%w: %s", domain.ErrWebhookHost, address)
+	}
+	return nil
+}
+
+// sharedAddrs — общее адресное пространство операторов связи (RFC 6598), не маршрутизируется в интернете
+var sharedAddrs = netip.MustParsePrefix("100.64.0.0/10")
+
+// privateAddr сообщает, что адрес относится к локальному узлу, частной сети или не может быть адресом получателя
+func privateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || sharedAddrs.Contains(addr)
+}
+
+// privateHost сообщает, что в адресе webhook указан локальный узел или внутренний IP-адрес.
+// Имена, которые разрешаются во внутренние адреса, отклоняет dialControl при доставке
+func privateHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && privateAddr(addr)
+}
+
+// Wake запускает внеочередной проход очереди
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start обрабатывает очередь в фоне до отмены ctx
+func (d *Dispatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := d.DeliverPending(ctx, time.Now()); err != nil {
+				log.Printf("Ошибка доставки webhook: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-d.wake:
+			}
+		}
+	}()
+}
+
+// DeliverPending отправляет доставки, время попытки которых наступило к now,
+// и возвращает число успешных
+func (d *Dispatcher) DeliverPending(ctx context.Context, now time.Time) (int, error) {
+	deliveries, err := d.repo.PendingDeliveries(now, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			break
+		}
+		code, err := d.send(ctx, delivery)
+		delivery.Attempts++
+		delivery.ResponseCode = code
+		if err == nil {
+			delivery.Status = domain.DeliveryDelivered
+			delivery.LastError = ""
+			delivery.NextAttemptAt = ""
+			delivery.DeliveredAt = now.UTC().Format(time.RFC3339)
+			delivered++
+		} else {
+			delivery.LastError = err.Error()
+			if delivery.Attempts >= MaxAttempts {
+				delivery.Status = domain.DeliveryFailed
+				delivery.NextAttemptAt = ""
+			} else {
+				delivery.NextAttemptAt = now.Add(Backoff(delivery.Attempts)).UTC().Format(time.RFC3339)
+			}
+		}
+		if err = d.repo.UpdateDelivery(delivery); err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *domain.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todo_web-webhook")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("получатель ответил статусом %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,169 @@
+// Package webhook управляет подписками на события задач и доставляет их
+// подписанными JSON-запросами через очередь в БД с повторными попытками.
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+)
+
+// Число записей журнала доставки, возвращаемых по одной подписке
+const deliveriesLimit = 50
+
+var knownEvents = []string{domain.EventTaskCreated, domain.EventTaskUpdated, domain.EventTaskDone, domain.EventTaskDeleted}
+
+type Service struct {
+	repo       domain.WebhookRepository
+	dispatcher *Dispatcher
+}
+
+func NewService(repo domain.WebhookRepository, dispatcher *Dispatcher) *Service {
+	return &Service{repo: repo, dispatcher: dispatcher}
+}
+
+// Create создаёт подписку, если секрет не указан, он генерируется
+func (s *Service) Create(hook *domain.Webhook) (*domain.Webhook, *domain.CustomError) {
+	if cErr := s.validate(hook); cErr != nil {
+		return nil, cErr
+	}
+	if hook.Secret == "" {
+		secret := make([]byte, 20)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+		}
+		hook.Secret = hex.EncodeToString(secret)
+	}
+	hook.Active = true
+	if _, err := s.repo.CreateWebhook(hook); err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return hook, nil
+}
+
+func (s *Service) List(userID int64) ([]*domain.Webhook, *domain.CustomError) {
+	hooks, err := s.repo.FindWebhooks(userID)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+	return hooks, nil
+}
+
+func (s *Service) Get(userID, id int64) (*domain.Webhook, *domain.CustomError) {
+	hook, err := s.repo.FindWebhook(userID, id)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	if hook == nil {
+		return nil, domain.NewCustomError(0, domain.ErrID, nil)
+	}
+	hook.Secret = ""
+	return hook, nil
+}
+
+// Update изменяет адрес, события и активность подписки, секрет не меняется
+func (s *Service) Update(hook *domain.Webhook) *domain.CustomError {
+	if cErr := s.validate(hook); cErr != nil {
+		return cErr
+	}
+	err := s.repo.UpdateWebhook(hook)
+	if errors.Is(err, domain.ErrWebhookNotFound) {
+		return domain.NewCustomError(0, domain.ErrID, err)
+	}
+	if err != nil {
+		return domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return nil
+}
+
+func (s *Service) Delete(userID, id int64) *domain.CustomError {
+	err := s.repo.DeleteWebhook(userID, id)
+	if errors.Is(err, domain.ErrWebhookNotFound) {
+		return domain.NewCustomError(0, domain.ErrID, err)
+	}
+	if err != nil {
+		return domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return nil
+}
+
+// Deliveries возвращает журнал доставки подписки пользователя
+func (s *Service) Deliveries(userID, id int64) ([]*domain.WebhookDelivery, *domain.CustomError) {
+	if _, cErr := s.Get(userID, id); cErr != nil {
+		return nil, cErr
+	}
+	deliveries, err := s.repo.FindDeliveries(id, deliveriesLimit)
+	if err != nil {
+		return nil, domain.NewCustomError(0, domain.ErrInternalServer, err)
+	}
+	return deliveries, nil
+}
+
+// HandleEvent ставит событие в очередь доставки всем подходящим подпискам владельца задачи
+func (s *Service) HandleEvent(event domain.TaskEvent) {
+	hooks, err := s.repo.FindWebhooks(event.UserID)
+	if err != nil {
+		log.Printf("Ошибка поиска подписок webhook: %v", err)
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Ошибка сериализации события %s: %v", event.Type, err)
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var deliveries []*domain.WebhookDelivery
+	for _, hook := range hooks {
+		if !hook.Active || (len(hook.Events) > 0 && !slices.Contains(hook.Events, event.Type)) {
+			continue
+		}
+		deliveries = append(deliveries, &domain.WebhookDelivery{
+			WebhookID:     hook.ID,
+			Event:         event.Type,
+			Payload:       string(payload),
+			Status:        domain.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	if err = s.repo.AddDeliveries(deliveries); err != nil {
+		log.Printf("Ошибка постановки события %s в очередь: %v", event.Type, err)
+		return
+	}
+	if s.dispatcher != nil {
+		s.dispatcher.Wake()
+	}
+}
+
+func (s *Service) validate(hook *domain.Webhook) *domain.CustomError {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return domain.NewCustomError(0, domain.ErrWebhookURL, err)
+	}
+	if s.dispatcher != nil && !s.dispatcher.private && privateHost(u.Hostname()) {
+		return domain.NewCustomError(0, domain.ErrWebhookHost, nil)
+	}
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+	for _, event := range hook.Events {
+		if !slices.Contains(knownEvents, event) {
+			return domain.NewCustomError(0, domain.ErrWebhookEvent, nil)
+		}
+	}
+	return nil
+}
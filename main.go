@@ -3,12 +3,31 @@ package main
 import (
 	"github.com/agidelle/todo_web/cmd"
 
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
 )
 
 func main() {
+	//Команды обслуживания выполняются без запуска сервера
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := cmd.Migrate(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "todotxt":
+			if err := cmd.TodoTxt(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		default:
+			log.Fatalf("неизвестная команда: %s", os.Args[1])
+		}
+	}
+
 	app, db := cmd.Initialize()
 	server := app.Start()
 
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agidelle/todo_web/cmd"
+	"github.com/agidelle/todo_web/internal/config"
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// agendaEntry — задача дня повестки: id, и признаки повторения и просрочки
+type agendaEntry struct {
+	id      string
+	virtual bool
+	overdue bool
+}
+
+func agendaEntries(t *testing.T, tasks any) []agendaEntry {
+	list, ok := tasks.([]any)
+	require.True(t, ok, tasks)
+	res := make([]agendaEntry, len(list))
+	for i, item := range list {
+		task := item.(map[string]any)
+		res[i] = agendaEntry{id: fmt.Sprint(task["id"]), virtual: task["virtual"] == true, overdue: task["overdue"] == true}
+	}
+	return res
+}
+
+// agendaDays возвращает задачи повестки по датам
+func agendaDays(t *testing.T, m map[string]any) map[string][]agendaEntry {
+	res := make(map[string][]agendaEntry)
+	for _, item := range m["days"].([]any) {
+		day := item.(map[string]any)
+		res[fmt.Sprint(day["date"])] = agendaEntries(t, day["tasks"])
+	}
+	return res
+}
+
+func TestAgenda(t *testing.T) {
+	app, db := cmd.New(&config.Config{
+		DBdriver:     "sqlite",
+		DBPath:       filepath.Join(t.TempDir(), "agenda.db"),
+		Password:     "secret",
+		JWTKey:       "key",
+		Migrate:      true,
+		Registration: true,
+	})
+	defer db.Close()
+	srv := httptest.NewServer(app.Router())
+	defer srv.Close()
+
+	code, m := authRequest(t, srv, http.MethodPost, "/api/signup", "", map[string]string{"login": "alice", "password": "alice-pass"})
+	require.Equal(t, http.StatusCreated, code)
+	token := fmt.Sprint(m["token"])
+	code, _ = authRequest(t, srv, http.MethodPut, "/api/me", token, map[string]string{"timezone": "UTC"})
+	require.Equal(t, http.StatusOK, code)
+	code, me := authRequest(t, srv, http.MethodGet, "/api/me", token, nil)
+	require.Equal(t, http.StatusOK, code)
+	userID := int64(me["id"].(float64))
+
+	today := time.Now().UTC()
+	day := func(offset int) string {
+		return today.AddDate(0, 0, offset).Format("20060102")
+	}
+	add := func(values map[string]any) string {
+		code, m := authRequest(t, srv, http.MethodPost, "/api/task", token, values)
+		require.Equal(t, http.StatusCreated, code, m)
+		return fmt.Sprint(m["id"])
+	}
+	//Задачи с прошедшими датами через API не создать, их пишем в БД
+	insert := func(task *domain.Task) string {
+		task.UserID = userID
+		id, err := db.CreateTask(task)
+		require.NoError(t, err)
+		return fmt.Sprint(id)
+	}
+	everyOther := add(map[string]any{"date": day(1), "title": "Полив", "repeat": "d 2"})
+	series := add(map[string]any{"date": day(2), "title": "Курс", "repeat": "FREQ=DAILY;COUNT=3"})
+	meeting := add(map[string]any{"date": day(4), "title": "Встреча", "time": "09:00"})
+	errand := add(map[string]any{"date": day(4), "title": "Почта"})
+	add(map[string]any{"date": day(7), "title": "После недели"})
+	weekly := insert(&domain.Task{Date: day(-10), Title: "Уборка", Repeat: "d 7"})
+	overdue := insert(&domain.Task{Date: day(-3), Title: "Счёт"})
+
+	//По умолчанию — неделя от сегодняшнего дня, каждый день есть в ответе
+	code, m = authRequest(t, srv, http.MethodGet, "/api/agenda", token, nil)
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, day(0), m["from"])
+	assert.Equal(t, day(6), m["to"])
+	assert.Len(t, m["days"], 7)
+	assert.Equal(t, []agendaEntry{{id: overdue, overdue: true}}, agendaEntries(t, m["overdue"]))
+	assert.Equal(t, map[string][]agendaEntry{
+		day(0): {},
+		day(1): {{id: everyOther}},
+		day(2): {{id: series}},
+		day(3): {{id: everyOther, virtual: true}, {id: series, virtual: true}},
+		//Задачи со временем идут после задач без времени, повторение задачи из прошлого — с первой даты периода
+		day(4): {{id: series, virtual: true}, {id: errand}, {id: weekly, virtual: true}, {id: meeting}},
+		day(5): {{id: everyOther, virtual: true}},
+		day(6): {},
+	}, agendaDays(t, m))
+
+	//Просроченная задача внутри периода остаётся в своём дне
+	code, m = authRequest(t, srv, http.MethodGet, "/api/agenda?from="+day(-3)+"&to="+day(-1), token, nil)
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Empty(t, agendaEntries(t, m["overdue"]))
+	assert.Equal(t, map[string][]agendaEntry{
+		day(-3): {{id: weekly, virtual: true}, {id: overdue, overdue: true}},
+		day(-2): {},
+		day(-1): {},
+	}, agendaDays(t, m))
+
+	//Срок выполнения вычисляется для даты повторения
+	code, m = authRequest(t, srv, http.MethodGet, "/api/agenda?from="+day(3)+"&to="+day(3), token, nil)
+	require.Equal(t, http.StatusOK, code, m)
+	first := m["days"].([]any)[0].(map[string]any)["tasks"].([]any)[0].(map[string]any)
+	assert.Equal(t, day(3), first["date"])
+	assert.Contains(t, first["due"], today.AddDate(0, 0, 3).Format("2006-01-02"))
+
+	for _, query := range []string{
+		"from=" + day(5) + "&to=" + day(4),
+		"from=" + day(0) + "&to=" + day(92),
+		"from=2030-01-01",
+		"to=завтра",
+	} {
+		code, m = authRequest(t, srv, http.MethodGet, "/api/agenda?"+query, token, nil)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+		assert.NotEmpty(t, m["error"], query)
+	}
+	code, m = authRequest(t, srv, http.MethodGet, "/api/agenda?from="+day(0)+"&to="+day(91), token, nil)
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Len(t, m["days"], 92)
+}
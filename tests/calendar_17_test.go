@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/agidelle/todo_web/internal/ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getCalendar запрашивает календарь и возвращает статус и строки содержимого без переносов
+func getCalendar(t *testing.T, query string) (int, []string) {
+	resp, err := http.Get(getURL("api/calendar.ics?" + query))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/calendar"))
+
+	raw := strings.Split(strings.TrimSuffix(string(body), "\r\n"), "\r\n")
+	var lines []string
+	for _, line := range raw {
+		assert.LessOrEqual(t, len(line), 75, "Строка длиннее 75 октетов: %q", line)
+		if strings.HasPrefix(line, " ") {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return resp.StatusCode, lines
+}
+
+// component возвращает строки компонента задачи с указанным UID
+func component(lines []string, id string) []string {
+	for i, line := range lines {
+		if line != "UID:task-"+id+"@todo_web" {
+			continue
+		}
+		start := i
+		for start > 0 && !strings.HasPrefix(lines[start], "BEGIN:V") {
+			start--
+		}
+		end := i
+		for end < len(lines) && !strings.HasPrefix(lines[end], "END:V") {
+			end++
+		}
+		return lines[start : end+1]
+	}
+	return nil
+}
+
+// timezone возвращает строки описания пояса VTIMEZONE с указанным TZID
+func timezone(lines []string, tzid string) []string {
+	for i, line := range lines {
+		if line != "TZID:"+tzid || i == 0 || lines[i-1] != "BEGIN:VTIMEZONE" {
+			continue
+		}
+		end := i
+		for end < len(lines) && lines[end] != "END:VTIMEZONE" {
+			end++
+		}
+		return lines[i-1 : end+1]
+	}
+	return nil
+}
+
+func TestCalendar(t *testing.T) {
+	code, _ := getCalendar(t, "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = getCalendar(t, "token=unknown")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	m, err := postJSON("api/calendar/token", nil, http.MethodPost)
+	require.NoError(t, err)
+	token := fmt.Sprint(m["token"])
+	require.NotEmpty(t, token)
+	assert.True(t, strings.HasSuffix(fmt.Sprint(m["url"]), "/api/calendar.ics?token="+token))
+
+	m, err = postJSON("api/task", map[string]any{
+		"date":     "20300114",
+		"title":    "Планёрка; отдел, продажи",
+		"comment":  "Повестка:\n" + strings.Repeat("обсудить квартальные итоги ", 5),
+		"repeat":   "w 1,3",
+		"priority": 3,
+		"tags":     []string{"работа"},
+	}, http.MethodPost)
+	require.NoError(t, err)
+	weekly := fmt.Sprint(m["id"])
+	m, err = postJSON("api/task", map[string]any{
+		"date":     "20300115",
+		"title":    "Созвон",
+		"time":     "14:30",
+		"timezone": "Europe/Moscow",
+		"repeat":   "m 1,-1",
+	}, http.MethodPost)
+	require.NoError(t, err)
+	timed := fmt.Sprint(m["id"])
+	m, err = postJSON("api/task", map[string]any{
+		"date":     "20300601",
+		"title":    "Встреча в Берлине",
+		"time":     "10:00",
+		"timezone": "Europe/Berlin",
+	}, http.MethodPost)
+	require.NoError(t, err)
+	berlin := fmt.Sprint(m["id"])
+
+	code, lines := getCalendar(t, "token="+url.QueryEscape(token))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "BEGIN:VCALENDAR", lines[0])
+	assert.Equal(t, "END:VCALENDAR", lines[len(lines)-1])
+
+	event := component(lines, weekly)
+	require.NotNil(t, event)
+	assert.Equal(t, "BEGIN:VEVENT", event[0])
+	assert.Contains(t, event, "DTSTART;VALUE=DATE:20300114")
+	assert.Contains(t, event, "RRULE:FREQ=WEEKLY;BYDAY=MO,WE")
+	assert.Contains(t, event, `SUMMARY:Планёрка\; отдел\, продажи`)
+	assert.Contains(t, event, `DESCRIPTION:Повестка:\n`+strings.Repeat("обсудить квартальные итоги ", 5))
+	assert.Contains(t, event, "CATEGORIES:работа")
+	assert.Contains(t, event, "PRIORITY:1")
+
+	event = component(lines, timed)
+	require.NotNil(t, event)
+	assert.Contains(t, event, "DTSTART;TZID=Europe/Moscow:20300115T143000")
+	assert.Contains(t, event, "RRULE:FREQ=MONTHLY;BYMONTHDAY=1,-1")
+	assert.Contains(t, component(lines, berlin), "DTSTART;TZID=Europe/Berlin:20300601T100000")
+
+	//Каждый TZID описан в VTIMEZONE до событий, с переходами на летнее время
+	moscow := timezone(lines, "Europe/Moscow")
+	require.NotNil(t, moscow)
+	assert.Less(t, slices.Index(lines, "BEGIN:VTIMEZONE"), slices.Index(lines, "BEGIN:VEVENT"))
+	assert.Equal(t, []string{"BEGIN:STANDARD", "DTSTART:20141026T020000", "TZOFFSETFROM:+0400", "TZOFFSETTO:+0300", "TZNAME:MSK", "END:STANDARD"},
+		moscow[len(moscow)-7:len(moscow)-1])
+	cet := timezone(lines, "Europe/Berlin")
+	require.NotNil(t, cet)
+	assert.Contains(t, strings.Join(cet, "\n"), "BEGIN:DAYLIGHT\nDTSTART:20300331T020000\nTZOFFSETFROM:+0100\nTZOFFSETTO:+0200\nTZNAME:CEST\nEND:DAYLIGHT")
+	assert.Contains(t, strings.Join(cet, "\n"), "BEGIN:STANDARD\nDTSTART:20301027T030000\nTZOFFSETFROM:+0200\nTZOFFSETTO:+0100\nTZNAME:CET\nEND:STANDARD")
+
+	code, lines = getCalendar(t, "type=todo&token="+token)
+	require.Equal(t, http.StatusOK, code)
+	todo := component(lines, timed)
+	require.NotNil(t, todo)
+	assert.Equal(t, "BEGIN:VTODO", todo[0])
+	assert.Contains(t, todo, "DUE;TZID=Europe/Moscow:20300115T143000")
+	code, _ = getCalendar(t, "type=journal&token="+token)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	//Выполненная обычная задача пропадает из календаря
+	m, err = postJSON("api/task", map[string]any{"date": "20300116", "title": "Разовая"}, http.MethodPost)
+	require.NoError(t, err)
+	once := fmt.Sprint(m["id"])
+	_, err = postJSON("api/task/done?id="+once, nil, http.MethodPost)
+	require.NoError(t, err)
+	_, lines = getCalendar(t, "token="+token)
+	assert.Nil(t, component(lines, once))
+
+	//Новый токен заменяет прежний, отозванный токен не действует
+	m, err = postJSON("api/calendar/token", nil, http.MethodPost)
+	require.NoError(t, err)
+	rotated := fmt.Sprint(m["token"])
+	code, _ = getCalendar(t, "token="+token)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = getCalendar(t, "token="+rotated)
+	assert.Equal(t, http.StatusOK, code)
+	_, err = postJSON("api/calendar/token", nil, http.MethodDelete)
+	require.NoError(t, err)
+	code, _ = getCalendar(t, "token="+rotated)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	for _, id := range []string{weekly, timed} {
+		_, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
+
+func TestRepeatToRRule(t *testing.T) {
+	for repeat, want := range map[string]string{
+		"y":                            "FREQ=YEARLY",
+		"d 1":                          "FREQ=DAILY",
+		"d 14":                         "FREQ=DAILY;INTERVAL=14",
+		"w 7":                          "FREQ=WEEKLY;BYDAY=SU",
+		"m -2 2,8":                     "FREQ=MONTHLY;BYMONTHDAY=-2;BYMONTH=2,8",
+		"rrule:FREQ=MONTHLY;BYDAY=2TU": "FREQ=MONTHLY;BYDAY=2TU",
+	} {
+		rule, ok := ical.RRule(repeat)
+		assert.True(t, ok, repeat)
+		assert.Equal(t, want, rule, repeat)
+	}
+	for _, repeat := range []string{"", "d 500", "w 8", "m 0", "m 1 13", "x"} {
+		_, ok := ical.RRule(repeat)
+		assert.False(t, ok, "Правило %q не переводится", repeat)
+	}
+}
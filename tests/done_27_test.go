@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doneParallel выполняет задачу n раз одновременно и возвращает число успешных ответов
+func doneParallel(t *testing.T, id string, n int) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+			if !assert.NoError(t, err) {
+				return
+			}
+			if _, failed := ret["error"]; !failed {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return succeeded
+}
+
+func TestDoneConcurrent(t *testing.T) {
+	const n = 16
+
+	//Каждое выполнение повторяющейся задачи переносит её ровно на один шаг
+	id := addTask(t, task{date: "20300101", title: "Параллельное выполнение", repeat: "d 1"})
+	assert.Equal(t, n, doneParallel(t, id, n))
+	ret, err := postJSON("api/task?id="+id, nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.Equal(t, "20300117", ret["date"])
+	completions := getCompletions(t, "api/task/history?id="+id)
+	require.Len(t, completions, n)
+	dates := make(map[string]bool)
+	for _, c := range completions {
+		dates[c.Date] = true
+	}
+	assert.Len(t, dates, n, "Каждое выполнение приходится на свою дату")
+
+	//Разовая задача выполняется один раз
+	once := addTask(t, task{date: "20300101", title: "Параллельное разовое выполнение"})
+	assert.Equal(t, 1, doneParallel(t, once, n))
+	assert.Len(t, getCompletions(t, "api/task/history?id="+once), 1)
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etagRequest выполняет запрос с заголовком If-Match и возвращает статус, ETag и ответ
+func etagRequest(t *testing.T, method, apipath, ifMatch string, values map[string]any) (int, string, map[string]any) {
+	var data []byte
+	if values != nil {
+		var err error
+		data, err = json.Marshal(values)
+		require.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewReader(data))
+	require.NoError(t, err)
+	if values != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var m map[string]any
+	if resp.StatusCode != http.StatusNoContent {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+	}
+	return resp.StatusCode, resp.Header.Get("ETag"), m
+}
+
+func TestETag(t *testing.T) {
+	id := addTask(t, task{date: "20300101", title: "Задача с версией", repeat: "d 1"})
+	code, etag, _ := etagRequest(t, http.MethodGet, "api/task?id="+id, "", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `"1"`, etag)
+
+	edit := map[string]any{"id": id, "date": "20300101", "title": "Первое изменение", "repeat": "d 1"}
+	code, etag, _ = etagRequest(t, http.MethodPut, "api/task", `"1"`, edit)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `"2"`, etag)
+
+	//Второй клиент изменяет задачу по устаревшей версии
+	edit["title"] = "Устаревшее изменение"
+	code, _, m := etagRequest(t, http.MethodPut, "api/task", `"1"`, edit)
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	assert.NotEmpty(t, m["error"])
+	_, _, m = etagRequest(t, http.MethodGet, "api/task?id="+id, "", nil)
+	assert.Equal(t, "Первое изменение", m["title"])
+
+	//Без If-Match изменение выполняется как раньше
+	edit["title"] = "Второе изменение"
+	code, etag, _ = etagRequest(t, http.MethodPut, "api/task", "", edit)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `"3"`, etag)
+	code, etag, _ = etagRequest(t, http.MethodPatch, "api/task?id="+id, `"3"`, map[string]any{"comment": "С версией"})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `"4"`, etag)
+
+	for _, ifMatch := range []string{`"3"`, `W/"4"`, "4", `"abc"`} {
+		code, _, _ = etagRequest(t, http.MethodPost, "api/task/done?id="+id, ifMatch, nil)
+		assert.Equal(t, http.StatusPreconditionFailed, code, ifMatch)
+		code, _, _ = etagRequest(t, http.MethodDelete, "api/task?id="+id, ifMatch, nil)
+		assert.Equal(t, http.StatusPreconditionFailed, code, ifMatch)
+	}
+
+	code, etag, m = etagRequest(t, http.MethodPost, "api/v2/tasks/"+id+"/done", `"4"`, nil)
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, `"5"`, etag)
+	assert.Equal(t, "20300102", m["date"])
+	code, _, m = etagRequest(t, http.MethodPut, "api/v2/tasks/"+id, `"4"`, map[string]any{"title": "Поздно"})
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	assert.Equal(t, float64(http.StatusPreconditionFailed), m["status"])
+
+	code, _, _ = etagRequest(t, http.MethodDelete, "api/v2/tasks/"+id, `"*"`, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, code, "Звёздочка в кавычках — обычный ETag")
+	code, _, _ = etagRequest(t, http.MethodDelete, "api/v2/tasks/"+id, "*", nil)
+	assert.Equal(t, http.StatusNoContent, code)
+
+	//Выполнение разовой задачи тоже меняет версию
+	once := addTask(t, task{date: "20300101", title: "Разовая с версией"})
+	code, etag, m = etagRequest(t, http.MethodPost, "api/v2/tasks/"+once+"/done", `"1"`, nil)
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, "done", m["status"])
+	assert.Equal(t, `"2"`, etag)
+	code, _, _ = etagRequest(t, http.MethodDelete, "api/v2/tasks/"+once, `"1"`, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	code, _, _ = etagRequest(t, http.MethodDelete, "api/v2/tasks/"+once, `"2"`, nil)
+	assert.Equal(t, http.StatusNoContent, code)
+}
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  struct {
+		Event  string `json:"event"`
+		TaskID string `json:"task_id"`
+		Task   *struct {
+			Title string `json:"title"`
+		} `json:"task"`
+	}
+}
+
+// openEvents подключается к /api/events и возвращает канал разобранных событий
+func openEvents(t *testing.T, lastEventID string) <-chan sseEvent {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, getURL("api/events"), nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/event-stream")
+
+	ch := make(chan sseEvent, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(ch)
+		scanner := bufio.NewScanner(resp.Body)
+		var e sseEvent
+		var data string
+		for scanner.Scan() {
+			line := scanner.Text()
+			key, value, _ := strings.Cut(line, ": ")
+			switch key {
+			case "id":
+				e.ID = value
+			case "event":
+				e.Event = value
+			case "data":
+				data = value
+			case "":
+				if line == "" && e.Event != "" {
+					_ = json.Unmarshal([]byte(data), &e.Data)
+					ch <- e
+				}
+				e, data = sseEvent{}, ""
+			}
+		}
+	}()
+	return ch
+}
+
+// nextEvent ждёт событие задачи id, остальные пропускаются
+func nextEvent(t *testing.T, ch <-chan sseEvent, id string) sseEvent {
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case e, ok := <-ch:
+			require.True(t, ok, "Поток событий закрыт")
+			if id == "" || e.Data.TaskID == id {
+				return e
+			}
+		case <-timeout:
+			require.FailNow(t, "Нет события задачи "+id)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	stream := openEvents(t, "")
+
+	m, err := postJSON("api/task", map[string]any{"date": "20300101", "title": "Событие SSE"}, http.MethodPost)
+	require.NoError(t, err)
+	id := fmt.Sprint(m["id"])
+	created := nextEvent(t, stream, id)
+	assert.Equal(t, "task.created", created.Event)
+	assert.Equal(t, "task.created", created.Data.Event)
+	require.NotNil(t, created.Data.Task)
+	assert.Equal(t, "Событие SSE", created.Data.Task.Title)
+	assert.NotEmpty(t, created.ID)
+
+	//Поток не обрывается по WriteTimeout сервера
+	time.Sleep(5500 * time.Millisecond)
+	_, err = postJSON("api/task", map[string]any{"id": id, "date": "20300102", "title": "Событие SSE 2"}, http.MethodPut)
+	require.NoError(t, err)
+	assert.Equal(t, "task.updated", nextEvent(t, stream, id).Event)
+
+	//После переподключения приходят события, пропущенные с Last-Event-ID
+	_, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	require.NoError(t, err)
+	_, err = requestJSON("api/task?id="+id, nil, http.MethodDelete)
+	require.NoError(t, err)
+	resumed := openEvents(t, created.ID)
+	assert.Equal(t, "task.updated", nextEvent(t, resumed, id).Event)
+	assert.Equal(t, "task.done", nextEvent(t, resumed, id).Event)
+	deleted := nextEvent(t, resumed, id)
+	assert.Equal(t, "task.deleted", deleted.Event)
+	assert.Nil(t, deleted.Data.Task)
+
+	//Номер, которого нет в буфере, требует перечитать задачи
+	reset := nextEvent(t, openEvents(t, "999999999"), "")
+	assert.Equal(t, "reset", reset.Event)
+}
@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agidelle/todo_web/cmd"
+	"github.com/agidelle/todo_web/internal/config"
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/service"
+	"github.com/agidelle/todo_web/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// filterTaskIDs возвращает id задач ответа /api/filters/{id}/tasks по порядку
+func filterTaskIDs(t *testing.T, m map[string]any) []string {
+	tasks, ok := m["tasks"].([]any)
+	require.True(t, ok, m)
+	res := make([]string, len(tasks))
+	for i, task := range tasks {
+		res[i] = fmt.Sprint(task.(map[string]any)["id"])
+	}
+	return res
+}
+
+func TestSavedFilters(t *testing.T) {
+	app, db := cmd.New(&config.Config{
+		DBdriver:     "sqlite",
+		DBPath:       filepath.Join(t.TempDir(), "filters.db"),
+		Password:     "secret",
+		JWTKey:       "key",
+		Migrate:      true,
+		Registration: true,
+	})
+	defer db.Close()
+	srv := httptest.NewServer(app.Router())
+	defer srv.Close()
+
+	tokens := make(map[string]string)
+	for _, login := range []string{"alice", "bob"} {
+		code, m := authRequest(t, srv, http.MethodPost, "/api/signup", "", map[string]string{"login": login, "password": login + "-pass"})
+		require.Equal(t, http.StatusCreated, code)
+		tokens[login] = fmt.Sprint(m["token"])
+	}
+	token := tokens["alice"]
+	code, _ := authRequest(t, srv, http.MethodPut, "/api/me", token, map[string]string{"timezone": "UTC"})
+	require.Equal(t, http.StatusOK, code)
+	code, me := authRequest(t, srv, http.MethodGet, "/api/me", token, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	today := time.Now().UTC()
+	day := func(offset int) string {
+		return today.AddDate(0, 0, offset).Format("20060102")
+	}
+	add := func(values map[string]any) string {
+		code, m := authRequest(t, srv, http.MethodPost, "/api/task", token, values)
+		require.Equal(t, http.StatusCreated, code, m)
+		return fmt.Sprint(m["id"])
+	}
+	now := add(map[string]any{"date": day(0), "title": "Созвон", "tags": []string{"работа"}})
+	soon := add(map[string]any{"date": day(3), "title": "Отчёт", "tags": []string{"работа"}})
+	later := add(map[string]any{"date": day(10), "title": "Отпуск"})
+	weekly := add(map[string]any{"date": day(20), "title": "Планёрка", "repeat": "d 7", "tags": []string{"работа"}})
+	//Прошедшая дата без повтора через API сдвигается на сегодня, поэтому просроченную задачу пишем в БД
+	overdue, err := db.CreateTask(&domain.Task{Date: day(-1), Title: "Просрочено", UserID: int64(me["id"].(float64))})
+	require.NoError(t, err)
+
+	//Встроенные списки идут первыми и строятся от текущей даты пользователя
+	code, m := authRequest(t, srv, http.MethodGet, "/api/filters", token, nil)
+	require.Equal(t, http.StatusOK, code)
+	list := m["filters"].([]any)
+	require.Len(t, list, 4)
+	var builtIn []string
+	for _, f := range list {
+		assert.Equal(t, true, f.(map[string]any)["builtin"])
+		builtIn = append(builtIn, fmt.Sprint(f.(map[string]any)["id"]))
+	}
+	assert.Equal(t, []string{"today", "overdue", "next7", "recurring"}, builtIn)
+	assert.Equal(t, "date:"+day(0), list[0].(map[string]any)["query"])
+
+	for id, want := range map[string][]string{
+		"today":     {now},
+		"overdue":   {fmt.Sprint(overdue)},
+		"next7":     {now, soon},
+		"recurring": {weekly},
+	} {
+		code, m = authRequest(t, srv, http.MethodGet, "/api/filters/"+id+"/tasks", token, nil)
+		require.Equal(t, http.StatusOK, code, id)
+		assert.Equal(t, want, filterTaskIDs(t, m), id)
+	}
+
+	//Сохранённый фильтр хранит запрос и порядок сортировки
+	code, m = authRequest(t, srv, http.MethodPost, "/api/filters", token,
+		map[string]string{"name": "Работа", "query": "tag:работа -is:recurring", "sort": "title", "order": "desc"})
+	require.Equal(t, http.StatusCreated, code, m)
+	id := fmt.Sprint(m["id"])
+	assert.Equal(t, "Работа", m["name"])
+	assert.Nil(t, m["builtin"])
+
+	code, m = authRequest(t, srv, http.MethodGet, "/api/filters/"+id+"/tasks", token, nil)
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, []string{now, soon}, filterTaskIDs(t, m))
+	//Параметры запроса меняют сохранённый порядок, страницы работают как в /api/tasks
+	code, m = authRequest(t, srv, http.MethodGet, "/api/filters/"+id+"/tasks?sort=date&limit=1", token, nil)
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, []string{now}, filterTaskIDs(t, m))
+	assert.Equal(t, float64(2), m["total"])
+	assert.NotEmpty(t, m["next_cursor"])
+
+	code, m = authRequest(t, srv, http.MethodGet, "/api/filters", token, nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, m["filters"], 5)
+
+	//Ошибки в фильтре
+	code, m = authRequest(t, srv, http.MethodPost, "/api/filters", token, map[string]string{"name": "Работа", "query": "отпуск"})
+	assert.Equal(t, http.StatusConflict, code, m)
+	code, m = authRequest(t, srv, http.MethodPost, "/api/filters", token, map[string]string{"name": "Ошибка", "query": "title:"})
+	assert.Equal(t, http.StatusBadRequest, code, m)
+	assert.Equal(t, float64(7), m["position"])
+	code, m = authRequest(t, srv, http.MethodPost, "/api/filters", token, map[string]string{"name": "Ошибка", "query": "отпуск", "sort": "size"})
+	assert.Equal(t, http.StatusBadRequest, code, m)
+	code, m = authRequest(t, srv, http.MethodPost, "/api/filters", token, map[string]string{"name": " ", "query": "отпуск"})
+	assert.Equal(t, http.StatusBadRequest, code, m)
+
+	//Изменение фильтра
+	code, m = authRequest(t, srv, http.MethodPut, "/api/filters/"+id, token, map[string]string{"name": "Работа", "query": "отпуск"})
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, "отпуск", m["query"])
+	code, m = authRequest(t, srv, http.MethodGet, "/api/filters/"+id+"/tasks", token, nil)
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, []string{later}, filterTaskIDs(t, m))
+
+	//Встроенные списки не меняются и не удаляются
+	code, _ = authRequest(t, srv, http.MethodPut, "/api/filters/today", token, map[string]string{"name": "Сегодня", "query": "отпуск"})
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = authRequest(t, srv, http.MethodDelete, "/api/filters/today", token, nil)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	//Фильтры другого пользователя не видны
+	code, m = authRequest(t, srv, http.MethodGet, "/api/filters", tokens["bob"], nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, m["filters"], 4)
+	for _, path := range []string{"/api/filters/" + id, "/api/filters/" + id + "/tasks"} {
+		code, _ = authRequest(t, srv, http.MethodGet, path, tokens["bob"], nil)
+		assert.Equal(t, http.StatusNotFound, code, path)
+	}
+	code, _ = authRequest(t, srv, http.MethodDelete, "/api/filters/"+id, tokens["bob"], nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, m = authRequest(t, srv, http.MethodGet, "/api/filters/today/tasks", tokens["bob"], nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, filterTaskIDs(t, m))
+
+	code, m = authRequest(t, srv, http.MethodDelete, "/api/filters/"+id, token, nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, m)
+	code, _ = authRequest(t, srv, http.MethodGet, "/api/filters/"+id, token, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = authRequest(t, srv, http.MethodGet, "/api/filters/unknown", token, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	//Ошибки задач фильтра тоже отдаются как JSON
+	for path, want := range map[string]int{
+		"/api/filters/unknown/tasks":       http.StatusNotFound,
+		"/api/filters/today/tasks?limit=x": http.StatusBadRequest,
+	} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := srv.Client().Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, want, resp.StatusCode, path)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"), path)
+	}
+}
+
+func TestSavedFiltersConcurrentCreate(t *testing.T) {
+	db, err := storage.Open("sqlite", filepath.Join(t.TempDir(), "filters.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+	defer db.Close()
+	filters := service.NewFilterService(db, service.NewService(db, db))
+
+	//Одновременные запросы проходят проверку названия раньше, чем любой из них сохранит фильтр,
+	//занятое название должно определяться по ограничению в БД
+	const n = 8
+	errs := make(chan *domain.CustomError, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, cErr := filters.Create(&domain.SavedFilter{Name: "Работа", Query: "tag:работа"})
+			errs <- cErr
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	created := 0
+	for cErr := range errs {
+		if cErr == nil {
+			created++
+			continue
+		}
+		assert.ErrorIs(t, cErr.Err, domain.ErrFilterExists)
+	}
+	assert.Equal(t, 1, created)
+
+	//То же при переименовании в занятое название в обход проверки сервиса
+	other := &domain.SavedFilter{Name: "Дом", Query: "tag:дом"}
+	_, err = db.CreateFilter(other)
+	require.NoError(t, err)
+	_, err = db.CreateFilter(&domain.SavedFilter{Name: "Дом", Query: "дом"})
+	assert.ErrorIs(t, err, domain.ErrFilterExists)
+	other.Name = "Работа"
+	assert.ErrorIs(t, db.UpdateFilter(other), domain.ErrFilterExists)
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/api"
+	"github.com/agidelle/todo_web/internal/events"
+	"github.com/agidelle/todo_web/internal/grpcapi"
+	"github.com/agidelle/todo_web/internal/grpcapi/pb"
+	"github.com/agidelle/todo_web/internal/service"
+	"github.com/agidelle/todo_web/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPC(t *testing.T) {
+	db, err := storage.Open("sqlite", filepath.Join(t.TempDir(), "grpc.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+	defer db.Close()
+	svc := service.NewService(db, db)
+	broker := events.NewBroker(16)
+	svc.Subscribe(broker.Publish)
+	users := service.NewUserService(db)
+	alice, cErr := users.Register("alice", "alice-pass", false)
+	require.Nil(t, cErr)
+	bob, cErr := users.Register("bob", "bob-pass", false)
+	require.Nil(t, cErr)
+
+	srv := grpcapi.NewServer(svc, broker, "key", true)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewTaskSchedulerClient(conn)
+
+	authCtx := func(userID int64) context.Context {
+		token, err := api.GenerateJWT("key", userID)
+		require.NoError(t, err)
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	}
+	ctx, other := authCtx(alice.ID), authCtx(bob.ID)
+
+	_, err = client.ListTasks(context.Background(), &pb.ListTasksRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "Вызов без токена должен отклоняться")
+
+	watchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	watch, err := client.Watch(watchCtx, &pb.WatchRequest{})
+	require.NoError(t, err)
+	_, err = watch.Header()
+	require.NoError(t, err)
+
+	created, err := client.CreateTask(ctx, &pb.CreateTaskRequest{Task: &pb.Task{
+		Date: "20300101", Title: "Задача gRPC", Repeat: "d 2", Priority: 3, Project: "Работа", Tags: []string{"grpc"},
+	}})
+	require.NoError(t, err)
+	assert.NotZero(t, created.GetId())
+
+	_, err = client.CreateTask(ctx, &pb.CreateTaskRequest{Task: &pb.Task{Date: "20300101"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	task, err := client.GetTask(ctx, &pb.GetTaskRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, created.GetId(), task.GetId())
+	assert.Equal(t, "Задача gRPC", task.GetTitle())
+	assert.Equal(t, int32(3), task.GetPriority())
+	assert.Equal(t, []string{"grpc"}, task.GetTags())
+	assert.NotEmpty(t, task.GetDue())
+
+	_, err = client.GetTask(other, &pb.GetTaskRequest{Id: created.GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err), "Чужая задача недоступна")
+
+	task.Title = "Изменённая задача gRPC"
+	_, err = client.UpdateTask(ctx, &pb.UpdateTaskRequest{Task: task})
+	require.NoError(t, err)
+	list, err := client.ListTasks(ctx, &pb.ListTasksRequest{Search: "Изменённая"})
+	require.NoError(t, err)
+	require.Len(t, list.GetTasks(), 1)
+	list, err = client.ListTasks(other, &pb.ListTasksRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, list.GetTasks())
+
+	//Страницы списка: по умолчанию 25 задач, курсор продолжает тот же порядок
+	for day := 1; day <= 30; day++ {
+		_, err = client.CreateTask(other, &pb.CreateTaskRequest{Task: &pb.Task{Date: fmt.Sprintf("203001%02d", day), Title: "Задача"}})
+		require.NoError(t, err)
+	}
+	list, err = client.ListTasks(other, &pb.ListTasksRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.GetTasks(), 25)
+	assert.Equal(t, int32(30), list.GetTotal())
+	assert.NotEmpty(t, list.GetNextCursor())
+	var dates []string
+	req := &pb.ListTasksRequest{Limit: 12, Sort: "date", Desc: true}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+		list, err = client.ListTasks(other, req)
+		require.NoError(t, err)
+		assert.Equal(t, int32(30), list.GetTotal())
+		for _, task := range list.GetTasks() {
+			dates = append(dates, task.GetDate())
+		}
+		if list.GetNextCursor() == "" {
+			break
+		}
+		req.Cursor = list.GetNextCursor()
+	}
+	require.Len(t, dates, 30)
+	assert.Equal(t, "20300130", dates[0])
+	assert.Equal(t, "20300101", dates[29])
+	list, err = client.ListTasks(other, &pb.ListTasksRequest{From: "20300105", To: "20300109"})
+	require.NoError(t, err)
+	assert.Len(t, list.GetTasks(), 5)
+	assert.Empty(t, list.GetNextCursor())
+	_, err = client.ListTasks(other, &pb.ListTasksRequest{Limit: 101})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.ListTasks(other, &pb.ListTasksRequest{Sort: "title", Cursor: req.Cursor})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "Курсор выдан для другого порядка")
+
+	_, err = client.Done(ctx, &pb.DoneRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	task, err = client.GetTask(ctx, &pb.GetTaskRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, "20300103", task.GetDate())
+	_, err = client.DeleteTask(ctx, &pb.DeleteTaskRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	_, err = client.DeleteTask(ctx, &pb.DeleteTaskRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	var got []string
+	var lastID uint64
+	for len(got) < 4 {
+		e, err := watch.Recv()
+		require.NoError(t, err, "%v", got)
+		assert.Equal(t, created.GetId(), e.GetTaskId())
+		got = append(got, e.GetEvent())
+		lastID = e.GetId()
+	}
+	assert.Equal(t, []string{"task.created", "task.updated", "task.done", "task.deleted"}, got)
+	cancel()
+
+	//Возобновление с номера события
+	resumeCtx, cancelResume := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelResume()
+	resumed, err := client.Watch(resumeCtx, &pb.WatchRequest{LastEventId: lastID - 1})
+	require.NoError(t, err)
+	e, err := resumed.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "task.deleted", e.GetEvent())
+
+	//Остановка рассыльщика завершает поток
+	broker.Close()
+	_, err = resumed.Recv()
+	require.Error(t, err)
+	assert.NotEqual(t, io.EOF, err)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	next, err := client.NextDate(ctx, &pb.NextDateRequest{Now: "20240126", Date: "20240125", Repeat: "d 7"})
+	require.NoError(t, err)
+	assert.Equal(t, "20240201", next.GetDate())
+	_, err = client.NextDate(ctx, &pb.NextDateRequest{Now: "20240126", Date: "20240125", Repeat: "x"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agidelle/todo_web/cmd"
+	"github.com/agidelle/todo_web/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type completion struct {
+	ID          string `json:"id"`
+	TaskID      string `json:"task_id"`
+	Title       string `json:"title"`
+	Date        string `json:"date"`
+	NextDate    string `json:"next_date"`
+	CompletedAt string `json:"completed_at"`
+}
+
+func getCompletions(t *testing.T, apipath string) []completion {
+	body, err := requestJSON(apipath, nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m struct {
+		Completions []completion `json:"completions"`
+	}
+	err = json.Unmarshal(body, &m)
+	assert.NoError(t, err)
+	return m.Completions
+}
+
+func TestHistory(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+
+	id := addTask(t, task{
+		date:  today,
+		title: "Сдать отчёт",
+	})
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	//Выполненная задача остаётся в БД
+	var stored Task
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Сдать отчёт", stored.Title)
+
+	history := getCompletions(t, "api/task/history?id="+id)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, id, history[0].TaskID)
+		assert.Equal(t, today, history[0].Date)
+		assert.Empty(t, history[0].NextDate)
+		assert.NotEmpty(t, history[0].CompletedAt)
+	}
+
+	//Выполненную задачу нельзя ни изменить, ни выполнить повторно
+	m, err := postJSON("api/task", map[string]any{
+		"id":    id,
+		"date":  today,
+		"title": "Сдать отчёт ещё раз",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+	m, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+
+	rid := addTask(t, task{
+		date:   today,
+		title:  "Полить цветы",
+		repeat: "d 3",
+	})
+	for i := 0; i < 2; i++ {
+		ret, err := postJSON("api/task/done?id="+rid, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+	history = getCompletions(t, "api/task/history?id="+rid)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, today, history[0].Date)
+		assert.Equal(t, now.AddDate(0, 0, 3).Format(`20060102`), history[0].NextDate)
+		assert.Equal(t, history[0].NextDate, history[1].Date)
+		assert.Equal(t, now.AddDate(0, 0, 6).Format(`20060102`), history[1].NextDate)
+	}
+
+	completed := getCompletions(t, "api/completed?from="+today+"&to="+today)
+	found := 0
+	for _, c := range completed {
+		if c.TaskID == id || c.TaskID == rid {
+			found++
+		}
+	}
+	assert.Equal(t, 3, found)
+
+	completed = getCompletions(t, "api/completed?to="+now.AddDate(0, 0, -1).Format(`20060102`))
+	for _, c := range completed {
+		assert.NotEqual(t, id, c.TaskID)
+	}
+
+	m, err = postJSON("api/completed?from=2024-01-01", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+}
+
+func TestCompletedTimezone(t *testing.T) {
+	app, db := cmd.New(&config.Config{
+		DBdriver:     "sqlite",
+		DBPath:       filepath.Join(t.TempDir(), "completed.db"),
+		Password:     "secret",
+		JWTKey:       "key",
+		Migrate:      true,
+		Registration: true,
+	})
+	defer db.Close()
+	srv := httptest.NewServer(app.Router())
+	defer srv.Close()
+
+	code, m := authRequest(t, srv, http.MethodPost, "/api/signup", "", map[string]string{"login": "alice", "password": "alice-pass"})
+	require.Equal(t, http.StatusCreated, code)
+	token := fmt.Sprint(m["token"])
+
+	//Пояса с разницей в сутки: хотя бы в одном из них дата пользователя отличается от даты сервера
+	for _, zone := range []string{"Pacific/Kiritimati", "Etc/GMT+12"} {
+		code, _ = authRequest(t, srv, http.MethodPut, "/api/me", token, map[string]string{"timezone": zone})
+		require.Equal(t, http.StatusOK, code)
+		loc, err := time.LoadLocation(zone)
+		require.NoError(t, err)
+		now := time.Now().In(loc)
+		day := func(offset int) string {
+			return now.AddDate(0, 0, offset).Format("20060102")
+		}
+
+		code, m = authRequest(t, srv, http.MethodPost, "/api/task", token, map[string]any{"date": day(0), "title": "Задача " + zone})
+		require.Equal(t, http.StatusCreated, code, m)
+		id := fmt.Sprint(m["id"])
+		code, _ = authRequest(t, srv, http.MethodPost, "/api/task/done?id="+id, token, nil)
+		require.Equal(t, http.StatusOK, code)
+
+		for query, want := range map[string]bool{
+			"from=" + day(0) + "&to=" + day(0): true,
+			"to=" + day(-1):                    false,
+			"from=" + day(1):                   false,
+		} {
+			code, m = authRequest(t, srv, http.MethodGet, "/api/completed?"+query, token, nil)
+			require.Equal(t, http.StatusOK, code, m)
+			found := false
+			for _, c := range m["completions"].([]any) {
+				found = found || c.(map[string]any)["task_id"] == id
+			}
+			assert.Equal(t, want, found, "%s %s", zone, query)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// importReport — отчёт об импорте
+type importReport struct {
+	Created int `json:"created"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+	Items   []struct {
+		Index  int    `json:"index"`
+		Ref    string `json:"ref"`
+		Title  string `json:"title"`
+		Status string `json:"status"`
+		ID     string `json:"id"`
+		Error  string `json:"error"`
+	} `json:"items"`
+}
+
+// postFile отправляет файл телом запроса и возвращает статус и тело ответа
+func postFile(t *testing.T, apipath, contentType string, body []byte) (int, []byte) {
+	req, err := http.NewRequest(http.MethodPost, getURL(apipath), bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, data
+}
+
+const importCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example//Tasks//EN
+BEGIN:VTODO
+UID:todo-1
+DTSTART;VALUE=DATE:20300107
+DUE;VALUE=DATE:20300110
+SUMMARY:Отчёт\, квартал
+DESCRIPTION:Первая строка\nвторая строка
+CATEGORIES:Работа,отчёты
+PRIORITY:1
+RRULE:FREQ=WEEKLY;BYDAY=MO,FR
+END:VTODO
+BEGIN:VEVENT
+UID:event-2
+DTSTART;TZID=Europe/Berlin:20300329T090000
+SUMMARY:Последняя пятница месяца с очень длинным названием, которое клиент пере
+ носит на следующую строку
+RRULE:FREQ=MONTHLY;BYDAY=-1FR
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Напоминание
+TRIGGER:-PT15M
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+UID:event-3
+DTSTART:20300401T120000Z
+SUMMARY:Звонок в UTC
+END:VEVENT
+BEGIN:VTODO
+UID:todo-4
+SUMMARY:Уже сделано
+STATUS:COMPLETED
+END:VTODO
+BEGIN:VEVENT
+UID:event-5
+DTSTART:20300401T120000Z
+SUMMARY:Каждый час
+RRULE:FREQ=HOURLY
+END:VEVENT
+BEGIN:VEVENT
+UID:event-6
+DTSTART;VALUE=DATE:20300402
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestImportICS(t *testing.T) {
+	code, body := postFile(t, "api/import/ics", "text/calendar", []byte("SUMMARY:не календарь\n"))
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, string(body), "error")
+
+	code, body = postFile(t, "api/import/ics", "text/calendar", []byte(strings.ReplaceAll(importCalendar, "\n", "\r\n")))
+	require.Equal(t, http.StatusOK, code, string(body))
+	var report importReport
+	require.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, 3, report.Created)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 2, report.Failed)
+	require.Len(t, report.Items, 6)
+
+	statuses := []string{"created", "created", "created", "skipped", "failed", "failed"}
+	for i, item := range report.Items {
+		assert.Equal(t, i+1, item.Index)
+		assert.Equal(t, statuses[i], item.Status, "Запись %d", i+1)
+		if item.Status == "created" {
+			assert.NotEmpty(t, item.ID)
+			defer requestJSON("api/task?id="+item.ID, nil, http.MethodDelete)
+		} else {
+			assert.Empty(t, item.ID)
+		}
+	}
+	assert.Equal(t, "event-5", report.Items[4].Ref)
+	assert.Contains(t, report.Items[4].Error, "HOURLY")
+	assert.NotEmpty(t, report.Items[5].Error)
+
+	var task struct {
+		Date     string   `json:"date"`
+		Time     string   `json:"time"`
+		Timezone string   `json:"timezone"`
+		Title    string   `json:"title"`
+		Comment  string   `json:"comment"`
+		Repeat   string   `json:"repeat"`
+		Priority int      `json:"priority"`
+		Tags     []string `json:"tags"`
+	}
+	get := func(id string) {
+		task.Time, task.Timezone, task.Repeat, task.Tags = "", "", "", nil
+		data, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &task))
+	}
+
+	//Повторения VTODO отсчитываются от DTSTART, правило заменено сокращённым
+	get(report.Items[0].ID)
+	assert.Equal(t, "20300107", task.Date)
+	assert.Equal(t, "Отчёт, квартал", task.Title)
+	assert.Equal(t, "Первая строка\nвторая строка", task.Comment)
+	assert.Equal(t, "w 1,5", task.Repeat)
+	assert.Equal(t, 3, task.Priority)
+	assert.Equal(t, []string{"отчёты", "работа"}, task.Tags)
+
+	get(report.Items[1].ID)
+	assert.Equal(t, "20300329", task.Date)
+	assert.Equal(t, "09:00", task.Time)
+	assert.Equal(t, "Europe/Berlin", task.Timezone)
+	assert.Equal(t, "FREQ=MONTHLY;BYDAY=-1FR", task.Repeat)
+	assert.Equal(t, "Последняя пятница месяца с очень длинным названием, которое клиент переносит на следующую строку", task.Title)
+
+	get(report.Items[2].ID)
+	assert.Equal(t, "20300401", task.Date)
+	assert.Equal(t, "12:00", task.Time)
+	assert.Equal(t, "UTC", task.Timezone)
+
+	//Файл можно передать полем file формы
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, err := mw.CreateFormFile("file", "tasks.ics")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Из формы\nDTSTART;VALUE=DATE:20300501\nRRULE:FREQ=DAILY;INTERVAL=3\nEND:VEVENT\nEND:VCALENDAR\n"))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+	code, body = postFile(t, "api/import/ics", mw.FormDataContentType(), form.Bytes())
+	require.Equal(t, http.StatusOK, code, string(body))
+	report = importReport{}
+	require.NoError(t, json.Unmarshal(body, &report))
+	require.Equal(t, 1, report.Created)
+	defer requestJSON("api/task?id="+report.Items[0].ID, nil, http.MethodDelete)
+	get(report.Items[0].ID)
+	assert.Equal(t, "d 3", task.Repeat)
+}
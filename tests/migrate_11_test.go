@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/agidelle/todo_web/internal/storage"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestMigrations(t *testing.T) {
+	dbfile := filepath.Join(t.TempDir(), "legacy.db")
+
+	//БД в том виде, в каком её создавали версии без schema_migrations
+	legacy, err := sqlx.Connect("sqlite", dbfile)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`CREATE TABLE scheduler (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		date CHAR(8) NOT NULL DEFAULT '',
+		title VARCHAR(128) NOT NULL DEFAULT '',
+		comment TEXT NOT NULL DEFAULT '',
+		repeat VARCHAR(128) NOT NULL DEFAULT ''
+	)`)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`INSERT INTO scheduler (date, title) VALUES ('20240101', 'Старая задача')`)
+	require.NoError(t, err)
+	require.NoError(t, legacy.Close())
+
+	s, err := storage.Open("sqlite", dbfile)
+	require.NoError(t, err)
+	defer s.Close()
+
+	status, err := s.MigrationStatus()
+	require.NoError(t, err)
+	require.NotEmpty(t, status)
+	for _, m := range status {
+		assert.Empty(t, m.AppliedAt, "миграция %d не должна быть применена", m.Version)
+	}
+
+	applied, err := s.MigrateUp()
+	require.NoError(t, err)
+	assert.Len(t, applied, len(status))
+
+	applied, err = s.MigrateUp()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	db, err := sqlx.Connect("sqlite", dbfile)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var task Task
+	require.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id = 1`))
+	assert.Equal(t, "Старая задача", task.Title)
+
+	last := status[len(status)-1]
+	reverted, err := s.MigrateDown(1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.Equal(t, last.Version, reverted[0].Version)
+
+	status, err = s.MigrationStatus()
+	require.NoError(t, err)
+	assert.Empty(t, status[len(status)-1].AppliedAt)
+	assert.NotEmpty(t, status[0].AppliedAt)
+
+	applied, err = s.MigrateUp()
+	require.NoError(t, err)
+	assert.Len(t, applied, 1)
+	require.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id = 1`))
+	assert.Equal(t, "Старая задача", task.Title)
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fullTask struct {
+	ID       string   `json:"id"`
+	Date     string   `json:"date"`
+	Title    string   `json:"title"`
+	Priority int      `json:"priority"`
+	Project  string   `json:"project"`
+	Tags     []string `json:"tags"`
+}
+
+func getFullTasks(t *testing.T, query url.Values) []fullTask {
+	body, err := requestJSON("api/tasks?"+query.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m struct {
+		Tasks []fullTask `json:"tasks"`
+	}
+	err = json.Unmarshal(body, &m)
+	assert.NoError(t, err)
+	return m.Tasks
+}
+
+func TestOrganize(t *testing.T) {
+	now := time.Now().Format(`20060102`)
+	project := "проект-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	for _, v := range []map[string]any{
+		{"title": "Приоритет вне диапазона", "priority": 4},
+		{"title": "Отрицательный приоритет", "priority": -1},
+	} {
+		m, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, m["error"], "Ожидается ошибка для задачи %v", v)
+	}
+
+	m, err := postJSON("api/task", map[string]any{
+		"date":     now,
+		"title":    "Подготовить релиз",
+		"priority": 3,
+		"project":  project,
+		"tags":     []string{"Работа", " срочно ", "работа"},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(m["id"])
+
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var task fullTask
+	assert.NoError(t, json.Unmarshal(body, &task))
+	assert.Equal(t, 3, task.Priority)
+	assert.Equal(t, project, task.Project)
+	assert.Equal(t, []string{"работа", "срочно"}, task.Tags)
+
+	m, err = postJSON("api/task", map[string]any{
+		"date":     now,
+		"title":    "Написать заметки к релизу",
+		"priority": 1,
+		"project":  project,
+		"tags":     []string{"работа"},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id2 := fmt.Sprint(m["id"])
+
+	tasks := getFullTasks(t, url.Values{"project": {project}})
+	assert.Len(t, tasks, 2)
+	tasks = getFullTasks(t, url.Values{"project": {project}, "priority": {"2"}})
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, id, tasks[0].ID)
+	}
+	tasks = getFullTasks(t, url.Values{"project": {project}, "tag": {"Работа", "срочно"}})
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, id, tasks[0].ID)
+	}
+
+	//Полное обновление задачи заменяет метки
+	m, err = postJSON("api/task", map[string]any{
+		"id":      id2,
+		"date":    now,
+		"title":   "Написать заметки к релизу",
+		"project": project,
+		"tags":    []string{"срочно"},
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, m["error"])
+	tasks = getFullTasks(t, url.Values{"project": {project}, "tag": {"срочно"}})
+	assert.Len(t, tasks, 2)
+	tasks = getFullTasks(t, url.Values{"project": {project}, "tag": {"работа"}})
+	assert.Len(t, tasks, 1)
+
+	m, err = postJSON("api/tasks?priority=high", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+}
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type taskPage struct {
+	Tasks      []fullTask `json:"tasks"`
+	NextCursor string     `json:"next_cursor"`
+	Total      int        `json:"total"`
+	Error      string     `json:"error"`
+}
+
+// getPage запрашивает страницу списка задач и возвращает статус, X-Total-Count и ответ
+func getPage(t *testing.T, apipath string, query url.Values) (int, string, taskPage) {
+	resp, err := http.Get(getURL(apipath + "?" + query.Encode()))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var page taskPage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	return resp.StatusCode, resp.Header.Get("X-Total-Count"), page
+}
+
+// walkPages проходит список по next_cursor и возвращает задачи всех страниц
+func walkPages(t *testing.T, query url.Values) []fullTask {
+	var tasks []fullTask
+	for i := 0; i < 100; i++ {
+		code, _, page := getPage(t, "api/tasks", query)
+		require.Equal(t, http.StatusOK, code, page.Error)
+		tasks = append(tasks, page.Tasks...)
+		if page.NextCursor == "" {
+			return tasks
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+	require.Fail(t, "Курсор не заканчивается")
+	return nil
+}
+
+func TestPagination(t *testing.T) {
+	project := "страницы-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	const n = 30
+	for i := 0; i < n; i++ {
+		m, err := postJSON("api/task", map[string]any{
+			"date":     fmt.Sprintf("203002%02d", i%10+1),
+			"title":    fmt.Sprintf("Страница %02d", i),
+			"priority": i % 4,
+			"project":  project,
+		}, http.MethodPost)
+		require.NoError(t, err)
+		require.Empty(t, m["error"])
+	}
+
+	//Без параметров страницы — первые 25 задач по дате, общее число только в заголовке
+	code, total, page := getPage(t, "api/tasks", url.Values{"project": {project}})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "30", total)
+	assert.Zero(t, page.Total)
+	assert.Len(t, page.Tasks, 25)
+	assert.Empty(t, page.NextCursor)
+	code, _, page = getPage(t, "api/tasks", url.Values{"project": {project}, "sort": {"date"}})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, n, page.Total)
+	assert.Len(t, page.Tasks, 25)
+	assert.NotEmpty(t, page.NextCursor)
+
+	ids := make(map[string]bool)
+	tasks := walkPages(t, url.Values{"project": {project}, "limit": {"7"}})
+	require.Len(t, tasks, n)
+	for i, task := range tasks {
+		ids[task.ID] = true
+		if i > 0 {
+			assert.LessOrEqual(t, tasks[i-1].Date, task.Date, "Задачи идут по дате")
+		}
+	}
+	assert.Len(t, ids, n, "Каждая задача встречается один раз")
+
+	tasks = walkPages(t, url.Values{"project": {project}, "limit": {"8"}, "sort": {"title"}, "order": {"desc"}})
+	require.Len(t, tasks, n)
+	for i, task := range tasks {
+		assert.Equal(t, fmt.Sprintf("Страница %02d", n-1-i), task.Title)
+	}
+
+	tasks = walkPages(t, url.Values{"project": {project}, "limit": {"4"}, "sort": {"priority"}, "order": {"desc"}})
+	require.Len(t, tasks, n)
+	for i := 1; i < len(tasks); i++ {
+		assert.GreaterOrEqual(t, tasks[i-1].Priority, tasks[i].Priority, "Задачи идут по убыванию приоритета")
+	}
+
+	tasks = walkPages(t, url.Values{"project": {project}, "limit": {"5"}, "sort": {"created"}})
+	require.Len(t, tasks, n)
+	assert.Equal(t, "Страница 00", tasks[0].Title)
+	assert.Equal(t, "Страница 29", tasks[n-1].Title)
+
+	//Диапазон дат включительно
+	code, total, page = getPage(t, "api/tasks", url.Values{"project": {project}, "from": {"20300203"}, "to": {"20300205"}})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "9", total)
+	assert.Len(t, page.Tasks, 9)
+	assert.Empty(t, page.NextCursor)
+	for _, task := range page.Tasks {
+		assert.True(t, task.Date >= "20300203" && task.Date <= "20300205", task.Date)
+	}
+
+	//Курсор выдан для сортировки по дате и не подходит для сортировки по заголовку
+	_, _, page = getPage(t, "api/tasks", url.Values{"project": {project}, "limit": {"3"}})
+	dateCursor := page.NextCursor
+	require.NotEmpty(t, dateCursor)
+	for name, query := range map[string]url.Values{
+		"Неизвестная сортировка":  {"sort": {"due"}},
+		"Неизвестное направление": {"order": {"up"}},
+		"Нулевой размер":          {"limit": {"0"}},
+		"Большой размер":          {"limit": {"101"}},
+		"Размер не число":         {"limit": {"много"}},
+		"Испорченный курсор":      {"cursor": {"не-курсор"}},
+		"Чужой курсор":            {"cursor": {dateCursor}, "sort": {"title"}},
+		"Неверная дата":           {"from": {"01.02.2030"}},
+	} {
+		code, _, page = getPage(t, "api/tasks", query)
+		assert.Equal(t, http.StatusBadRequest, code, name)
+		assert.NotEmpty(t, page.Error, name)
+	}
+
+	//Поиск и v2 поддерживают те же параметры
+	code, _, page = getPage(t, "api/tasks", url.Values{"search": {"Страница"}, "project": {project}, "limit": {"10"}})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, n, page.Total)
+	assert.Len(t, page.Tasks, 10)
+	code, total, page = getPage(t, "api/v2/tasks", url.Values{"project": {project}, "limit": {"10"}, "cursor": {dateCursor}})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "30", total)
+	assert.Len(t, page.Tasks, 10)
+	assert.NotEmpty(t, page.NextCursor)
+	code, _, _ = getPage(t, "api/v2/tasks", url.Values{"sort": {"due"}})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rawPatch отправляет тело патча как есть
+func rawPatch(t *testing.T, apipath, contentType, body string) (int, map[string]any) {
+	req, err := http.NewRequest(http.MethodPatch, getURL(apipath), strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var m map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+	return resp.StatusCode, m
+}
+
+func TestPatchTask(t *testing.T) {
+	id := addTask(t, task{
+		date:    "20300101",
+		title:   "Задача для PATCH",
+		comment: "Комментарий PATCH",
+		repeat:  "d 5",
+	})
+
+	//Меняется только заголовок, дата не сбрасывается на сегодня
+	ret, err := postJSON("api/task?id="+id, map[string]any{"title": "Изменено PATCH"}, http.MethodPatch)
+	require.NoError(t, err)
+	assert.Equal(t, id, ret["id"])
+	assert.Equal(t, "Изменено PATCH", ret["title"])
+	assert.Equal(t, "20300101", ret["date"])
+	assert.Equal(t, "Комментарий PATCH", ret["comment"])
+	assert.Equal(t, "d 5", ret["repeat"])
+	assert.NotEmpty(t, ret["due"])
+
+	//null сбрасывает поле
+	ret, err = postJSON("api/task?id="+id, map[string]any{"comment": nil, "priority": 3, "tags": []string{"Patch"}}, http.MethodPatch)
+	require.NoError(t, err)
+	assert.Nil(t, ret["comment"])
+	assert.Equal(t, float64(3), ret["priority"])
+	assert.Equal(t, []any{"patch"}, ret["tags"])
+	assert.Equal(t, "Изменено PATCH", ret["title"])
+
+	//Результат проверяется по правилам Update
+	for name, patch := range map[string]map[string]any{
+		"Сброс заголовка":         {"title": nil},
+		"Неверная дата":           {"date": "01.01.2030"},
+		"Неверный приоритет":      {"priority": 7},
+		"Неверный тип приоритета": {"priority": "high"},
+	} {
+		ret, err = postJSON("api/task?id="+id, patch, http.MethodPatch)
+		require.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], name)
+	}
+	ret, err = postJSON("api/task?id="+id, nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.Equal(t, "Изменено PATCH", ret["title"], "Задача не меняется при ошибке")
+	assert.Equal(t, "20300101", ret["date"])
+
+	code, m := rawPatch(t, "api/task?id="+id, "application/json", `["title"]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.NotEmpty(t, m["error"])
+	ret, err = postJSON("api/task?id=999999", map[string]any{"title": "Нет такой"}, http.MethodPatch)
+	require.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	//Выполненная задача не меняется
+	done := addTask(t, task{date: "20300101", title: "Выполненная для PATCH"})
+	_, err = postJSON("api/task/done?id="+done, nil, http.MethodPost)
+	require.NoError(t, err)
+	code, m = rawPatch(t, "api/v2/tasks/"+done, "application/merge-patch+json", `{"title":"Поздно"}`)
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, float64(http.StatusConflict), m["status"])
+
+	code, m = rawPatch(t, "api/v2/tasks/"+id, "application/merge-patch+json", `{"repeat":null,"time":"09:30"}`)
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Nil(t, m["repeat"])
+	assert.Equal(t, "09:30", m["time"])
+	assert.Equal(t, "Изменено PATCH", m["title"])
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queryError выполняет поиск с ошибкой в запросе и возвращает статус и позицию ошибки
+func queryError(t *testing.T, apipath, term string) (int, int) {
+	resp, err := http.Get(getURL(apipath + "?" + url.Values{"search": {term}}.Encode()))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var m struct {
+		Position int `json:"position"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+	return resp.StatusCode, m.Position
+}
+
+func TestSearchQuery(t *testing.T) {
+	mark := "q" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	add := func(values map[string]any) string {
+		values["title"] = fmt.Sprint(values["title"], " ", mark)
+		m, err := postJSON("api/task", values, http.MethodPost)
+		require.NoError(t, err)
+		require.Empty(t, m["error"])
+		return fmt.Sprint(m["id"])
+	}
+	quarter := add(map[string]any{"date": "20301105", "title": "Отчёт за квартал", "comment": "Черновик", "repeat": "w 1"})
+	annual := add(map[string]any{"date": "20301120", "title": "Отчёт годовой", "comment": "готово"})
+	call := add(map[string]any{"date": "20301201", "title": "Звонок", "repeat": "d 3", "tags": []string{"Срочно"}})
+
+	for term, want := range map[string][]string{
+		"title:отчёт":                   {quarter, annual},
+		"title:ОТЧЁТ -comment:черновик": {annual},
+		`title:"отчёт за"`:              {quarter},
+		"repeat:w":                      {quarter},
+		"repeat:D":                      {call},
+		"is:recurring":                  {quarter, call},
+		"-is:recurring":                 {annual},
+		"before:20301120":               {quarter},
+		"after:20301120":                {call},
+		"date:20.11.2030":               {annual},
+		"tag:срочно":                    {call},
+		"-tag:срочно":                   {quarter, annual},
+		"-звонок":                       {quarter, annual},
+		"годовой":                       {annual},
+		"title:отчёт repeat:w before:20301201 -comment:готово is:recurring": {quarter},
+	} {
+		assert.ElementsMatch(t, want, taskIDs(search(t, term+" "+mark)), term)
+	}
+
+	//Двоеточие после цифр — часть слова, а не поле
+	assert.Empty(t, search(t, "18:00 "+mark))
+
+	_, err := postJSON("api/task/done?id="+annual, nil, http.MethodPost)
+	require.NoError(t, err)
+	assert.Equal(t, []string{annual}, taskIDs(search(t, "is:done "+mark)))
+	assert.ElementsMatch(t, []string{quarter, call}, taskIDs(search(t, "-is:done "+mark)))
+
+	//Слово с двоеточием без известного поля ищется как текст
+	reply := add(map[string]any{"date": "20301202", "title": "Re: встреча по бюджету"})
+	link := add(map[string]any{"date": "20301203", "title": "Ссылка", "comment": "http://example.com/docs"})
+	todo := add(map[string]any{"date": "20301204", "title": "TODO:fix парсер"})
+	for term, want := range map[string][]string{
+		"Re: встреча":        {reply},
+		"re:":                {reply},
+		"http://example.com": {link},
+		"TODO:fix":           {todo},
+		"-todo:fix парсер":   {},
+	} {
+		assert.Equal(t, want, taskIDs(search(t, term+" "+mark)), term)
+	}
+
+	//Ошибка возвращается с номером символа, с которого она начинается
+	for term, pos := range map[string]int{
+		"title:":              7,
+		"before:2030":         8,
+		`title:"отчёт`:        7,
+		`"отчёт"за`:           8,
+		"-":                   1,
+		`""`:                  1,
+		"отчёт -priority:2":   7,
+		"is:maybe":            4,
+		"priority:высокий":    10,
+		"отчёт  -date:203001": 8,
+	} {
+		code, got := queryError(t, "api/tasks", term)
+		assert.Equal(t, http.StatusBadRequest, code, term)
+		assert.Equal(t, pos, got, term)
+	}
+	code, pos := queryError(t, "api/v2/tasks", "title:отчёт is:maybe")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, 16, pos)
+}
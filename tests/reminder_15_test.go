@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/reminder"
+	"github.com/agidelle/todo_web/internal/service"
+	"github.com/agidelle/todo_web/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTP — минимальный SMTP-сервер, сохраняющий полученные письма
+type fakeSMTP struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	mails []string
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeSMTP{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case cmd == "DATA":
+			reply("354 end with <CRLF>.<CRLF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.mails = append(s.mails, data.String())
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *fakeSMTP) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.mails...)
+}
+
+func TestReminders(t *testing.T) {
+	db, err := storage.Open("sqlite", filepath.Join(t.TempDir(), "reminders.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+	defer db.Close()
+	svc := service.NewService(db, db)
+
+	smtpServer := startFakeSMTP(t)
+	var (
+		mu    sync.Mutex
+		hooks []reminder.Reminder
+	)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rem reminder.Reminder
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&rem))
+		mu.Lock()
+		hooks = append(hooks, rem)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	notifiers := []reminder.Notifier{
+		&reminder.SMTPNotifier{Addr: smtpServer.ln.Addr().String(), From: "todo@localhost", To: []string{"user@localhost"}},
+		&reminder.WebhookNotifier{URL: webhook.URL},
+	}
+	offsets := []time.Duration{24 * time.Hour, time.Hour}
+	newEngine := func() *reminder.Engine {
+		return reminder.NewEngine(svc, db, notifiers, offsets, time.Minute)
+	}
+
+	now := time.Date(2030, 1, 15, 12, 0, 0, 0, time.UTC)
+	soonID, cErr := svc.Create(&domain.Task{Date: "20300115", Time: "12:30", Timezone: "UTC", Title: "Созвон"})
+	require.Nil(t, cErr)
+	_, cErr = svc.Create(&domain.Task{Date: "20300118", Time: "12:00", Timezone: "UTC", Title: "Отчёт"})
+	require.Nil(t, cErr)
+	laterID, cErr := svc.Create(&domain.Task{Date: "20300116", Time: "18:00", Timezone: "Europe/Moscow", Title: "Встреча"})
+	require.Nil(t, cErr)
+
+	//Созвону осталось 30 минут: отправляется только напоминание за час
+	engine := newEngine()
+	sent, err := engine.Tick(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent, "По одному напоминанию в каждый канал")
+	require.Len(t, hooks, 1)
+	assert.Equal(t, fmt.Sprint(soonID), hooks[0].TaskID)
+	assert.Equal(t, "2030-01-15T12:30:00Z", hooks[0].Due)
+	assert.Equal(t, time.Hour.String(), hooks[0].Before)
+	mails := smtpServer.received()
+	require.Len(t, mails, 1)
+	assert.Contains(t, mails[0], "Subject: =?utf-8?")
+	assert.Contains(t, mails[0], "Созвон")
+
+	//Повторный просмотр и перезапуск не отправляют напоминания снова
+	sent, err = engine.Tick(context.Background(), now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	sent, err = newEngine().Tick(context.Background(), now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	//Встреча в 18:00 по Москве — 15:00 UTC, за сутки напоминание приходит 15 января в 15:00 UTC
+	sent, err = engine.Tick(context.Background(), time.Date(2030, 1, 15, 14, 59, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	sent, err = engine.Tick(context.Background(), time.Date(2030, 1, 15, 15, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	require.Len(t, hooks, 2)
+	assert.Equal(t, fmt.Sprint(laterID), hooks[1].TaskID)
+	assert.Equal(t, "2030-01-16T18:00:00+03:00", hooks[1].Due)
+	assert.Equal(t, (24 * time.Hour).String(), hooks[1].Before)
+
+	//Выполненная задача больше не напоминает о себе
+	id := int(laterID)
+	require.Nil(t, svc.Done(&domain.Filter{ID: &id}))
+	sent, err = engine.Tick(context.Background(), time.Date(2030, 1, 16, 14, 30, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	_, err = reminder.ParseOffsets([]string{"1d,30m"})
+	assert.NoError(t, err)
+	_, err = reminder.ParseOffsets([]string{"-1h"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateRRule(t *testing.T) {
+	tbl := []nextDate{
+		{"20240126", "FREQ=DAILY;INTERVAL=3", "20240129"},
+		{"20240101", "FREQ=WEEKLY;INTERVAL=3;BYDAY=MO,FR", "20240212"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=2TU", "20240213"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=-1FR", "20240223"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "20240131"},
+		{"20230308", "FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=8", "20240308"},
+		{"20231123", "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "20241128"},
+		{"20240131", "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1", "20240229"},
+		{"20240126", "FREQ=MONTHLY;COUNT=5", "20240226"},
+		{"20240105", "FREQ=WEEKLY;UNTIL=20240202", "20240202"},
+		{"20240105", "FREQ=WEEKLY;UNTIL=20240201", ""},
+		{"20240101", "FREQ=DAILY;COUNT=3", ""},
+		{"20240101", "FREQ=HOURLY", ""},
+		{"20240101", "FREQ=WEEKLY;BYDAY=2MO", ""},
+		{"20240101", "FREQ=DAILY;COUNT=3;UNTIL=20240301", ""},
+		{"20240101", "INTERVAL=2", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`,
+			v.date, v.repeat, v.want)
+	}
+}
+
+func TestDoneRRuleCount(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	m, err := postJSON("api/task", map[string]any{
+		"title":  "Неверное правило",
+		"repeat": "FREQ=DAILY;BYDAY=1MO",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	_, ok := m["error"]
+	assert.True(t, ok, "Ожидается ошибка для неверного правила RRULE")
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Принять лекарство",
+		repeat: "FREQ=DAILY;COUNT=2",
+	})
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), task.Date)
+	assert.Equal(t, "FREQ=DAILY;COUNT=1", task.Repeat)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+}
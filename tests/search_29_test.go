@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type searchTask struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"`
+}
+
+// search ищет задачи через /api/tasks и возвращает найденные
+func search(t *testing.T, term string) []searchTask {
+	var page struct {
+		Tasks []searchTask `json:"tasks"`
+	}
+	body, err := requestJSON("api/tasks?"+url.Values{"search": {term}}.Encode(), nil, http.MethodGet)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, &page), string(body))
+	return page.Tasks
+}
+
+func taskIDs(tasks []searchTask) []string {
+	res := make([]string, len(tasks))
+	for i, task := range tasks {
+		res[i] = task.ID
+	}
+	return res
+}
+
+func TestFullTextSearch(t *testing.T) {
+	//Уникальное слово отделяет задачи теста от остальных
+	mark := "поиск" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	milk := addTask(t, task{date: "20300301", title: "Купить молоко " + mark, comment: "В магазине у дома"})
+	bread := addTask(t, task{date: "20300302", title: "Купить хлеб " + mark, comment: "Свежий батон"})
+	long := addTask(t, task{date: "20300303", title: "Квартальный отчёт " + mark,
+		comment: "Собрать данные по продажам за третий квартал, согласовать с бухгалтерией и отправить руководителю"})
+	short := addTask(t, task{date: "20300304", title: "Отчёт " + mark, comment: "отчёт"})
+
+	//Регистр кириллицы не учитывается, слово ищется по началу
+	assert.Equal(t, []string{milk}, taskIDs(search(t, "МОЛОКО "+mark)))
+	assert.Equal(t, []string{milk}, taskIDs(search(t, "молок "+mark)))
+	//Задача должна содержать все слова, в заголовке или в комментарии
+	assert.Equal(t, []string{bread}, taskIDs(search(t, "купить батон "+mark)))
+	assert.ElementsMatch(t, []string{milk, bread}, taskIDs(search(t, "купить "+mark)))
+
+	//Задача, где слово встречается чаще в коротком тексте, идёт первой
+	found := search(t, "отчёт "+mark)
+	require.Len(t, found, 2)
+	assert.Equal(t, []string{short, long}, taskIDs(found))
+	assert.Contains(t, found[0].Snippet, "<mark>Отчёт</mark>")
+
+	found = search(t, "бухгалтер "+mark)
+	require.Len(t, found, 1)
+	assert.Contains(t, found[0].Snippet, "<mark>бухгалтер")
+
+	//Символы синтаксиса FTS5 в запросе ищутся как обычный текст
+	for _, term := range []string{`мол"око`, "молоко AND OR", "(молоко*", "NEAR(молоко) *"} {
+		body, err := requestJSON("api/tasks?"+url.Values{"search": {term + " " + mark}}.Encode(), nil, http.MethodGet)
+		require.NoError(t, err)
+		assert.NotContains(t, string(body), `"error"`, term)
+	}
+
+	//Индекс следует за изменением и удалением задач
+	ret, err := postJSON("api/task", map[string]any{"id": milk, "date": "20300301", "title": "Купить кефир " + mark}, http.MethodPut)
+	require.NoError(t, err)
+	require.Empty(t, ret["error"])
+	assert.Empty(t, search(t, "молоко "+mark))
+	assert.Equal(t, []string{milk}, taskIDs(search(t, "кефир "+mark)))
+	_, err = postJSON("api/task?id="+bread, nil, http.MethodDelete)
+	require.NoError(t, err)
+	assert.Equal(t, []string{milk}, taskIDs(search(t, "купить "+mark)))
+
+	//Курсор поиска продолжает список по релевантности
+	code, _, page := getPage(t, "api/tasks", url.Values{"search": {"отчёт " + mark}, "limit": {"1"}})
+	require.Equal(t, http.StatusOK, code, page.Error)
+	require.Len(t, page.Tasks, 1)
+	assert.Equal(t, short, page.Tasks[0].ID)
+	assert.Equal(t, 2, page.Total)
+	code, _, page = getPage(t, "api/tasks", url.Values{"search": {"отчёт " + mark}, "limit": {"1"}, "cursor": {page.NextCursor}})
+	require.Equal(t, http.StatusOK, code, page.Error)
+	require.Len(t, page.Tasks, 1)
+	assert.Equal(t, long, page.Tasks[0].ID)
+	assert.Empty(t, page.NextCursor)
+}
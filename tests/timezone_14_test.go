@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type timedTask struct {
+	ID       string `json:"id"`
+	Date     string `json:"date"`
+	Time     string `json:"time"`
+	Timezone string `json:"timezone"`
+	Due      string `json:"due"`
+}
+
+func getTimedTask(t *testing.T, id string) timedTask {
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	require.NoError(t, err)
+	var task timedTask
+	require.NoError(t, json.Unmarshal(body, &task))
+	return task
+}
+
+func TestTimezone(t *testing.T) {
+	for _, v := range []map[string]any{
+		{"title": "Неверное время", "time": "25:00"},
+		{"title": "Время без минут", "time": "14"},
+		{"title": "Неизвестный пояс", "timezone": "Mars/Olympus"},
+		{"title": "Пояс сервера", "timezone": "Local"},
+	} {
+		m, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, m["error"], "Ожидается ошибка для задачи %v", v)
+	}
+
+	m, err := postJSON("api/task", map[string]any{
+		"date":     "20300115",
+		"title":    "Созвон",
+		"time":     "14:30",
+		"timezone": "Europe/Moscow",
+	}, http.MethodPost)
+	require.NoError(t, err)
+	task := getTimedTask(t, fmt.Sprint(m["id"]))
+	assert.Equal(t, "20300115", task.Date)
+	assert.Equal(t, "14:30", task.Time)
+	assert.Equal(t, "Europe/Moscow", task.Timezone)
+	assert.Equal(t, "2030-01-15T14:30:00+03:00", task.Due)
+
+	//Между UTC+14 и UTC-11 всегда разные календарные даты,
+	//просроченная задача переносится на сегодня в своём поясе
+	for _, zone := range []string{"Pacific/Kiritimati", "Pacific/Pago_Pago"} {
+		loc, err := time.LoadLocation(zone)
+		require.NoError(t, err)
+		m, err := postJSON("api/task", map[string]any{
+			"date":     "20000101",
+			"title":    "Просроченная задача",
+			"timezone": zone,
+		}, http.MethodPost)
+		require.NoError(t, err)
+		task := getTimedTask(t, fmt.Sprint(m["id"]))
+		today := time.Now().In(loc)
+		assert.Equal(t, today.Format("20060102"), task.Date, "Сегодняшняя дата в поясе %s", zone)
+		due, err := time.Parse(time.RFC3339, task.Due)
+		require.NoError(t, err)
+		_, offset := today.Zone()
+		_, dueOffset := due.Zone()
+		assert.Equal(t, offset, dueOffset)
+
+		//Ежедневная задача после выполнения переносится на завтра в своём поясе
+		_, err = postJSON("api/task", map[string]any{
+			"id":       task.ID,
+			"date":     task.Date,
+			"title":    "Ежедневная задача",
+			"repeat":   "d 1",
+			"timezone": zone,
+		}, http.MethodPut)
+		require.NoError(t, err)
+		_, err = postJSON("api/task/done?id="+task.ID, nil, http.MethodPost)
+		require.NoError(t, err)
+		task = getTimedTask(t, task.ID)
+		assert.Equal(t, today.AddDate(0, 0, 1).Format("20060102"), task.Date)
+	}
+
+	//Без параметра now сегодняшняя дата берётся в поясе tz
+	loc, err := time.LoadLocation("Pacific/Kiritimati")
+	require.NoError(t, err)
+	today := time.Now().In(loc)
+	body, err := getBody("api/nextdate?date=" + today.Format("20060102") + "&repeat=d+1&tz=Pacific/Kiritimati")
+	require.NoError(t, err)
+	assert.Equal(t, today.AddDate(0, 0, 1).Format("20060102"), string(body))
+
+	resp, err := http.Get(getURL("api/nextdate?date=20300101&repeat=d+1&tz=Mars/Olympus"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
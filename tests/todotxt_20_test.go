@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exportedLines возвращает строки выгрузки todo.txt с меткой mark
+func exportedLines(t *testing.T, mark string) []string {
+	resp, err := http.Get(getURL("api/export?format=todotxt"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/plain")
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "todo.txt")
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var lines []string
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.Contains(line, mark) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestTodoTxt(t *testing.T) {
+	mark := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	for _, task := range []map[string]any{
+		{"date": "20300210", "time": "09:30", "timezone": "Europe/Moscow", "title": "Отчёт " + mark,
+			"repeat": "w 7", "priority": 3, "project": "Мой дом", "tags": []string{"b", "a"}},
+		{"date": "20300212", "title": "Второй вторник " + mark, "repeat": "FREQ=MONTHLY;BYDAY=2TU", "comment": "не переносится"},
+	} {
+		m, err := postJSON("api/task", task, http.MethodPost)
+		require.NoError(t, err)
+		require.NotContains(t, m, "error")
+		defer requestJSON("api/task?id="+fmt.Sprint(m["id"]), nil, http.MethodDelete)
+	}
+	assert.Equal(t, []string{
+		"(A) Отчёт " + mark + " +Мой_дом @a @b due:2030-02-10 rec:1w at:09:30 tz:Europe/Moscow",
+		"Второй вторник " + mark + " due:2030-02-12 rrule:FREQ=MONTHLY;BYDAY=2TU",
+	}, exportedLines(t, mark))
+
+	file := strings.Join([]string{
+		"(B) 2030-01-01 Купить молоко " + mark + " +Дом @магазин due:2030-03-01 rec:1m цвет:белый",
+		"x 2030-01-02 2030-01-01 Сделано " + mark + " due:2030-03-02",
+		"",
+		"(D) Рабочие дни " + mark + " due:2030-03-04 rec:+1b",
+		"Каждые два месяца " + mark + " due:2030-03-05 rec:2m",
+		"Неверное правило " + mark + " due:2030-03-06 rec:2b",
+		"Неверная дата " + mark + " due:2030-13-40",
+		"(B) 2030-01-01 Купить молоко " + mark + " +Дом @магазин due:2030-03-01 rec:1m цвет:белый",
+	}, "\n")
+	code, body := postFile(t, "api/import?dry_run=true", "text/plain", []byte(file))
+	require.Equal(t, http.StatusOK, code, string(body))
+	var report importReport
+	require.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, 4, report.Created)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 2, report.Failed)
+	assert.Len(t, exportedLines(t, mark), 2, "Пробный импорт не создаёт задачи")
+
+	code, body = postFile(t, "api/import?format=todotxt", "application/octet-stream", []byte(file))
+	require.Equal(t, http.StatusOK, code, string(body))
+	report = importReport{}
+	require.NoError(t, json.Unmarshal(body, &report))
+	statuses := []string{"created", "created", "created", "created", "failed", "failed", "skipped"}
+	require.Len(t, report.Items, len(statuses))
+	for i, item := range report.Items {
+		assert.Equal(t, statuses[i], item.Status, "Строка %d: %s", i+1, item.Error)
+		if item.ID != "" {
+			defer requestJSON("api/task?id="+item.ID, nil, http.MethodDelete)
+		}
+	}
+	assert.Equal(t, "строка 6", report.Items[4].Ref)
+	assert.Contains(t, report.Items[4].Error, "2b")
+	assert.Equal(t, "строка 7", report.Items[5].Ref)
+	assert.Equal(t, "Неверная дата "+mark, report.Items[5].Title)
+
+	tasks := exportedJSON(t, mark)
+	require.Len(t, tasks, 6)
+	milk := tasks[2]
+	assert.Equal(t, "Купить молоко "+mark+" цвет:белый", milk.Title)
+	assert.Equal(t, "20300301", milk.Date)
+	assert.Equal(t, "m 1", milk.Repeat)
+	assert.Equal(t, 2, milk.Priority)
+	assert.Equal(t, "Дом", milk.Project)
+	assert.Equal(t, []string{"магазин"}, milk.Tags)
+	assert.Equal(t, "done", tasks[3].Status)
+	assert.Equal(t, "w 1,2,3,4,5", tasks[4].Repeat)
+	assert.Equal(t, 1, tasks[4].Priority)
+	assert.Equal(t, "FREQ=MONTHLY;INTERVAL=2", tasks[5].Repeat)
+
+	//Повторная загрузка выгрузки ничего не создаёт
+	lines := exportedLines(t, mark)
+	require.Len(t, lines, 6)
+	assert.Equal(t, "x Сделано "+mark+" due:2030-03-02", lines[3])
+	code, body = postFile(t, "api/import", "text/plain; charset=utf-8", []byte(strings.Join(lines, "\n")))
+	require.Equal(t, http.StatusOK, code, string(body))
+	report = importReport{}
+	require.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 6, report.Skipped)
+}
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type exportedTask struct {
+	ID       string   `json:"id"`
+	Date     string   `json:"date"`
+	Time     string   `json:"time"`
+	Title    string   `json:"title"`
+	Comment  string   `json:"comment"`
+	Repeat   string   `json:"repeat"`
+	Priority int      `json:"priority"`
+	Project  string   `json:"project"`
+	Tags     []string `json:"tags"`
+	Status   string   `json:"status"`
+}
+
+// exportTasks выгружает задачи и возвращает тело ответа
+func exportTasks(t *testing.T, format string) []byte {
+	resp, err := http.Get(getURL("api/export?format=" + format))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "tasks."+format)
+	return body
+}
+
+// exportedJSON возвращает выгруженные задачи с меткой mark в заголовке
+func exportedJSON(t *testing.T, mark string) []exportedTask {
+	var file struct {
+		Tasks []exportedTask `json:"tasks"`
+	}
+	require.NoError(t, json.Unmarshal(exportTasks(t, "json"), &file))
+	var tasks []exportedTask
+	for _, task := range file.Tasks {
+		if strings.Contains(task.Title, mark) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+func TestExportImport(t *testing.T) {
+	mark := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	m, err := postJSON("api/task", map[string]any{
+		"date": "20300210", "time": "08:30", "title": "Экспорт, с запятой " + mark, "comment": "строка 1\nстрока 2",
+		"repeat": "d 3", "priority": 2, "project": "Дом", "tags": []string{"b", "a"},
+	}, http.MethodPost)
+	require.NoError(t, err)
+	active := fmt.Sprint(m["id"])
+	m, err = postJSON("api/task", map[string]any{"date": "20300211", "title": "Выполненная " + mark}, http.MethodPost)
+	require.NoError(t, err)
+	done := fmt.Sprint(m["id"])
+	_, err = postJSON("api/task/done?id="+done, nil, http.MethodPost)
+	require.NoError(t, err)
+
+	//Выгружаются и выполненные задачи
+	tasks := exportedJSON(t, mark)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, exportedTask{ID: active, Date: "20300210", Time: "08:30", Title: "Экспорт, с запятой " + mark,
+		Comment: "строка 1\nстрока 2", Repeat: "d 3", Priority: 2, Project: "Дом", Tags: []string{"a", "b"}, Status: "active"}, tasks[0])
+	assert.Equal(t, done, tasks[1].ID)
+	assert.Equal(t, "done", tasks[1].Status)
+
+	records, err := csv.NewReader(bytes.NewReader(exportTasks(t, "csv"))).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "date", "time", "timezone", "title", "comment", "repeat", "priority", "project", "tags", "status"}, records[0])
+	var rows [][]string
+	for _, record := range records[1:] {
+		if strings.Contains(record[4], mark) {
+			rows = append(rows, record)
+		}
+	}
+	require.Len(t, rows, 2)
+	assert.Equal(t, []string{active, "20300210", "08:30", "", "Экспорт, с запятой " + mark, "строка 1\nстрока 2", "d 3", "2", "Дом", "a,b", "active"}, rows[0])
+
+	//Повторный импорт выгрузки ничего не создаёт
+	data, err := json.Marshal(map[string]any{"tasks": tasks})
+	require.NoError(t, err)
+	code, body := postFile(t, "api/import?format=json", "application/json", data)
+	require.Equal(t, http.StatusOK, code, string(body))
+	var report importReport
+	require.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 2, report.Skipped)
+	assert.Contains(t, report.Items[0].Error, active)
+
+	//Перенос на «пустой» сервер: удаляем задачи и загружаем CSV
+	for _, id := range []string{active, done} {
+		_, err = requestJSON("api/task?id="+id, nil, http.MethodDelete)
+		require.NoError(t, err)
+	}
+	var file bytes.Buffer
+	w := csv.NewWriter(&file)
+	require.NoError(t, w.WriteAll(append([][]string{records[0]}, append(rows,
+		[]string{"", "20300212", "", "", "Неверный приоритет " + mark, "", "", "высокий", "", "", ""},
+		[]string{"", "2030-02-12", "", "", "Неверная дата " + mark, "", "", "", "", "", ""},
+		[]string{"", "20300212", "", "", "", "", "", "", "", "", ""},
+		[]string{"", "20200212", "", "", "Неверное правило " + mark, "", "z 1", "", "", "", ""},
+		[]string{"", "20300212", "", "", "Неизвестное состояние " + mark, "", "", "", "", "", "archived"},
+		rows[0],
+	)...)))
+
+	code, body = postFile(t, "api/import?dry_run=true", "text/csv", file.Bytes())
+	require.Equal(t, http.StatusOK, code, string(body))
+	report = importReport{}
+	require.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 5, report.Failed)
+	assert.Empty(t, report.Items[0].ID, "Пробный импорт не создаёт задачи")
+	assert.Empty(t, exportedJSON(t, mark))
+
+	code, body = postFile(t, "api/import", "text/csv", file.Bytes())
+	require.Equal(t, http.StatusOK, code, string(body))
+	report = importReport{}
+	require.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, 2, report.Created)
+	statuses := []string{"created", "created", "failed", "failed", "failed", "failed", "failed", "skipped"}
+	require.Len(t, report.Items, len(statuses))
+	for i, item := range report.Items {
+		assert.Equal(t, statuses[i], item.Status, "Строка %d: %s", i+1, item.Error)
+	}
+	assert.Equal(t, active, report.Items[0].Ref)
+
+	imported := exportedJSON(t, mark)
+	require.Len(t, imported, 2)
+	for i := range imported {
+		assert.NotEqual(t, tasks[i].ID, imported[i].ID)
+		defer requestJSON("api/task?id="+imported[i].ID, nil, http.MethodDelete)
+		imported[i].ID = tasks[i].ID
+	}
+	assert.Equal(t, tasks, imported)
+
+	code, _ = postFile(t, "api/import?format=xml", "application/xml", []byte("<tasks/>"))
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = postFile(t, "api/import", "text/csv", []byte("date,comment\n20300101,нет заголовка\n"))
+	assert.Equal(t, http.StatusBadRequest, code)
+	resp, err := http.Get(getURL("api/export?format=xml"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
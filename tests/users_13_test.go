@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agidelle/todo_web/cmd"
+	"github.com/agidelle/todo_web/internal/config"
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authRequest выполняет запрос к тестовому серверу с токеном в заголовке Authorization
+func authRequest(t *testing.T, srv *httptest.Server, method, path, token string, body any) (int, map[string]any) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, srv.URL+path, bytes.NewReader(data))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var m map[string]any
+	if len(raw) > 0 {
+		require.NoError(t, json.Unmarshal(raw, &m), string(raw))
+	}
+	return resp.StatusCode, m
+}
+
+func TestUsers(t *testing.T) {
+	dbfile := filepath.Join(t.TempDir(), "users.db")
+
+	//Задача, созданная до включения учётных записей, достаётся администратору
+	s, err := storage.Open("sqlite", dbfile)
+	require.NoError(t, err)
+	require.NoError(t, s.Migrate())
+	legacyID, err := s.CreateTask(&domain.Task{Date: "20300101", Title: "Старая задача"})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	app, db := cmd.New(&config.Config{
+		DBdriver:     "sqlite",
+		DBPath:       dbfile,
+		Password:     "secret",
+		JWTKey:       "key",
+		Migrate:      true,
+		Registration: true,
+	})
+	defer db.Close()
+	srv := httptest.NewServer(app.Router())
+	defer srv.Close()
+
+	code, _ := authRequest(t, srv, http.MethodGet, "/api/tasks", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, code, "Запрос без токена должен отклоняться")
+	code, _ = authRequest(t, srv, http.MethodGet, "/api/tasks", Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, code, "Токен без sub должен отклоняться")
+
+	code, m := authRequest(t, srv, http.MethodPost, "/api/signin", "", map[string]string{"password": "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.NotEmpty(t, m["error"])
+
+	//Веб-интерфейс входит только по паролю
+	code, m = authRequest(t, srv, http.MethodPost, "/api/signin", "", map[string]string{"password": "secret"})
+	require.Equal(t, http.StatusOK, code)
+	adminToken := fmt.Sprint(m["token"])
+	assert.NotContains(t, m, "hash", "Ответ не должен содержать хэш пароля")
+
+	code, _ = authRequest(t, srv, http.MethodGet, fmt.Sprintf("/api/task?id=%d", legacyID), adminToken, nil)
+	assert.Equal(t, http.StatusOK, code, "Старая задача должна принадлежать администратору")
+
+	code, m = authRequest(t, srv, http.MethodPost, "/api/signup", "", map[string]string{"login": "alice", "password": "alice-pass"})
+	require.Equal(t, http.StatusCreated, code)
+	aliceToken := fmt.Sprint(m["token"])
+	code, _ = authRequest(t, srv, http.MethodPost, "/api/signup", "", map[string]string{"login": "alice", "password": "other"})
+	assert.Equal(t, http.StatusConflict, code)
+	code, _ = authRequest(t, srv, http.MethodPost, "/api/signup", "", map[string]string{"login": "a b", "password": "alice-pass"})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	//Часовой пояс пользователя применяется к задачам без собственного пояса
+	code, _ = authRequest(t, srv, http.MethodPut, "/api/me", aliceToken, map[string]string{"timezone": "Mars/Olympus"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, m = authRequest(t, srv, http.MethodPut, "/api/me", aliceToken, map[string]string{"timezone": "Pacific/Kiritimati"})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Pacific/Kiritimati", m["timezone"])
+
+	code, m = authRequest(t, srv, http.MethodPost, "/api/task", aliceToken, map[string]string{"title": "Задача Алисы", "date": "20000101"})
+	require.Equal(t, http.StatusCreated, code)
+	aliceTask := fmt.Sprint(m["id"])
+	code, m = authRequest(t, srv, http.MethodGet, "/api/task?id="+aliceTask, aliceToken, nil)
+	require.Equal(t, http.StatusOK, code)
+	kiritimati, err := time.LoadLocation("Pacific/Kiritimati")
+	require.NoError(t, err)
+	assert.Equal(t, time.Now().In(kiritimati).Format("20060102"), m["date"])
+	assert.Contains(t, m["due"], "+14:00")
+
+	//Администратор не видит задачи Алисы, Алиса — задачи администратора
+	code, _ = authRequest(t, srv, http.MethodGet, "/api/task?id="+aliceTask, adminToken, nil)
+	assert.NotEqual(t, http.StatusOK, code)
+	code, _ = authRequest(t, srv, http.MethodGet, fmt.Sprintf("/api/task?id=%d", legacyID), aliceToken, nil)
+	assert.NotEqual(t, http.StatusOK, code)
+	code, _ = authRequest(t, srv, http.MethodDelete, "/api/task?id="+aliceTask, adminToken, nil)
+	assert.NotEqual(t, http.StatusOK, code)
+	code, _ = authRequest(t, srv, http.MethodPost, "/api/task/done?id="+aliceTask, adminToken, nil)
+	assert.NotEqual(t, http.StatusOK, code)
+
+	code, m = authRequest(t, srv, http.MethodGet, "/api/tasks", aliceToken, nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, m["tasks"], 1)
+
+	//Учётные записи создаёт только администратор
+	code, _ = authRequest(t, srv, http.MethodPost, "/api/users", aliceToken, map[string]string{"login": "bob", "password": "bob-pass"})
+	assert.Equal(t, http.StatusForbidden, code)
+	code, m = authRequest(t, srv, http.MethodPost, "/api/users", adminToken, map[string]string{"login": "bob", "password": "bob-pass"})
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "bob", m["login"])
+	assert.NotContains(t, m, "password_hash")
+
+	code, m = authRequest(t, srv, http.MethodPost, "/api/signin", "", map[string]string{"login": "bob", "password": "bob-pass"})
+	require.Equal(t, http.StatusOK, code)
+	code, m = authRequest(t, srv, http.MethodGet, "/api/tasks", fmt.Sprint(m["token"]), nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, m["tasks"])
+
+	code, m = authRequest(t, srv, http.MethodGet, "/api/users", adminToken, nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, m["users"], 3)
+}
@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agidelle/todo_web/cmd"
+	"github.com/agidelle/todo_web/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type v2Response struct {
+	Code   int
+	Header http.Header
+	Body   map[string]any
+}
+
+// v2Request отправляет запрос к /api/v2 с JSON-телом, если оно задано
+func v2Request(t *testing.T, srv *httptest.Server, method, path, token, body string) v2Response {
+	req, err := http.NewRequest(method, srv.URL+"/api/v2"+path, strings.NewReader(body))
+	require.NoError(t, err)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	res := v2Response{Code: resp.StatusCode, Header: resp.Header}
+	if len(raw) > 0 {
+		require.NoError(t, json.Unmarshal(raw, &res.Body), string(raw))
+	}
+	return res
+}
+
+// assertProblem проверяет ответ с ошибкой в формате RFC 7807
+func assertProblem(t *testing.T, resp v2Response, code int, msg string) {
+	t.Helper()
+	assert.Equal(t, code, resp.Code, msg)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"), msg)
+	assert.Equal(t, "about:blank", resp.Body["type"], msg)
+	assert.Equal(t, http.StatusText(code), resp.Body["title"], msg)
+	assert.Equal(t, float64(code), resp.Body["status"], msg)
+	assert.NotEmpty(t, resp.Body["detail"], msg)
+}
+
+func TestRESTv2(t *testing.T) {
+	app, db := cmd.New(&config.Config{
+		DBdriver:     "sqlite",
+		DBPath:       filepath.Join(t.TempDir(), "v2.db"),
+		Password:     "secret",
+		JWTKey:       "key",
+		Migrate:      true,
+		Registration: true,
+	})
+	defer db.Close()
+	srv := httptest.NewServer(app.Router())
+	defer srv.Close()
+
+	tokens := make(map[string]string)
+	for _, login := range []string{"alice", "bob"} {
+		code, m := authRequest(t, srv, http.MethodPost, "/api/signup", "", map[string]string{"login": login, "password": login + "-pass"})
+		require.Equal(t, http.StatusCreated, code)
+		tokens[login] = fmt.Sprint(m["token"])
+	}
+	token := tokens["alice"]
+
+	resp := v2Request(t, srv, http.MethodGet, "/tasks", "", "")
+	assertProblem(t, resp, http.StatusUnauthorized, "Запрос без токена")
+	assert.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+
+	//Создание: 201, Location и сама задача
+	resp = v2Request(t, srv, http.MethodPost, "/tasks", token, `{"date":"20300101","title":"Задача v2","repeat":"d 2","priority":2}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	id := fmt.Sprint(resp.Body["id"])
+	assert.Equal(t, "/api/v2/tasks/"+id, resp.Header.Get("Location"))
+	assert.Equal(t, "Задача v2", resp.Body["title"])
+	assert.NotEmpty(t, resp.Body["due"])
+
+	resp = v2Request(t, srv, http.MethodPost, "/tasks", token, `{"date":"20300101"}`)
+	assertProblem(t, resp, http.StatusUnprocessableEntity, "Задача без заголовка")
+	resp = v2Request(t, srv, http.MethodPost, "/tasks", token, `{"title":`)
+	assertProblem(t, resp, http.StatusBadRequest, "Некорректный JSON")
+
+	resp = v2Request(t, srv, http.MethodGet, "/tasks/"+id, token, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, id, resp.Body["id"])
+	assertProblem(t, v2Request(t, srv, http.MethodGet, "/tasks/"+id, tokens["bob"], ""), http.StatusNotFound, "Чужая задача")
+	assertProblem(t, v2Request(t, srv, http.MethodGet, "/tasks/abc", token, ""), http.StatusNotFound, "Некорректный id")
+	assertProblem(t, v2Request(t, srv, http.MethodGet, "/tasks/999999", token, ""), http.StatusNotFound, "Несуществующая задача")
+
+	//Изменение: id берётся из адреса
+	resp = v2Request(t, srv, http.MethodPut, "/tasks/"+id, token, `{"id":"1000","date":"20300101","title":"Изменённая v2","repeat":"d 2"}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body)
+	assert.Equal(t, id, resp.Body["id"])
+	assert.Equal(t, "Изменённая v2", resp.Body["title"])
+	resp = v2Request(t, srv, http.MethodPut, "/tasks/"+id, token, `{"date":"01.01.2030","title":"v2"}`)
+	assertProblem(t, resp, http.StatusUnprocessableEntity, "Неверный формат даты")
+	resp = v2Request(t, srv, http.MethodPut, "/tasks/999999", token, `{"title":"Нет такой"}`)
+	assertProblem(t, resp, http.StatusNotFound, "Изменение несуществующей задачи")
+
+	resp = v2Request(t, srv, http.MethodGet, "/tasks?search=Изменённая", token, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Len(t, resp.Body["tasks"], 1)
+	assertProblem(t, v2Request(t, srv, http.MethodGet, "/tasks?status=archived", token, ""), http.StatusUnprocessableEntity, "Неизвестное состояние")
+
+	//Выполнение повторяющейся задачи переносит её, разовой — переводит в done
+	resp = v2Request(t, srv, http.MethodPost, "/tasks/"+id+"/done", token, "")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body)
+	assert.Equal(t, "20300103", resp.Body["date"])
+	resp = v2Request(t, srv, http.MethodPost, "/tasks", token, `{"date":"20300101","title":"Разовая v2"}`)
+	require.Equal(t, http.StatusCreated, resp.Code)
+	once := fmt.Sprint(resp.Body["id"])
+	resp = v2Request(t, srv, http.MethodPost, "/tasks/"+once+"/done", token, "")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body)
+	assert.Equal(t, "done", resp.Body["status"])
+	assertProblem(t, v2Request(t, srv, http.MethodPost, "/tasks/"+once+"/done", token, ""), http.StatusConflict, "Повторное выполнение")
+	resp = v2Request(t, srv, http.MethodPut, "/tasks/"+once, token, `{"date":"20300101","title":"Разовая"}`)
+	assertProblem(t, resp, http.StatusConflict, "Изменение выполненной задачи")
+	resp = v2Request(t, srv, http.MethodGet, "/tasks?status=done", token, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Len(t, resp.Body["tasks"], 1)
+
+	resp = v2Request(t, srv, http.MethodDelete, "/tasks/"+id, token, "")
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	assert.Nil(t, resp.Body)
+	assertProblem(t, v2Request(t, srv, http.MethodDelete, "/tasks/"+id, token, ""), http.StatusNotFound, "Повторное удаление")
+
+	assertProblem(t, v2Request(t, srv, http.MethodGet, "/unknown", token, ""), http.StatusNotFound, "Неизвестный адрес")
+	assertProblem(t, v2Request(t, srv, http.MethodPatch, "/tasks", token, ""), http.StatusMethodNotAllowed, "Неподдерживаемый метод")
+
+	//Согласование типов содержимого
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/v2/tasks", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "text/html, application/json;q=0")
+	res, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotAcceptable, res.StatusCode)
+	assert.Equal(t, "application/problem+json", res.Header.Get("Content-Type"))
+
+	req, err = http.NewRequest(http.MethodPost, srv.URL+"/api/v2/tasks", strings.NewReader("title=v2"))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err = srv.Client().Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, res.StatusCode)
+
+	//v1 по-прежнему отвечает в своём формате
+	code, m := authRequest(t, srv, http.MethodGet, "/api/task?id=999999", token, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.NotEmpty(t, m["error"])
+}
@@ -0,0 +1,291 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agidelle/todo_web/internal/domain"
+	"github.com/agidelle/todo_web/internal/service"
+	"github.com/agidelle/todo_web/internal/storage"
+	"github.com/agidelle/todo_web/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type hookCall struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+// hookReceiver — получатель webhook, отвечающий статусами из codes по очереди, затем 200
+type hookReceiver struct {
+	*httptest.Server
+	mu    sync.Mutex
+	calls []hookCall
+	codes []int
+}
+
+func newHookReceiver(codes ...int) *hookReceiver {
+	h := &hookReceiver{codes: codes}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.calls = append(h.calls, hookCall{
+			event:     r.Header.Get(webhook.HeaderEvent),
+			signature: r.Header.Get(webhook.HeaderSignature),
+			body:      body,
+		})
+		if len(h.codes) > 0 {
+			w.WriteHeader(h.codes[0])
+			h.codes = h.codes[1:]
+		}
+	}))
+	return h
+}
+
+func (h *hookReceiver) received() []hookCall {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]hookCall(nil), h.calls...)
+}
+
+func TestWebhooks(t *testing.T) {
+	receiver := newHookReceiver()
+	defer receiver.Close()
+
+	for _, v := range []map[string]any{
+		{"url": "ftp://example.com/hook"},
+		{"url": "/relative"},
+		{"url": receiver.URL, "events": []string{"task.archived"}},
+	} {
+		m, err := postJSON("api/webhooks", v, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, m["error"], "Ожидается ошибка для подписки %v", v)
+	}
+
+	m, err := postJSON("api/webhooks", map[string]any{
+		"url":    receiver.URL,
+		"secret": "s3cret",
+		"events": []string{domain.EventTaskCreated, domain.EventTaskDone},
+	}, http.MethodPost)
+	require.NoError(t, err)
+	require.Empty(t, m["error"])
+	assert.Equal(t, "s3cret", m["secret"])
+	hookID := fmt.Sprint(m["id"])
+	defer requestJSON("api/webhook?id="+hookID, nil, http.MethodDelete)
+
+	body, err := requestJSON("api/webhook?id="+hookID, nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "s3cret", "Секрет не должен возвращаться повторно")
+
+	m, err = postJSON("api/task", map[string]any{"title": "Задача для webhook", "date": "20300101"}, http.MethodPost)
+	require.NoError(t, err)
+	taskID := fmt.Sprint(m["id"])
+	_, err = postJSON("api/task", map[string]any{"id": taskID, "title": "Изменённая задача", "date": "20300101"}, http.MethodPut)
+	require.NoError(t, err)
+	_, err = postJSON("api/task/done?id="+taskID, nil, http.MethodPost)
+	require.NoError(t, err)
+
+	//Изменение задачи не входит в подписку
+	require.Eventually(t, func() bool { return len(receiver.received()) >= 2 }, 5*time.Second, 50*time.Millisecond)
+	calls := receiver.received()
+	require.Len(t, calls, 2)
+	for i, want := range []string{domain.EventTaskCreated, domain.EventTaskDone} {
+		assert.Equal(t, want, calls[i].event)
+		assert.Equal(t, webhook.Sign("s3cret", calls[i].body), calls[i].signature)
+		var event domain.TaskEvent
+		require.NoError(t, json.Unmarshal(calls[i].body, &event))
+		assert.Equal(t, want, event.Type)
+		assert.Equal(t, taskID, event.TaskID)
+	}
+	var done domain.TaskEvent
+	require.NoError(t, json.Unmarshal(calls[1].body, &done))
+	require.NotNil(t, done.Completion)
+	assert.Equal(t, "20300101", done.Completion.Date)
+
+	var log struct {
+		Deliveries []domain.WebhookDelivery `json:"deliveries"`
+	}
+	body, err = requestJSON("api/webhook/deliveries?id="+hookID, nil, http.MethodGet)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, &log))
+	require.Len(t, log.Deliveries, 2)
+	for _, d := range log.Deliveries {
+		assert.Equal(t, domain.DeliveryDelivered, d.Status)
+		assert.Equal(t, 1, d.Attempts)
+		assert.Equal(t, http.StatusOK, d.ResponseCode)
+	}
+
+	_, err = postJSON("api/webhook?id="+hookID, map[string]any{"url": receiver.URL, "active": false}, http.MethodPut)
+	require.NoError(t, err)
+	_, err = postJSON("api/task", map[string]any{"title": "Без уведомления", "date": "20300101"}, http.MethodPost)
+	require.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, receiver.received(), 2, "Отключённая подписка не получает события")
+
+	m, err = postJSON("api/webhook?id="+hookID, nil, http.MethodDelete)
+	require.NoError(t, err)
+	assert.Empty(t, m["error"])
+	m, err = postJSON("api/webhook?id="+hookID, nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+}
+
+func TestWebhookRetries(t *testing.T) {
+	db, err := storage.Open("sqlite", filepath.Join(t.TempDir(), "webhooks.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+	defer db.Close()
+
+	dispatcher := webhook.NewDispatcher(db, time.Minute, true)
+	hooks := webhook.NewService(db, nil)
+	svc := service.NewService(db, db)
+	svc.Subscribe(hooks.HandleEvent)
+
+	flaky := newHookReceiver(http.StatusInternalServerError, http.StatusBadGateway)
+	defer flaky.Close()
+	broken := newHookReceiver()
+	broken.codes = make([]int, webhook.MaxAttempts)
+	for i := range broken.codes {
+		broken.codes[i] = http.StatusInternalServerError
+	}
+	defer broken.Close()
+
+	flakyHook, cErr := hooks.Create(&domain.Webhook{URL: flaky.URL})
+	require.Nil(t, cErr)
+	brokenHook, cErr := hooks.Create(&domain.Webhook{URL: broken.URL, Events: []string{domain.EventTaskCreated}})
+	require.Nil(t, cErr)
+
+	_, cErr = svc.Create(&domain.Task{Title: "Повторная доставка", Date: "20300101"})
+	require.Nil(t, cErr)
+
+	ctx := context.Background()
+	now := time.Now()
+	delivered, err := dispatcher.DeliverPending(ctx, now)
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+
+	deliveries, cErr := hooks.Deliveries(0, flakyHook.ID)
+	require.Nil(t, cErr)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, domain.DeliveryPending, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseCode)
+	assert.Equal(t, now.Add(webhook.Backoff(1)).UTC().Format(time.RFC3339), deliveries[0].NextAttemptAt)
+
+	//До истечения задержки повторная попытка не выполняется
+	_, err = dispatcher.DeliverPending(ctx, now.Add(webhook.Backoff(1)/2))
+	require.NoError(t, err)
+	assert.Len(t, flaky.received(), 1)
+
+	now = now.Add(webhook.Backoff(1))
+	_, err = dispatcher.DeliverPending(ctx, now)
+	require.NoError(t, err)
+	now = now.Add(webhook.Backoff(2))
+	delivered, err = dispatcher.DeliverPending(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	deliveries, cErr = hooks.Deliveries(0, flakyHook.ID)
+	require.Nil(t, cErr)
+	assert.Equal(t, domain.DeliveryDelivered, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+
+	//После MaxAttempts неудачных попыток доставка прекращается
+	for i := 3; i <= webhook.MaxAttempts; i++ {
+		now = now.Add(webhook.Backoff(i))
+		_, err = dispatcher.DeliverPending(ctx, now)
+		require.NoError(t, err)
+	}
+	deliveries, cErr = hooks.Deliveries(0, brokenHook.ID)
+	require.Nil(t, cErr)
+	assert.Equal(t, domain.DeliveryFailed, deliveries[0].Status)
+	assert.Equal(t, webhook.MaxAttempts, deliveries[0].Attempts)
+	assert.Len(t, broken.received(), webhook.MaxAttempts)
+
+	assert.Equal(t, 10*time.Second, webhook.Backoff(1))
+	assert.Equal(t, time.Hour, webhook.Backoff(20))
+}
+
+func TestWebhookErrors(t *testing.T) {
+	db, err := storage.Open("sqlite", filepath.Join(t.TempDir(), "webhooks.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+	hooks := webhook.NewService(db, nil)
+
+	hook, cErr := hooks.Create(&domain.Webhook{URL: "https://example.com/hook"})
+	require.Nil(t, cErr)
+
+	//Чужая или несуществующая подписка — ошибка id, а не сбой хранилища
+	cErr = hooks.Update(&domain.Webhook{ID: hook.ID, UserID: 1, URL: "https://example.com/other"})
+	require.NotNil(t, cErr)
+	assert.ErrorIs(t, cErr.Err, domain.ErrID)
+	cErr = hooks.Delete(0, hook.ID+1)
+	require.NotNil(t, cErr)
+	assert.ErrorIs(t, cErr.Err, domain.ErrID)
+
+	//Ошибки хранилища возвращаются как внутренние
+	require.NoError(t, db.Close())
+	cErr = hooks.Update(&domain.Webhook{ID: hook.ID, URL: "https://example.com/other"})
+	require.NotNil(t, cErr)
+	assert.ErrorIs(t, cErr.Err, domain.ErrInternalServer)
+	cErr = hooks.Delete(0, hook.ID)
+	require.NotNil(t, cErr)
+	assert.ErrorIs(t, cErr.Err, domain.ErrInternalServer)
+}
+
+func TestWebhookPrivateAddresses(t *testing.T) {
+	db, err := storage.Open("sqlite", filepath.Join(t.TempDir(), "webhooks.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+	defer db.Close()
+
+	dispatcher := webhook.NewDispatcher(db, time.Minute, false)
+	hooks := webhook.NewService(db, dispatcher)
+	for _, u := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://LOCALHOST./hook",
+		"http://[::1]/hook",
+		"http://[::ffff:10.0.0.1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://192.168.1.10/hook",
+		"http://0.0.0.0/hook",
+	} {
+		_, cErr := hooks.Create(&domain.Webhook{URL: u})
+		require.NotNil(t, cErr, u)
+		assert.ErrorIs(t, cErr.Err, domain.ErrWebhookHost, u)
+	}
+	_, cErr := hooks.Create(&domain.Webhook{URL: "https://example.com/hook"})
+	assert.Nil(t, cErr)
+
+	//Имя может разрешаться во внутренний адрес, поэтому адрес проверяется и при соединении
+	receiver := newHookReceiver()
+	defer receiver.Close()
+	unchecked := webhook.NewService(db, nil)
+	hook, cErr := unchecked.Create(&domain.Webhook{URL: strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)})
+	require.Nil(t, cErr)
+	svc := service.NewService(db, db)
+	svc.Subscribe(unchecked.HandleEvent)
+	_, cErr = svc.Create(&domain.Task{Title: "Внутренний адрес", Date: "20300101"})
+	require.Nil(t, cErr)
+
+	_, err = dispatcher.DeliverPending(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, receiver.received())
+	deliveries, cErr := hooks.Deliveries(0, hook.ID)
+	require.Nil(t, cErr)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, domain.DeliveryPending, deliveries[0].Status)
+	assert.Contains(t, deliveries[0].LastError, domain.ErrWebhookHost.Error())
+}
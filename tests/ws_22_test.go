@@ -0,0 +1,227 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agidelle/todo_web/cmd"
+	"github.com/agidelle/todo_web/internal/config"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type wsMessage struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// wsClient вызывает методы по соединению и складывает полученные уведомления в events
+type wsClient struct {
+	t      *testing.T
+	conn   *websocket.Conn
+	nextID int
+	events []wsMessage
+}
+
+func dialWS(t *testing.T, srv *httptest.Server, token string) *wsClient {
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/ws", header)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &wsClient{t: t, conn: conn}
+}
+
+// read возвращает следующее сообщение
+func (c *wsClient) read() wsMessage {
+	require.NoError(c.t, c.conn.SetReadDeadline(time.Now().Add(3*time.Second)))
+	var msg wsMessage
+	require.NoError(c.t, c.conn.ReadJSON(&msg))
+	return msg
+}
+
+// call отправляет вызов и ждёт ответа на него, уведомления по пути сохраняются
+func (c *wsClient) call(method string, params any) wsMessage {
+	c.nextID++
+	require.NoError(c.t, c.conn.WriteJSON(map[string]any{"id": c.nextID, "method": method, "params": params}))
+	for {
+		msg := c.read()
+		if msg.Method == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		require.Equal(c.t, c.nextID, msg.ID)
+		return msg
+	}
+}
+
+// event возвращает следующее уведомление о событии
+func (c *wsClient) event() (name, taskID string) {
+	var msg wsMessage
+	if len(c.events) > 0 {
+		msg, c.events = c.events[0], c.events[1:]
+	} else {
+		msg = c.read()
+	}
+	require.Equal(c.t, "event", msg.Method)
+	var params struct {
+		ID     uint64 `json:"id"`
+		Event  string `json:"event"`
+		TaskID string `json:"task_id"`
+	}
+	require.NoError(c.t, json.Unmarshal(msg.Params, &params))
+	assert.NotZero(c.t, params.ID)
+	return params.Event, params.TaskID
+}
+
+func TestWebSocket(t *testing.T) {
+	app, db := cmd.New(&config.Config{
+		DBdriver:     "sqlite",
+		DBPath:       filepath.Join(t.TempDir(), "ws.db"),
+		Password:     "secret",
+		JWTKey:       "key",
+		Migrate:      true,
+		Registration: true,
+	})
+	defer db.Close()
+	srv := httptest.NewServer(app.Router())
+	defer srv.Close()
+
+	tokens := make(map[string]string)
+	for _, login := range []string{"alice", "bob"} {
+		code, m := authRequest(t, srv, http.MethodPost, "/api/signup", "", map[string]string{"login": login, "password": login + "-pass"})
+		require.Equal(t, http.StatusCreated, code)
+		tokens[login] = fmt.Sprint(m["token"])
+	}
+
+	alice := dialWS(t, srv, "")
+	resp := alice.call("list", nil)
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, http.StatusUnauthorized, resp.Error.Code, "До auth методы недоступны")
+	resp = alice.call("auth", map[string]string{"token": "неверный"})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, http.StatusUnauthorized, resp.Error.Code)
+	resp = alice.call("auth", map[string]string{"token": tokens["alice"]})
+	require.Nil(t, resp.Error)
+	resp = alice.call("auth", map[string]string{"token": tokens["bob"]})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, http.StatusForbidden, resp.Error.Code, "Пользователь соединения не меняется")
+
+	//Токен в заголовке при подключении
+	bob := dialWS(t, srv, tokens["bob"])
+	require.Nil(t, bob.call("subscribe", nil).Error)
+	require.Nil(t, alice.call("subscribe", nil).Error)
+
+	resp = alice.call("add", map[string]any{"date": "20300101", "title": "Задача по WebSocket", "priority": 2})
+	require.Nil(t, resp.Error)
+	var added struct {
+		ID int64 `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Result, &added))
+	id := fmt.Sprint(added.ID)
+	event, taskID := alice.event()
+	assert.Equal(t, "task.created", event)
+	assert.Equal(t, id, taskID)
+
+	resp = alice.call("get", map[string]any{"id": added.ID})
+	require.Nil(t, resp.Error)
+	var task map[string]any
+	require.NoError(t, json.Unmarshal(resp.Result, &task))
+	assert.Equal(t, "Задача по WebSocket", task["title"])
+	assert.Equal(t, id, task["id"])
+
+	resp = alice.call("update", map[string]any{"id": id, "date": "20300102", "title": "Изменённая задача"})
+	require.Nil(t, resp.Error)
+	event, _ = alice.event()
+	assert.Equal(t, "task.updated", event)
+
+	resp = alice.call("update", map[string]any{"id": id, "date": "20300102", "title": ""})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, http.StatusBadRequest, resp.Error.Code)
+
+	resp = alice.call("list", map[string]any{"search": "Изменённая"})
+	require.Nil(t, resp.Error)
+	var list struct {
+		Tasks []map[string]any `json:"tasks"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Result, &list))
+	require.Len(t, list.Tasks, 1)
+	assert.Equal(t, id, list.Tasks[0]["id"])
+
+	//Чужие задачи и события недоступны
+	resp = bob.call("list", nil)
+	require.Nil(t, resp.Error)
+	list.Tasks = nil
+	require.NoError(t, json.Unmarshal(resp.Result, &list))
+	assert.Empty(t, list.Tasks)
+	assert.Empty(t, bob.events)
+
+	//Страницы списка как в /api/tasks
+	for day := 1; day <= 5; day++ {
+		require.Nil(t, alice.call("add", map[string]any{"date": fmt.Sprintf("203002%02d", day), "title": "Задача"}).Error)
+		event, _ = alice.event()
+		require.Equal(t, "task.created", event)
+	}
+	var page struct {
+		Tasks      []map[string]any `json:"tasks"`
+		NextCursor string           `json:"next_cursor"`
+		Total      int              `json:"total"`
+	}
+	var dates []any
+	params := map[string]any{"limit": 2, "sort": "date", "desc": true, "from": "20300201"}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+		resp = alice.call("list", params)
+		require.Nil(t, resp.Error)
+		page.NextCursor = ""
+		require.NoError(t, json.Unmarshal(resp.Result, &page))
+		assert.Equal(t, 5, page.Total)
+		for _, task := range page.Tasks {
+			dates = append(dates, task["date"])
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		params["cursor"] = page.NextCursor
+	}
+	assert.Equal(t, []any{"20300205", "20300204", "20300203", "20300202", "20300201"}, dates)
+	resp = alice.call("list", map[string]any{"limit": 2, "sort": "title", "cursor": params["cursor"]})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, http.StatusBadRequest, resp.Error.Code, "Курсор выдан для другого порядка")
+	resp = bob.call("done", map[string]any{"id": id})
+	assert.NotNil(t, resp.Error)
+
+	require.Nil(t, alice.call("done", map[string]any{"id": id}).Error)
+	event, _ = alice.event()
+	assert.Equal(t, "task.done", event)
+	require.Nil(t, alice.call("delete", map[string]any{"id": id}).Error)
+	event, _ = alice.event()
+	assert.Equal(t, "task.deleted", event)
+
+	resp = alice.call("archive", nil)
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, http.StatusNotFound, resp.Error.Code)
+	resp = alice.call("get", map[string]any{"id": "abc"})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, http.StatusBadRequest, resp.Error.Code)
+	require.NoError(t, alice.conn.WriteMessage(websocket.TextMessage, []byte("{")))
+	msg := alice.read()
+	require.NotNil(t, msg.Error)
+	assert.Equal(t, http.StatusBadRequest, msg.Error.Code)
+	assert.Empty(t, bob.events)
+}